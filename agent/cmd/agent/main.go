@@ -6,10 +6,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"zenoguard-agent/internal/collector"
 	"zenoguard-agent/internal/config"
 	"zenoguard-agent/internal/daemon"
+	"zenoguard-agent/internal/firewall"
 	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/proxy"
 	"zenoguard-agent/internal/reporter"
 )
 
@@ -173,6 +177,64 @@ func main() {
 		ServerURL:      cfg.ServerURL,
 		Token:          cfg.Token,
 		ReportInterval: cfg.ReportInterval,
+		StateDir:       config.GetStateDir(),
+		BruteForce:     bruteForceConfig(cfg),
+		Filesystems: collector.FilesystemConfig{
+			IncludeTypes:  cfg.FilesystemIncludeTypes,
+			ExcludeTypes:  cfg.FilesystemExcludeTypes,
+			IncludeMounts: cfg.FilesystemIncludeMounts,
+			ExcludeMounts: cfg.FilesystemExcludeMounts,
+		},
+		Disks: collector.DiskConfig{
+			Partitions:   cfg.DiskPartitions,
+			DeviceMapper: cfg.DiskDeviceMapper,
+			Loop:         cfg.DiskLoop,
+			Include:      cfg.DiskInclude,
+			Exclude:      cfg.DiskExclude,
+		},
+		Processes: collector.ProcessConfig{
+			TopN:       cfg.ProcessTopN,
+			CmdlineMax: cfg.ProcessCmdlineMax,
+			RedactArgs: cfg.ProcessRedactArgs,
+			RedactKeys: cfg.ProcessRedactKeys,
+		},
+		Connections: collector.ConnectionConfig{
+			TopN:            cfg.ConnectionTopN,
+			ThreatIntelPath: threatIntelPath(cfg),
+		},
+		Integrity: collector.IntegrityConfig{
+			Paths:       cfg.IntegrityPaths,
+			ExtraPaths:  cfg.IntegrityExtraPaths,
+			Exclude:     cfg.IntegrityExclude,
+			MaxHashSize: cfg.IntegrityMaxHashSize,
+			NoInotify:   cfg.IntegrityNoInotify,
+		},
+		Firewall: firewall.Config{
+			Backend:      cfg.FirewallBackend,
+			Table:        cfg.FirewallTable,
+			BanTTL:       time.Duration(cfg.FirewallBanTTL) * time.Second,
+			Allowlist:    cfg.FirewallAllowlist,
+			BanCommand:   cfg.FirewallBanCommand,
+			UnbanCommand: cfg.FirewallUnbanCommand,
+		},
+		Spool: reporter.SpoolConfig{
+			MaxSize: cfg.SpoolMaxSize,
+			MaxAge:  time.Duration(cfg.SpoolMaxAge) * time.Second,
+		},
+		Client: reporter.ClientConfig{
+			Compression:    cfg.ReportCompression,
+			MaxPayloadSize: cfg.ReportMaxPayloadSize,
+			CAFile:         cfg.TLSCAFile,
+			CertFile:       cfg.TLSCertFile,
+			KeyFile:        cfg.TLSKeyFile,
+			ServerName:     cfg.TLSServerName,
+			Pins:           cfg.TLSPins,
+			Proxy: proxy.Config{
+				URL:     cfg.ProxyURL,
+				NoProxy: cfg.NoProxy,
+			},
+			SigningSecret: cfg.SigningSecret,
+		},
 	}
 	rep := reporter.NewReporter(reporterCfg)
 
@@ -180,14 +242,11 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 
-	// Handle signals in a goroutine
+	// Handle signals in a goroutine, Start returns once the reporter stopped
 	go func() {
 		sig := <-sigChan
 		logger.Info("Received signal: " + sig.String())
 		rep.Stop()
-		daemon.RemovePIDFile()
-		logger.Close()
-		os.Exit(0)
 	}()
 
 	// Note: PID file is already written by the parent during daemonization
@@ -202,6 +261,11 @@ func main() {
 		daemon.RemovePIDFile()
 		os.Exit(1)
 	}
+
+	// Spool what was collected since the last report before exiting
+	rep.Flush()
+	daemon.RemovePIDFile()
+	logger.Close()
 }
 
 // configureAgent runs the interactive configuration
@@ -229,6 +293,37 @@ func configureAgent(serverURL, token string) error {
 	return config.SaveConfig(cfg)
 }
 
+// threatIntelPath returns the configured threat-intel list, or the default one
+func threatIntelPath(cfg *config.Config) string {
+	if cfg.ThreatIntelFile != "" {
+		return cfg.ThreatIntelFile
+	}
+	return config.GetThreatIntelPath()
+}
+
+// bruteForceConfig builds the brute-force detection thresholds from the config
+// Zero keeps the default, a negative value disables the threshold
+func bruteForceConfig(cfg *config.Config) collector.BruteForceConfig {
+	bf := collector.DefaultBruteForceConfig()
+	if cfg.BruteForceWindow > 0 {
+		bf.Window = time.Duration(cfg.BruteForceWindow) * time.Second
+	}
+	setThreshold(&bf.MaxFailures, cfg.BruteForceMaxFailures)
+	setThreshold(&bf.MaxUsers, cfg.BruteForceMaxUsers)
+	setThreshold(&bf.MaxUserFailures, cfg.BruteForceMaxUserFailures)
+	bf.SummarizeFailures = cfg.BruteForceSummarize
+	return bf
+}
+
+// setThreshold overrides a default threshold, negative disables it
+func setThreshold(threshold *int, value int) {
+	if value < 0 {
+		*threshold = 0
+	} else if value > 0 {
+		*threshold = value
+	}
+}
+
 // parseLogLevel parses log level string
 func parseLogLevel(level string) logger.LogLevel {
 	switch level {
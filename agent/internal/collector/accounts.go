@@ -0,0 +1,410 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+// privilegedGroups grant root or root-equivalent access to their members
+var privilegedGroups = []string{"root", "sudo", "wheel", "admin", "adm", "docker", "lxd", "libvirt", "disk", "shadow"}
+
+// noLoginShells are the shells of accounts that cannot log in
+var noLoginShells = []string{"nologin", "false", "true"}
+
+// AccountEvent is a change of a local user or group
+type AccountEvent struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`    // user_created, user_deleted, uid_changed, gid_changed, home_changed, shell_changed, login_shell_granted, password_changed, group_added, group_removed, group_created, group_deleted, group_gid_changed
+	Severity   string    `json:"severity"` // high, medium, low
+	User       string    `json:"user,omitempty"`
+	Group      string    `json:"group,omitempty"`
+	Before     string    `json:"before,omitempty"`
+	After      string    `json:"after,omitempty"`
+	LastChange string    `json:"last_change,omitempty"` // shadow lastchg of password_changed, YYYY-MM-DD
+}
+
+// AccountData is the result of an account collection
+type AccountData struct {
+	Users  int            `json:"users"`
+	Groups int            `json:"groups"`
+	Events []AccountEvent `json:"events"` // changes since the last delivered report
+}
+
+// accountRecord is the persisted view of a user
+// The password hash is only kept as a SHA-256 digest to notice changes
+type accountRecord struct {
+	UID            int      `json:"uid"`
+	GID            int      `json:"gid"`
+	Home           string   `json:"home"`
+	Shell          string   `json:"shell"`
+	Groups         []string `json:"groups"` // supplementary groups, sorted
+	PasswordDigest string   `json:"password_digest,omitempty"`
+	PasswordStatus string   `json:"password_status,omitempty"` // set, locked, empty, disabled; empty if shadow is unreadable
+	LastChange     int      `json:"last_change"`
+}
+
+// groupRecord is the persisted view of a group
+type groupRecord struct {
+	GID int `json:"gid"`
+}
+
+// accountState is the persisted account snapshot
+type accountState struct {
+	Initialized bool                     `json:"initialized"`
+	Users       map[string]accountRecord `json:"users"`
+	Groups      map[string]groupRecord   `json:"groups"`
+}
+
+// AccountCollector detects changes of local users and groups by diffing
+// /etc/passwd, /etc/group and /etc/shadow between collections
+type AccountCollector struct {
+	BaseCollector
+	statePath      string
+	committed      accountState
+	working        accountState
+	shadowReadable bool
+	mu             sync.Mutex
+}
+
+// NewAccountCollector creates a new account change collector
+func NewAccountCollector(stateDir string) *AccountCollector {
+	c := &AccountCollector{
+		BaseCollector:  BaseCollector{name: "accounts"},
+		statePath:      statePath(stateDir, "accounts.json"),
+		shadowReadable: true,
+	}
+
+	if err := state.Load(c.statePath, &c.committed); err != nil {
+		logger.Warn("Failed to load account state, starting fresh: " + err.Error())
+	}
+	if c.committed.Users == nil {
+		c.committed.Users = make(map[string]accountRecord)
+	}
+	if c.committed.Groups == nil {
+		c.committed.Groups = make(map[string]groupRecord)
+	}
+
+	return c
+}
+
+// Collect reads the account databases and returns the changes since the
+// last delivered report
+// The first collection is the baseline and raises no events
+func (c *AccountCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Checking local accounts")
+
+	users, err := readPasswd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", passwdPath, err)
+	}
+	groups, err := readGroup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", groupPath, err)
+	}
+	shadow, err := readShadow()
+	if err != nil {
+		if c.shadowReadable {
+			logger.Warn("Password changes are not tracked, cannot read " + shadowPath + ": " + err.Error())
+			c.shadowReadable = false
+		}
+		shadow = nil
+	} else {
+		c.shadowReadable = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.working = buildAccountState(users, groups, shadow)
+
+	data := &AccountData{
+		Users:  len(c.working.Users),
+		Groups: len(c.working.Groups),
+		Events: make([]AccountEvent, 0),
+	}
+	if c.committed.Initialized {
+		data.Events = diffAccounts(c.committed, c.working, time.Now())
+	}
+
+	for _, event := range data.Events {
+		if event.Severity == "high" {
+			logger.Warn(fmt.Sprintf("Account change: %s user=%s group=%s %s -> %s",
+				event.Event, event.User, event.Group, event.Before, event.After))
+		}
+	}
+
+	logger.Info(fmt.Sprintf("Found %d users and %d groups, %d changes", data.Users, data.Groups, len(data.Events)))
+	return data, nil
+}
+
+// Commit makes the last snapshot the baseline of the next collection
+func (c *AccountCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.working.Initialized {
+		return nil
+	}
+	c.committed = c.working
+	return state.Save(c.statePath, c.committed)
+}
+
+// buildAccountState combines the three databases into a snapshot
+// shadow is nil when /etc/shadow could not be read
+func buildAccountState(users []passwdEntry, groups []groupEntry, shadow []shadowEntry) accountState {
+	state := accountState{
+		Initialized: true,
+		Users:       make(map[string]accountRecord, len(users)),
+		Groups:      make(map[string]groupRecord, len(groups)),
+	}
+
+	memberOf := make(map[string][]string)
+	for _, group := range groups {
+		if _, ok := state.Groups[group.Name]; ok {
+			continue
+		}
+		state.Groups[group.Name] = groupRecord{GID: group.GID}
+		for _, member := range group.Members {
+			memberOf[member] = append(memberOf[member], group.Name)
+		}
+	}
+
+	passwords := make(map[string]shadowEntry, len(shadow))
+	for _, entry := range shadow {
+		passwords[entry.Name] = entry
+	}
+
+	for _, user := range users {
+		if _, ok := state.Users[user.Name]; ok {
+			continue // the first entry wins, as with getpwnam
+		}
+
+		record := accountRecord{
+			UID:        user.UID,
+			GID:        user.GID,
+			Home:       user.Home,
+			Shell:      user.Shell,
+			Groups:     memberOf[user.Name],
+			LastChange: -1,
+		}
+		if record.Groups == nil {
+			record.Groups = []string{}
+		}
+		sort.Strings(record.Groups)
+
+		if entry, ok := passwords[user.Name]; ok {
+			digest := sha256.Sum256([]byte(entry.Hash))
+			record.PasswordDigest = hex.EncodeToString(digest[:])
+			record.PasswordStatus = passwordStatus(entry.Hash)
+			record.LastChange = entry.LastChange
+		}
+
+		state.Users[user.Name] = record
+	}
+
+	return state
+}
+
+// diffAccounts returns the user and group changes between two snapshots
+func diffAccounts(before, after accountState, now time.Time) []AccountEvent {
+	events := make([]AccountEvent, 0)
+	add := func(event AccountEvent) {
+		event.Time = now
+		events = append(events, event)
+	}
+
+	for _, name := range sortedAccountNames(before.Users, after.Users) {
+		old, hadOld := before.Users[name]
+		cur, hasCur := after.Users[name]
+
+		switch {
+		case !hadOld:
+			severity := "medium"
+			if cur.UID == 0 || hasLoginShell(cur.Shell) {
+				severity = "high"
+			}
+			add(AccountEvent{Event: "user_created", Severity: severity, User: name,
+				After: fmt.Sprintf("uid=%d gid=%d home=%s shell=%s", cur.UID, cur.GID, cur.Home, cur.Shell)})
+			for _, group := range cur.Groups {
+				add(groupMembershipEvent("group_added", name, group))
+			}
+			continue
+		case !hasCur:
+			add(AccountEvent{Event: "user_deleted", Severity: "medium", User: name,
+				Before: fmt.Sprintf("uid=%d gid=%d home=%s shell=%s", old.UID, old.GID, old.Home, old.Shell)})
+			continue
+		}
+
+		if old.UID != cur.UID {
+			severity := "medium"
+			if cur.UID == 0 {
+				severity = "high"
+			}
+			add(AccountEvent{Event: "uid_changed", Severity: severity, User: name,
+				Before: strconv.Itoa(old.UID), After: strconv.Itoa(cur.UID)})
+		}
+		if old.GID != cur.GID {
+			// A primary group grants the same access as a supplementary one
+			group, privileged := primaryGroup(after.Groups, cur.GID)
+			severity := "low"
+			if cur.GID == 0 || privileged {
+				severity = "high"
+			}
+			add(AccountEvent{Event: "gid_changed", Severity: severity, User: name, Group: group,
+				Before: strconv.Itoa(old.GID), After: strconv.Itoa(cur.GID)})
+		}
+		if old.Home != cur.Home {
+			add(AccountEvent{Event: "home_changed", Severity: "low", User: name, Before: old.Home, After: cur.Home})
+		}
+		if old.Shell != cur.Shell {
+			event := AccountEvent{Event: "shell_changed", Severity: "low", User: name, Before: old.Shell, After: cur.Shell}
+			if !hasLoginShell(old.Shell) && hasLoginShell(cur.Shell) {
+				event.Event = "login_shell_granted"
+				event.Severity = "high"
+			}
+			add(event)
+		}
+
+		// Only compared when both snapshots could read /etc/shadow
+		if old.PasswordDigest != "" && cur.PasswordDigest != "" && old.PasswordDigest != cur.PasswordDigest {
+			event := AccountEvent{Event: "password_changed", Severity: "medium", User: name,
+				Before: old.PasswordStatus, After: cur.PasswordStatus}
+			if cur.LastChange >= 0 {
+				event.LastChange = time.Unix(int64(cur.LastChange)*86400, 0).UTC().Format("2006-01-02")
+			}
+			add(event)
+		}
+
+		for _, group := range cur.Groups {
+			if !containsString(old.Groups, group) {
+				add(groupMembershipEvent("group_added", name, group))
+			}
+		}
+		for _, group := range old.Groups {
+			if !containsString(cur.Groups, group) {
+				add(groupMembershipEvent("group_removed", name, group))
+			}
+		}
+	}
+
+	groupNames := make([]string, 0, len(before.Groups)+len(after.Groups))
+	for name := range before.Groups {
+		groupNames = append(groupNames, name)
+	}
+	for name := range after.Groups {
+		if _, ok := before.Groups[name]; !ok {
+			groupNames = append(groupNames, name)
+		}
+	}
+	sort.Strings(groupNames)
+
+	for _, name := range groupNames {
+		old, hadOld := before.Groups[name]
+		cur, hasCur := after.Groups[name]
+
+		switch {
+		case !hadOld:
+			severity := "low"
+			if cur.GID == 0 {
+				severity = "high"
+			}
+			add(AccountEvent{Event: "group_created", Severity: severity, Group: name, After: strconv.Itoa(cur.GID)})
+		case !hasCur:
+			add(AccountEvent{Event: "group_deleted", Severity: "low", Group: name, Before: strconv.Itoa(old.GID)})
+		case old.GID != cur.GID:
+			severity := "low"
+			if cur.GID == 0 {
+				severity = "high"
+			}
+			add(AccountEvent{Event: "group_gid_changed", Severity: severity, Group: name,
+				Before: strconv.Itoa(old.GID), After: strconv.Itoa(cur.GID)})
+		}
+	}
+
+	return events
+}
+
+// primaryGroup returns the name of the group with gid and whether it is a
+// privileged group
+// Groups sharing a GID share their access, a privileged one is preferred
+func primaryGroup(groups map[string]groupRecord, gid int) (string, bool) {
+	names := make([]string, 0, 1)
+	for name, group := range groups {
+		if group.GID == gid {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if containsString(privilegedGroups, name) {
+			return name, true
+		}
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	return names[0], false
+}
+
+// groupMembershipEvent builds a group_added or group_removed event, joining
+// a privileged group is high severity
+func groupMembershipEvent(event, user, group string) AccountEvent {
+	severity := "low"
+	if containsString(privilegedGroups, group) {
+		severity = "medium"
+		if event == "group_added" {
+			severity = "high"
+		}
+	}
+	return AccountEvent{Event: event, Severity: severity, User: user, Group: group}
+}
+
+// passwordStatus classifies a shadow password field without revealing it
+func passwordStatus(hash string) string {
+	switch {
+	case hash == "":
+		return "empty" // login without a password
+	case strings.HasPrefix(hash, "!"):
+		return "locked"
+	case hash == "*" || hash == "x":
+		return "disabled"
+	default:
+		return "set"
+	}
+}
+
+// hasLoginShell reports whether a shell lets the account log in
+func hasLoginShell(shell string) bool {
+	return shell != "" && !containsString(noLoginShells, filepath.Base(shell))
+}
+
+// sortedAccountNames returns the user names of two snapshots, sorted
+func sortedAccountNames(a, b map[string]accountRecord) []string {
+	names := make([]string, 0, len(a)+len(b))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
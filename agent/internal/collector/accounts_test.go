@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffAccountsPrimaryGroup(t *testing.T) {
+	groups := map[string]groupRecord{
+		"root":   {GID: 0},
+		"docker": {GID: 998},
+		"alice":  {GID: 1000},
+		"staff":  {GID: 50},
+	}
+
+	tests := []struct {
+		name     string
+		gid      int
+		group    string
+		severity string
+	}{
+		{name: "unprivileged group", gid: 50, group: "staff", severity: "low"},
+		{name: "root group", gid: 0, group: "root", severity: "high"},
+		{name: "privileged group", gid: 998, group: "docker", severity: "high"},
+		{name: "group not in /etc/group", gid: 4242, severity: "low"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := accountState{Initialized: true, Groups: groups, Users: map[string]accountRecord{
+				"alice": {UID: 1000, GID: 1000, Home: "/home/alice", Shell: "/bin/bash", Groups: []string{}},
+			}}
+			after := accountState{Initialized: true, Groups: groups, Users: map[string]accountRecord{
+				"alice": {UID: 1000, GID: tt.gid, Home: "/home/alice", Shell: "/bin/bash", Groups: []string{}},
+			}}
+
+			events := diffAccounts(before, after, time.Now())
+			if len(events) != 1 || events[0].Event != "gid_changed" {
+				t.Fatalf("events = %+v, want one gid_changed", events)
+			}
+			if events[0].Group != tt.group || events[0].Severity != tt.severity {
+				t.Errorf("gid_changed group = %q severity = %q, want %q %q",
+					events[0].Group, events[0].Severity, tt.group, tt.severity)
+			}
+		})
+	}
+}
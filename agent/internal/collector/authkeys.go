@@ -0,0 +1,439 @@
+package collector
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+// sshdConfigPath is the OpenSSH server configuration
+const sshdConfigPath = "/etc/ssh/sshd_config"
+
+// defaultAuthorizedKeysFiles is the sshd default of AuthorizedKeysFile
+var defaultAuthorizedKeysFiles = []string{".ssh/authorized_keys", ".ssh/authorized_keys2"}
+
+// AuthorizedKey is one public key of an authorized_keys file
+type AuthorizedKey struct {
+	User        string   `json:"user"`
+	File        string   `json:"file"`
+	Line        int      `json:"line"`
+	Type        string   `json:"type"`        // ssh-ed25519, ssh-rsa, ecdsa-sha2-nistp256, ...
+	Fingerprint string   `json:"fingerprint"` // SHA256:..., as printed by ssh-keygen -l
+	Comment     string   `json:"comment,omitempty"`
+	Options     []string `json:"options,omitempty"` // from="...", command="...", no-pty, ...
+}
+
+// key identifies an authorized key across collections
+// Options are part of it: lifting a command= restriction is a new key
+func (k AuthorizedKey) key() string {
+	return k.User + " " + k.File + " " + k.Fingerprint + " " + strings.Join(k.Options, ",")
+}
+
+// AuthorizedKeyEvent is a key added to or removed from an authorized_keys file
+type AuthorizedKeyEvent struct {
+	Time  time.Time     `json:"time"`
+	Event string        `json:"event"` // key_added, key_removed
+	Key   AuthorizedKey `json:"key"`
+}
+
+// AuthorizedKeysData is the result of an authorized_keys collection
+type AuthorizedKeysData struct {
+	Keys   []AuthorizedKey      `json:"keys"`
+	Events []AuthorizedKeyEvent `json:"events"` // changes since the last delivered report
+}
+
+// authorizedKeysState is the persisted key inventory
+type authorizedKeysState struct {
+	Initialized bool                     `json:"initialized"`
+	Keys        map[string]AuthorizedKey `json:"keys"`
+}
+
+// AuthorizedKeysCollector tracks the SSH public keys every local account
+// accepts, in the files named by the AuthorizedKeysFile setting of sshd
+type AuthorizedKeysCollector struct {
+	BaseCollector
+	configPath string
+	statePath  string
+	committed  authorizedKeysState
+	working    authorizedKeysState
+	mu         sync.Mutex
+}
+
+// NewAuthorizedKeysCollector creates a new authorized_keys collector
+func NewAuthorizedKeysCollector(stateDir string) *AuthorizedKeysCollector {
+	c := &AuthorizedKeysCollector{
+		BaseCollector: BaseCollector{name: "authorized_keys"},
+		configPath:    sshdConfigPath,
+		statePath:     statePath(stateDir, "authorized_keys.json"),
+	}
+
+	if err := state.Load(c.statePath, &c.committed); err != nil {
+		logger.Warn("Failed to load authorized_keys state, starting fresh: " + err.Error())
+	}
+	if c.committed.Keys == nil {
+		c.committed.Keys = make(map[string]AuthorizedKey)
+	}
+
+	return c
+}
+
+// Collect reads the authorized keys of every account and returns the keys
+// added or removed since the last delivered report
+// The first collection is the baseline and raises no events
+func (c *AuthorizedKeysCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Collecting authorized SSH keys")
+
+	users, err := readPasswd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+
+	files := defaultAuthorizedKeysFiles
+	if values := readSSHDConfig(c.configPath)["authorizedkeysfile"]; len(values) > 0 {
+		files = values
+	}
+
+	keys := make([]AuthorizedKey, 0)
+	seenFile := make(map[string]bool)
+	for _, user := range users {
+		for _, pattern := range files {
+			if strings.EqualFold(pattern, "none") {
+				continue
+			}
+			path := expandAuthorizedKeysPath(pattern, user)
+			if seenFile[user.Name+" "+path] {
+				continue
+			}
+			seenFile[user.Name+" "+path] = true
+
+			fileKeys, err := readAuthorizedKeys(path, user.Name)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					logger.Debug("Failed to read %s: %v", path, err)
+				}
+				continue
+			}
+			keys = append(keys, fileKeys...)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.working = authorizedKeysState{
+		Initialized: true,
+		Keys:        make(map[string]AuthorizedKey, len(keys)),
+	}
+	for _, key := range keys {
+		if _, ok := c.working.Keys[key.key()]; !ok {
+			c.working.Keys[key.key()] = key
+		}
+	}
+
+	data := &AuthorizedKeysData{
+		Keys:   keys,
+		Events: make([]AuthorizedKeyEvent, 0),
+	}
+	if c.committed.Initialized {
+		data.Events = diffAuthorizedKeys(c.committed.Keys, c.working.Keys, time.Now())
+	}
+
+	for _, event := range data.Events {
+		logger.Warn(fmt.Sprintf("SSH key %s for %s: %s %s %s", strings.TrimPrefix(event.Event, "key_"),
+			event.Key.User, event.Key.Type, event.Key.Fingerprint, event.Key.Comment))
+	}
+
+	logger.Info(fmt.Sprintf("Found %d authorized SSH keys, %d changes", len(data.Keys), len(data.Events)))
+	return data, nil
+}
+
+// Commit makes the last inventory the baseline of the next collection
+func (c *AuthorizedKeysCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.working.Initialized {
+		return nil
+	}
+	c.committed = c.working
+	return state.Save(c.statePath, c.committed)
+}
+
+// diffAuthorizedKeys returns the keys added and removed between two inventories
+func diffAuthorizedKeys(before, after map[string]AuthorizedKey, now time.Time) []AuthorizedKeyEvent {
+	events := make([]AuthorizedKeyEvent, 0)
+
+	for id, key := range after {
+		if _, ok := before[id]; !ok {
+			events = append(events, AuthorizedKeyEvent{Time: now, Event: "key_added", Key: key})
+		}
+	}
+	for id, key := range before {
+		if _, ok := after[id]; !ok {
+			events = append(events, AuthorizedKeyEvent{Time: now, Event: "key_removed", Key: key})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Event != events[j].Event {
+			return events[i].Event < events[j].Event
+		}
+		return events[i].Key.key() < events[j].Key.key()
+	})
+	return events
+}
+
+// expandAuthorizedKeysPath expands the sshd tokens of an AuthorizedKeysFile
+// entry (%h home, %u user, %U uid, %% percent); relative paths start at
+// the home directory
+func expandAuthorizedKeysPath(pattern string, user passwdEntry) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'h':
+			b.WriteString(user.Home)
+		case 'u':
+			b.WriteString(user.Name)
+		case 'U':
+			b.WriteString(strconv.Itoa(user.UID))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(pattern[i])
+		}
+	}
+
+	path := b.String()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(user.Home, path)
+	}
+	return filepath.Clean(path)
+}
+
+// readAuthorizedKeys parses the keys of one authorized_keys file. The path
+// is under the user's control, so it is opened without blocking and
+// anything but a regular file is rejected, as sshd does; a FIFO would
+// otherwise stall the collector forever
+func readAuthorizedKeys(path, user string) ([]AuthorizedKey, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("not a regular file (%s)", info.Mode().Type())
+	}
+
+	return parseAuthorizedKeys(file, path, user)
+}
+
+// parseAuthorizedKeys parses authorized_keys lines:
+// "[options] type base64-key [comment]"
+// Lines that do not hold a valid key are skipped, as sshd does
+func parseAuthorizedKeys(r io.Reader, path, user string) ([]AuthorizedKey, error) {
+	keys := make([]AuthorizedKey, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // RSA keys with options are long
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, ok := parseAuthorizedKeyLine(line)
+		if !ok {
+			continue
+		}
+		key.User = user
+		key.File = path
+		key.Line = lineNumber
+		keys = append(keys, key)
+	}
+
+	return keys, scanner.Err()
+}
+
+// parseAuthorizedKeyLine parses one authorized_keys entry
+func parseAuthorizedKeyLine(line string) (AuthorizedKey, bool) {
+	var key AuthorizedKey
+
+	// Options come first unless the line starts with the key type; they
+	// end at the first blank outside double quotes
+	if !isSSHKeyType(firstField(line)) {
+		end := optionsEnd(line)
+		key.Options = splitOptions(line[:end])
+		line = strings.TrimSpace(line[end:])
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !isSSHKeyType(fields[0]) {
+		return key, false
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return key, false
+	}
+
+	key.Type = fields[0]
+	key.Fingerprint = sshFingerprint(blob)
+	if len(fields) > 2 {
+		key.Comment = strings.Join(fields[2:], " ")
+	}
+	return key, true
+}
+
+// sshFingerprint returns the OpenSSH SHA256 fingerprint of a key blob
+func sshFingerprint(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// isSSHKeyType reports whether s names a public key algorithm
+func isSSHKeyType(s string) bool {
+	return strings.HasPrefix(s, "ssh-") || strings.HasPrefix(s, "ecdsa-sha2-") ||
+		strings.HasPrefix(s, "sk-ssh-") || strings.HasPrefix(s, "sk-ecdsa-")
+}
+
+// firstField returns the text up to the first blank
+func firstField(s string) string {
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// optionsEnd returns the index of the first blank outside double quotes
+func optionsEnd(s string) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ' ', '\t':
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+// splitOptions splits an option list at the commas outside double quotes
+func splitOptions(s string) []string {
+	options := make([]string, 0)
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				options = append(options, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(s) {
+		options = append(options, s[start:])
+	}
+	return options
+}
+
+// readSSHDConfig reads the global settings of sshd_config and the files it
+// includes, keyed by lower-case keyword
+// As in sshd the first value of a keyword wins; Match blocks are ignored
+func readSSHDConfig(path string) map[string][]string {
+	settings := make(map[string][]string)
+	readSSHDConfigFile(path, settings, 0)
+	return settings
+}
+
+// readSSHDConfigFile adds the settings of one file, following Include
+// It returns true once a Match block starts
+func readSSHDConfigFile(path string, settings map[string][]string, depth int) bool {
+	if depth > 8 {
+		return false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		// "Keyword=value" is accepted too
+		if keyword, value, ok := strings.Cut(fields[0], "="); ok {
+			fields = append([]string{keyword}, append([]string{value}, fields[1:]...)...)
+		}
+		keyword := strings.ToLower(fields[0])
+
+		switch keyword {
+		case "match":
+			return true // everything after belongs to Match blocks
+		case "include":
+			for _, pattern := range fields[1:] {
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(filepath.Dir(sshdConfigPath), pattern)
+				}
+				matches, _ := filepath.Glob(pattern)
+				sort.Strings(matches)
+				for _, match := range matches {
+					if readSSHDConfigFile(match, settings, depth+1) {
+						return true
+					}
+				}
+			}
+		default:
+			if _, ok := settings[keyword]; !ok && len(fields) > 1 {
+				settings[keyword] = fields[1:]
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReadAuthorizedKeysFileTypes(t *testing.T) {
+	dir := t.TempDir()
+
+	regular := filepath.Join(dir, "regular")
+	line := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl alice@laptop\n"
+	if err := os.WriteFile(regular, []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fifo := filepath.Join(dir, "fifo")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		keys    int
+		wantErr bool
+	}{
+		{name: "regular file", path: regular, keys: 1},
+		{name: "fifo", path: fifo, wantErr: true},
+		{name: "directory", path: dir, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			type result struct {
+				keys []AuthorizedKey
+				err  error
+			}
+			done := make(chan result, 1)
+			go func() {
+				keys, err := readAuthorizedKeys(tt.path, "alice")
+				done <- result{keys, err}
+			}()
+
+			var got result
+			select {
+			case got = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("readAuthorizedKeys blocked")
+			}
+
+			if (got.err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", got.err, tt.wantErr)
+			}
+			if len(got.keys) != tt.keys {
+				t.Fatalf("keys = %+v, want %d", got.keys, tt.keys)
+			}
+		})
+	}
+}
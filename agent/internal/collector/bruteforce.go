@@ -0,0 +1,408 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BruteForceConfig holds the SSH brute-force detection thresholds
+// A zero threshold disables that check
+type BruteForceConfig struct {
+	Window          time.Duration // sliding window length
+	MaxFailures     int           // failures from one IP within the window
+	MaxUsers        int           // distinct usernames tried from one IP within the window
+	MaxUserFailures int           // failures against one username, from any IP, within the window
+
+	// SummarizeFailures drops the raw failed logins covered by a detection
+	// from the report, the event carries their counts and usernames
+	SummarizeFailures bool
+}
+
+// DefaultBruteForceConfig returns the default detection thresholds
+func DefaultBruteForceConfig() BruteForceConfig {
+	return BruteForceConfig{
+		Window:          60 * time.Second,
+		MaxFailures:     10,
+		MaxUsers:        5,
+		MaxUserFailures: 20,
+	}
+}
+
+// BruteForceEvent is raised when failures from one IP or against one user
+// cross a threshold
+type BruteForceEvent struct {
+	Event         string    `json:"event"`  // bruteforce_detected
+	Kind          string    `json:"kind"`   // ip, user
+	Reason        string    `json:"reason"` // failures, usernames
+	IP            string    `json:"ip"`     // set for ip events
+	User          string    `json:"user"`   // set for user events
+	Failures      int       `json:"failures"`
+	DistinctUsers int       `json:"distinct_users"`
+	DistinctIPs   int       `json:"distinct_ips"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+	Usernames     []string  `json:"usernames"` // usernames tried, capped
+	IPs           []string  `json:"ips"`       // source addresses, user events only, capped
+	Window        int64     `json:"window"`    // seconds
+
+	// Every username and IP counted so far, the lists above are capped
+	users map[string]bool
+	ips   map[string]bool
+}
+
+const (
+	// maxWindowFailures bounds the failures kept per IP or user
+	maxWindowFailures = 10000
+	// maxEventNames bounds the usernames/IPs listed in an event
+	maxEventNames = 50
+)
+
+// authFailure is a single failed authentication
+type authFailure struct {
+	Time time.Time
+	User string
+	IP   string
+}
+
+// failureWindow holds the recent failures of one IP or user
+type failureWindow struct {
+	Failures  []authFailure
+	LastAlert time.Time
+}
+
+// bruteForceState is the detector state carried between collections
+type bruteForceState struct {
+	ByIP    map[string]*failureWindow
+	ByUser  map[string]*failureWindow
+	Invalid map[int]string // sshd PID -> user of a pending "Invalid user" line
+}
+
+// bruteForceDetector keeps sliding windows of SSH failures per source IP
+// and per target user
+// Like sessionTracker it works on a copy of the committed state so that
+// failures re-read after a failed report are not counted twice. The state
+// is not persisted, the windows are short enough to rebuild after a restart
+type bruteForceDetector struct {
+	config    BruteForceConfig
+	committed bruteForceState
+	working   bruteForceState
+	events    []BruteForceEvent
+	current   map[string]int // kind/key -> index in events for this cycle
+	mu        sync.Mutex
+}
+
+// newBruteForceDetector creates a detector with the given thresholds
+func newBruteForceDetector(config BruteForceConfig) *bruteForceDetector {
+	if config.Window <= 0 {
+		config.Window = DefaultBruteForceConfig().Window
+	}
+
+	d := &bruteForceDetector{
+		config:    config,
+		committed: newBruteForceState(),
+	}
+	d.reset()
+	return d
+}
+
+// newBruteForceState returns an empty detector state
+func newBruteForceState() bruteForceState {
+	return bruteForceState{
+		ByIP:    make(map[string]*failureWindow),
+		ByUser:  make(map[string]*failureWindow),
+		Invalid: make(map[int]string),
+	}
+}
+
+// copy returns a deep copy of the state
+func (s bruteForceState) copy() bruteForceState {
+	copied := newBruteForceState()
+	for ip, window := range s.ByIP {
+		copied.ByIP[ip] = window.copy()
+	}
+	for user, window := range s.ByUser {
+		copied.ByUser[user] = window.copy()
+	}
+	for pid, user := range s.Invalid {
+		copied.Invalid[pid] = user
+	}
+	return copied
+}
+
+// copy returns a deep copy of the window
+func (w *failureWindow) copy() *failureWindow {
+	return &failureWindow{
+		Failures:  append([]authFailure(nil), w.Failures...),
+		LastAlert: w.LastAlert,
+	}
+}
+
+// reset starts a new collection cycle from the committed state
+func (d *bruteForceDetector) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.working = d.committed.copy()
+	d.events = nil
+	d.current = make(map[string]int)
+}
+
+// commit keeps the windows of the current cycle
+func (d *bruteForceDetector) commit() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.committed = d.working.copy()
+}
+
+// observe feeds one parsed authentication result to the detector
+func (d *bruteForceDetector) observe(record syslogRecord, login SSHLogin) {
+	if login.Success {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// sshd logs "Invalid user x" and then "Failed password for invalid user x"
+	// for the same attempt, count it once
+	if strings.HasPrefix(record.Message, "Invalid user") {
+		d.working.Invalid[record.PID] = login.User
+	} else if strings.Contains(record.Message, " for invalid user ") {
+		if user, ok := d.working.Invalid[record.PID]; ok && user == login.User {
+			delete(d.working.Invalid, record.PID)
+			return
+		}
+	}
+
+	failure := authFailure{Time: record.Time, User: login.User, IP: login.IP}
+	if login.IP != "" {
+		d.add("ip", login.IP, d.working.ByIP, failure)
+	}
+	if login.User != "" {
+		d.add("user", login.User, d.working.ByUser, failure)
+	}
+}
+
+// add appends a failure to the window of key and raises or refreshes an
+// event when a threshold is crossed
+func (d *bruteForceDetector) add(kind, key string, windows map[string]*failureWindow, failure authFailure) {
+	window, ok := windows[key]
+	if !ok {
+		window = &failureWindow{}
+		windows[key] = window
+	}
+
+	window.Failures = append(window.Failures, failure)
+	window.prune(failure.Time.Add(-d.config.Window))
+	if len(window.Failures) > maxWindowFailures {
+		window.Failures = window.Failures[len(window.Failures)-maxWindowFailures:]
+	}
+
+	// Keep the event raised earlier in this cycle up to date
+	if i, ok := d.current[kind+"/"+key]; ok {
+		d.events[i].extend(failure)
+		return
+	}
+
+	event := d.buildEvent(kind, key, window)
+	reason := d.thresholdCrossed(kind, event)
+	if reason == "" {
+		return
+	}
+
+	// One event per window while the attack goes on
+	if !window.LastAlert.IsZero() && failure.Time.Sub(window.LastAlert) < d.config.Window {
+		return
+	}
+
+	window.LastAlert = failure.Time
+	event.Reason = reason
+	d.current[kind+"/"+key] = len(d.events)
+	d.events = append(d.events, event)
+}
+
+// thresholdCrossed returns which threshold the event crosses, if any
+func (d *bruteForceDetector) thresholdCrossed(kind string, event BruteForceEvent) string {
+	switch kind {
+	case "ip":
+		if d.config.MaxFailures > 0 && event.Failures >= d.config.MaxFailures {
+			return "failures"
+		}
+		if d.config.MaxUsers > 0 && event.DistinctUsers >= d.config.MaxUsers {
+			return "usernames"
+		}
+	case "user":
+		if d.config.MaxUserFailures > 0 && event.Failures >= d.config.MaxUserFailures {
+			return "failures"
+		}
+	}
+	return ""
+}
+
+// buildEvent summarizes the failures of a window
+func (d *bruteForceDetector) buildEvent(kind, key string, window *failureWindow) BruteForceEvent {
+	event := BruteForceEvent{
+		Event:    "bruteforce_detected",
+		Kind:     kind,
+		Failures: len(window.Failures),
+		Window:   int64(d.config.Window.Seconds()),
+	}
+	if kind == "ip" {
+		event.IP = key
+	} else {
+		event.User = key
+	}
+
+	users := make(map[string]bool)
+	ips := make(map[string]bool)
+	for _, failure := range window.Failures {
+		if event.FirstSeen.IsZero() || failure.Time.Before(event.FirstSeen) {
+			event.FirstSeen = failure.Time
+		}
+		if failure.Time.After(event.LastSeen) {
+			event.LastSeen = failure.Time
+		}
+		// Failures without a user or IP are counted but not named, as in extend
+		if failure.User != "" {
+			users[failure.User] = true
+		}
+		if failure.IP != "" {
+			ips[failure.IP] = true
+		}
+	}
+
+	event.users = users
+	event.ips = ips
+	event.DistinctUsers = len(users)
+	event.DistinctIPs = len(ips)
+	event.Usernames = sortedKeys(users, maxEventNames)
+	if kind == "user" {
+		event.IPs = sortedKeys(ips, maxEventNames)
+	}
+
+	return event
+}
+
+// extend adds a failure seen after the event was raised
+func (e *BruteForceEvent) extend(failure authFailure) {
+	e.Failures++
+	if failure.Time.After(e.LastSeen) {
+		e.LastSeen = failure.Time
+	}
+
+	if failure.User != "" && !e.users[failure.User] {
+		e.users[failure.User] = true
+		e.DistinctUsers++
+		if len(e.Usernames) < maxEventNames {
+			e.Usernames = append(e.Usernames, failure.User)
+			sort.Strings(e.Usernames)
+		}
+	}
+	if failure.IP != "" && e.Kind == "user" && !e.ips[failure.IP] {
+		e.ips[failure.IP] = true
+		e.DistinctIPs++
+		if len(e.IPs) < maxEventNames {
+			e.IPs = append(e.IPs, failure.IP)
+			sort.Strings(e.IPs)
+		}
+	}
+}
+
+// prune drops the failures older than cutoff
+func (w *failureWindow) prune(cutoff time.Time) {
+	kept := w.Failures[:0]
+	for _, failure := range w.Failures {
+		if !failure.Time.Before(cutoff) {
+			kept = append(kept, failure)
+		}
+	}
+	w.Failures = kept
+}
+
+// expire forgets the IPs and users without failures in the last window
+func (d *bruteForceDetector) expire(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cutoff := now.Add(-d.config.Window)
+	for _, windows := range []map[string]*failureWindow{d.working.ByIP, d.working.ByUser} {
+		for key, window := range windows {
+			window.prune(cutoff)
+			if len(window.Failures) == 0 && window.LastAlert.Before(cutoff) {
+				delete(windows, key)
+			}
+		}
+	}
+
+	// Pending "Invalid user" lines of sshd processes that are gone
+	for pid := range d.working.Invalid {
+		if !processExists(pid) {
+			delete(d.working.Invalid, pid)
+		}
+	}
+}
+
+// detected returns the events raised in this cycle
+func (d *bruteForceDetector) detected() []BruteForceEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append(make([]BruteForceEvent, 0, len(d.events)), d.events...)
+}
+
+// summarize drops the failed logins covered by an event of this cycle
+func (d *bruteForceDetector) summarize(logins []SSHLogin) []SSHLogin {
+	events := d.detected()
+	if !d.config.SummarizeFailures || len(events) == 0 {
+		return logins
+	}
+
+	covered := func(login SSHLogin) bool {
+		for _, event := range events {
+			if login.Timestamp.Before(event.FirstSeen) || login.Timestamp.After(event.LastSeen) {
+				continue
+			}
+			if (event.Kind == "ip" && event.IP == login.IP) || (event.Kind == "user" && event.User == login.User) {
+				return true
+			}
+		}
+		return false
+	}
+
+	kept := make([]SSHLogin, 0, len(logins))
+	for _, login := range logins {
+		if !login.Success && covered(login) {
+			continue
+		}
+		kept = append(kept, login)
+	}
+	return kept
+}
+
+// sortedKeys returns up to limit keys of a set in sorted order
+func sortedKeys(set map[string]bool, limit int) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBruteForceDistinctBeyondListCap(t *testing.T) {
+	detector := newBruteForceDetector(BruteForceConfig{Window: time.Hour, MaxFailures: 1})
+	start := time.Date(2026, time.October, 15, 10, 0, 0, 0, time.UTC)
+
+	// Twice as many usernames as an event lists, each tried three times
+	users := 2 * maxEventNames
+	for round := 0; round < 3; round++ {
+		for i := 0; i < users; i++ {
+			record := syslogRecord{Time: start.Add(time.Duration(round*users+i) * time.Second), PID: 1000 + i,
+				Message: "Failed password for root from 203.0.113.9 port 4242 ssh2"}
+			detector.observe(record, SSHLogin{User: fmt.Sprintf("user%03d", i), IP: "203.0.113.9"})
+		}
+	}
+
+	var event *BruteForceEvent
+	for i := range detector.events {
+		if detector.events[i].Kind == "ip" {
+			event = &detector.events[i]
+		}
+	}
+	if event == nil {
+		t.Fatal("no ip event raised")
+	}
+
+	if event.Failures != 3*users {
+		t.Errorf("Failures = %d, want %d", event.Failures, 3*users)
+	}
+	if event.DistinctUsers != users {
+		t.Errorf("DistinctUsers = %d, want %d", event.DistinctUsers, users)
+	}
+	if len(event.Usernames) != maxEventNames {
+		t.Errorf("listed %d usernames, want %d", len(event.Usernames), maxEventNames)
+	}
+}
+
+func TestBruteForceEventSkipsEmptyNames(t *testing.T) {
+	start := time.Date(2026, time.October, 15, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		logins            []SSHLogin // the event is raised by the last one
+		kind              string
+		wantUsernames     []string
+		wantIPs           []string
+		wantDistinctUsers int
+		wantDistinctIPs   int
+	}{
+		{
+			name: "ip event with failures before the username is known",
+			logins: []SSHLogin{
+				{IP: "203.0.113.9"},
+				{User: "root", IP: "203.0.113.9"},
+				{IP: "203.0.113.9"},
+			},
+			kind:              "ip",
+			wantUsernames:     []string{"root"},
+			wantDistinctUsers: 1,
+			wantDistinctIPs:   1,
+		},
+		{
+			name: "user event with failures without an address",
+			logins: []SSHLogin{
+				{User: "admin"},
+				{User: "admin", IP: "198.51.100.7"},
+				{User: "admin"},
+			},
+			kind:              "user",
+			wantUsernames:     []string{"admin"},
+			wantIPs:           []string{"198.51.100.7"},
+			wantDistinctUsers: 1,
+			wantDistinctIPs:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := newBruteForceDetector(BruteForceConfig{Window: time.Hour, MaxFailures: len(tt.logins),
+				MaxUserFailures: len(tt.logins)})
+			for i, login := range tt.logins {
+				record := syslogRecord{Time: start.Add(time.Duration(i) * time.Second), PID: 1000 + i,
+					Message: "Failed password"}
+				detector.observe(record, login)
+			}
+
+			var event *BruteForceEvent
+			for i := range detector.events {
+				if detector.events[i].Kind == tt.kind {
+					event = &detector.events[i]
+				}
+			}
+			if event == nil {
+				t.Fatalf("no %s event raised", tt.kind)
+			}
+
+			if !reflect.DeepEqual(event.Usernames, tt.wantUsernames) || event.DistinctUsers != tt.wantDistinctUsers {
+				t.Errorf("Usernames = %q (%d distinct), want %q (%d distinct)",
+					event.Usernames, event.DistinctUsers, tt.wantUsernames, tt.wantDistinctUsers)
+			}
+			if !reflect.DeepEqual(event.IPs, tt.wantIPs) || event.DistinctIPs != tt.wantDistinctIPs {
+				t.Errorf("IPs = %q (%d distinct), want %q (%d distinct)",
+					event.IPs, event.DistinctIPs, tt.wantIPs, tt.wantDistinctIPs)
+			}
+		})
+	}
+}
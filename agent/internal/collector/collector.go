@@ -6,6 +6,13 @@ type Collector interface {
 	Name() string
 }
 
+// Committer is implemented by collectors that keep a read position
+// (log offsets, cursors, snapshots) which should only advance once the
+// collected data has been delivered
+type Committer interface {
+	Commit() error
+}
+
 // BaseCollector provides common functionality for collectors
 type BaseCollector struct {
 	name string
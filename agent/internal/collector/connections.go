@@ -0,0 +1,317 @@
+package collector
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// DefaultConnectionTopN is the number of remotes, ports and processes reported
+const DefaultConnectionTopN = 10
+
+// connectionProtocols are the /proc/net tables summarized
+var connectionProtocols = []string{"tcp", "tcp6"}
+
+// ConnectionConfig configures the TCP connection summary
+type ConnectionConfig struct {
+	TopN            int    // entries per ranking, 0 uses DefaultConnectionTopN
+	ThreatIntelPath string // IP/CIDR list of malicious destinations, empty disables matching
+}
+
+// RemoteCount is the number of established connections with one address
+type RemoteCount struct {
+	Address string `json:"address"`
+	Count   int    `json:"count"`
+}
+
+// PortCount is the number of outbound connections to one remote port
+type PortCount struct {
+	Port  int `json:"port"`
+	Count int `json:"count"`
+}
+
+// ConnectionProcess is a process and its number of established connections
+type ConnectionProcess struct {
+	PID     int    `json:"pid"`
+	Process string `json:"process"`
+	Exe     string `json:"exe"`
+	Count   int    `json:"count"`
+}
+
+// ThreatMatch is a connection to or from an address of the threat-intel list
+type ThreatMatch struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`     // threat_intel_match
+	Severity   string    `json:"severity"`  // high
+	Direction  string    `json:"direction"` // outbound, inbound
+	State      string    `json:"state"`
+	LocalIP    string    `json:"local_ip"`
+	LocalPort  int       `json:"local_port"`
+	RemoteIP   string    `json:"remote_ip"`
+	RemotePort int       `json:"remote_port"`
+	Match      string    `json:"match"` // list entry that matched
+	Label      string    `json:"label,omitempty"`
+	PID        int       `json:"pid,omitempty"`
+	Process    string    `json:"process,omitempty"`
+	Exe        string    `json:"exe,omitempty"`
+}
+
+// ConnectionSummary summarizes the TCP connections of the host
+// Loopback connections are counted per state but left out of the direction
+// counts and rankings
+type ConnectionSummary struct {
+	Total       int                 `json:"total"` // sockets in any state but LISTEN
+	States      map[string]int      `json:"states"`
+	Established int                 `json:"established"`
+	Outbound    int                 `json:"outbound"` // established, local port is not a listener
+	Inbound     int                 `json:"inbound"`
+	TopRemotes  []RemoteCount       `json:"top_remotes"`
+	TopPorts    []PortCount         `json:"top_ports"` // remote ports of outbound connections
+	Processes   []ConnectionProcess `json:"processes"`
+	Threats     []ThreatMatch       `json:"threats"` // new matches since the last delivered report
+}
+
+// ConnectionCollector summarizes established TCP connections and matches
+// their remote addresses against a threat-intel list
+type ConnectionCollector struct {
+	BaseCollector
+	procPath  string
+	topN      int
+	threats   *threatList
+	committed map[string]bool // matched connections already reported
+	working   map[string]bool
+	mu        sync.Mutex
+}
+
+// NewConnectionCollector creates a new connection summary collector
+func NewConnectionCollector(config ConnectionConfig) *ConnectionCollector {
+	if config.TopN <= 0 {
+		config.TopN = DefaultConnectionTopN
+	}
+
+	return &ConnectionCollector{
+		BaseCollector: BaseCollector{name: "connections"},
+		procPath:      "/proc",
+		topN:          config.TopN,
+		threats:       newThreatList(config.ThreatIntelPath),
+		committed:     make(map[string]bool),
+	}
+}
+
+// Collect summarizes the current TCP connections
+// A connection matching the threat-intel list is reported once, while it
+// stays open
+func (c *ConnectionCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Collecting TCP connections")
+
+	sockets := make([]netSocket, 0)
+	for _, protocol := range connectionProtocols {
+		table, err := readNetSockets(c.procPath, protocol)
+		if err != nil {
+			logger.Debug("Failed to read /proc/net/%s: %v", protocol, err)
+			continue
+		}
+		sockets = append(sockets, table...)
+	}
+
+	c.threats.refresh()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	summary := &ConnectionSummary{
+		States:     make(map[string]int),
+		TopRemotes: make([]RemoteCount, 0),
+		TopPorts:   make([]PortCount, 0),
+		Processes:  make([]ConnectionProcess, 0),
+		Threats:    make([]ThreatMatch, 0),
+	}
+
+	// Local ports with a listener tell inbound from outbound connections
+	listening := make(map[int]bool)
+	for _, socket := range sockets {
+		if socket.State == tcpListen {
+			listening[socket.LocalPort] = true
+		}
+	}
+
+	established := make([]netSocket, 0)
+	matched := make([]netSocket, 0)
+	entries := make([]*threatEntry, 0)
+	wanted := make(map[uint64]bool)
+
+	for _, socket := range sockets {
+		if socket.State == tcpListen {
+			continue
+		}
+		summary.Total++
+		summary.States[tcpStateName(socket.State)]++
+
+		if socket.RemoteIP.IsUnspecified() || socket.RemoteIP.IsLoopback() {
+			continue
+		}
+
+		if entry := c.threats.match(socket.RemoteIP); entry != nil {
+			matched = append(matched, socket)
+			entries = append(entries, entry)
+			wanted[socket.Inode] = true
+		}
+
+		if socket.State == tcpEstablished {
+			established = append(established, socket)
+			wanted[socket.Inode] = true
+		}
+	}
+
+	owners := socketOwners(c.procPath, wanted)
+	c.summarize(summary, established, listening, owners)
+
+	c.working = make(map[string]bool, len(matched))
+	now := time.Now()
+	for i, socket := range matched {
+		key := connectionKey(socket)
+		c.working[key] = true
+		if c.committed[key] {
+			continue
+		}
+
+		threat := newThreatMatch(socket, entries[i], listening, owners[socket.Inode], now)
+		summary.Threats = append(summary.Threats, threat)
+		logger.Warn(fmt.Sprintf("Connection %s to %s:%d matches threat-intel entry %s (%s, pid %d)",
+			threat.Direction, threat.RemoteIP, threat.RemotePort, threat.Match, threat.Process, threat.PID))
+	}
+
+	logger.Info(fmt.Sprintf("Found %d TCP connections, %d established", summary.Total, summary.Established))
+	return summary, nil
+}
+
+// Commit remembers the reported threat matches
+func (c *ConnectionCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.working != nil {
+		c.committed = c.working
+	}
+	return nil
+}
+
+// summarize fills the established connection counts and rankings
+func (c *ConnectionCollector) summarize(summary *ConnectionSummary, established []netSocket,
+	listening map[int]bool, owners map[uint64]socketProcess) {
+	remotes := make(map[string]int)
+	ports := make(map[int]int)
+	processes := make(map[int]*ConnectionProcess)
+
+	summary.Established = summary.States["ESTABLISHED"]
+	for _, socket := range established {
+		remotes[socket.RemoteIP.String()]++
+
+		if listening[socket.LocalPort] {
+			summary.Inbound++
+		} else {
+			summary.Outbound++
+			ports[socket.RemotePort]++
+		}
+
+		if owner, ok := owners[socket.Inode]; ok {
+			process, ok := processes[owner.PID]
+			if !ok {
+				process = &ConnectionProcess{PID: owner.PID, Process: owner.Name, Exe: owner.Exe}
+				processes[owner.PID] = process
+			}
+			process.Count++
+		}
+	}
+
+	for address, count := range remotes {
+		summary.TopRemotes = append(summary.TopRemotes, RemoteCount{Address: address, Count: count})
+	}
+	sort.Slice(summary.TopRemotes, func(i, j int) bool {
+		a, b := summary.TopRemotes[i], summary.TopRemotes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Address < b.Address
+	})
+	if len(summary.TopRemotes) > c.topN {
+		summary.TopRemotes = summary.TopRemotes[:c.topN]
+	}
+
+	for port, count := range ports {
+		summary.TopPorts = append(summary.TopPorts, PortCount{Port: port, Count: count})
+	}
+	sort.Slice(summary.TopPorts, func(i, j int) bool {
+		a, b := summary.TopPorts[i], summary.TopPorts[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Port < b.Port
+	})
+	if len(summary.TopPorts) > c.topN {
+		summary.TopPorts = summary.TopPorts[:c.topN]
+	}
+
+	for _, process := range processes {
+		summary.Processes = append(summary.Processes, *process)
+	}
+	sort.Slice(summary.Processes, func(i, j int) bool {
+		a, b := summary.Processes[i], summary.Processes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.PID < b.PID
+	})
+	if len(summary.Processes) > c.topN {
+		summary.Processes = summary.Processes[:c.topN]
+	}
+}
+
+// newThreatMatch builds the event of a connection matching the threat-intel list
+func newThreatMatch(socket netSocket, entry *threatEntry, listening map[int]bool,
+	owner socketProcess, now time.Time) ThreatMatch {
+	threat := ThreatMatch{
+		Time:       now,
+		Event:      "threat_intel_match",
+		Severity:   "high",
+		Direction:  "outbound",
+		State:      tcpStateName(socket.State),
+		LocalIP:    socket.LocalIP.String(),
+		LocalPort:  socket.LocalPort,
+		RemoteIP:   socket.RemoteIP.String(),
+		RemotePort: socket.RemotePort,
+		Match:      entry.Entry,
+		Label:      entry.Label,
+		PID:        owner.PID,
+		Process:    owner.Name,
+		Exe:        owner.Exe,
+	}
+	if listening[socket.LocalPort] {
+		threat.Direction = "inbound"
+	}
+	return threat
+}
+
+// connectionKey identifies a TCP connection
+func connectionKey(socket netSocket) string {
+	return net.JoinHostPort(socket.LocalIP.String(), strconv.Itoa(socket.LocalPort)) + " " +
+		net.JoinHostPort(socket.RemoteIP.String(), strconv.Itoa(socket.RemotePort))
+}
+
+// tcpStateName returns the name of a /proc/net/tcp state
+func tcpStateName(state int) string {
+	if name, ok := tcpStateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN_%02X", state)
+}
@@ -0,0 +1,273 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// cpuInitialSample is how long the first collection samples /proc/stat
+// when there is no previous sample to compare with
+const cpuInitialSample = time.Second
+
+// CPUUsage is the share of time a CPU spent in each mode, in percent
+type CPUUsage struct {
+	CPU     string  `json:"cpu"` // "cpu" for the total, "cpu0", "cpu1", ... per core
+	User    float64 `json:"user"`
+	Nice    float64 `json:"nice"`
+	System  float64 `json:"system"`
+	Idle    float64 `json:"idle"`
+	IOWait  float64 `json:"iowait"`
+	IRQ     float64 `json:"irq"`
+	SoftIRQ float64 `json:"softirq"`
+	Steal   float64 `json:"steal"`
+	Busy    float64 `json:"busy"` // everything but idle and iowait
+}
+
+// CPUStats is the CPU utilization between two collections
+type CPUStats struct {
+	Interval        float64    `json:"interval"` // seconds covered by the sample
+	Total           CPUUsage   `json:"total"`
+	Cores           []CPUUsage `json:"cores"`
+	ContextSwitches uint64     `json:"context_switches"` // during the interval
+	ContextRate     float64    `json:"context_rate"`     // per second
+	Forks           uint64     `json:"forks"`            // processes created during the interval
+	ForkRate        float64    `json:"fork_rate"`        // per second
+	ProcsRunning    int        `json:"procs_running"`
+	ProcsBlocked    int        `json:"procs_blocked"`
+}
+
+// cpuTimes are the jiffies counters of one /proc/stat cpu line
+type cpuTimes struct {
+	User, Nice, System, Idle, IOWait, IRQ, SoftIRQ, Steal uint64
+}
+
+// total returns the sum of all counters
+// guest and guest_nice are already included in user and nice
+func (t cpuTimes) total() uint64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+// procStat is a parsed /proc/stat snapshot
+type procStat struct {
+	Time         time.Time
+	CPUs         map[string]cpuTimes
+	Order        []string // cpu line order, "cpu" first
+	Ctxt         uint64
+	Processes    uint64
+	BootTime     int64 // seconds since the epoch
+	ProcsRunning int
+	ProcsBlocked int
+}
+
+// CPUCollector computes CPU utilization from /proc/stat deltas
+type CPUCollector struct {
+	BaseCollector
+	statPath  string
+	committed *procStat // sample of the last delivered report
+	current   *procStat // sample of the last Collect
+	mu        sync.Mutex
+}
+
+// NewCPUCollector creates a new CPU collector
+func NewCPUCollector() *CPUCollector {
+	return &CPUCollector{
+		BaseCollector: BaseCollector{name: "cpu"},
+		statPath:      "/proc/stat",
+	}
+}
+
+// Collect returns the CPU utilization since the last delivered report
+// On the first call a short sample is taken instead
+func (c *CPUCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Collecting CPU utilization")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.committed
+	if previous == nil {
+		sample, err := c.readStat()
+		if err != nil {
+			return nil, err
+		}
+		previous = sample
+		time.Sleep(cpuInitialSample)
+	}
+
+	current, err := c.readStat()
+	if err != nil {
+		return nil, err
+	}
+	c.current = current
+
+	stats := cpuDelta(previous, current)
+	logger.Info("CPU: %.1f%% busy, %.1f%% iowait, %.1f%% steal over %.0fs",
+		stats.Total.Busy, stats.Total.IOWait, stats.Total.Steal, stats.Interval)
+	return stats, nil
+}
+
+// Commit makes the last sample the baseline of the next collection
+func (c *CPUCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil {
+		c.committed = c.current
+	}
+	return nil
+}
+
+// readStat reads and parses /proc/stat
+func (c *CPUCollector) readStat() (*procStat, error) {
+	file, err := os.Open(c.statPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", c.statPath, err)
+	}
+	defer file.Close()
+
+	stat, err := parseProcStat(file)
+	if err != nil {
+		return nil, err
+	}
+	stat.Time = time.Now()
+	return stat, nil
+}
+
+// parseProcStat parses the cpu, ctxt, btime, processes and procs_* lines of /proc/stat
+func parseProcStat(r io.Reader) (*procStat, error) {
+	stat := &procStat{CPUs: make(map[string]cpuTimes)}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // the intr line is long
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(fields[0], "cpu"):
+			values := make([]uint64, 8)
+			for i := 0; i < len(values) && i+1 < len(fields); i++ {
+				values[i], _ = strconv.ParseUint(fields[i+1], 10, 64)
+			}
+			stat.CPUs[fields[0]] = cpuTimes{
+				User:    values[0],
+				Nice:    values[1],
+				System:  values[2],
+				Idle:    values[3],
+				IOWait:  values[4],
+				IRQ:     values[5],
+				SoftIRQ: values[6],
+				Steal:   values[7],
+			}
+			stat.Order = append(stat.Order, fields[0])
+		case fields[0] == "ctxt":
+			stat.Ctxt, _ = strconv.ParseUint(fields[1], 10, 64)
+		case fields[0] == "processes":
+			stat.Processes, _ = strconv.ParseUint(fields[1], 10, 64)
+		case fields[0] == "btime":
+			stat.BootTime, _ = strconv.ParseInt(fields[1], 10, 64)
+		case fields[0] == "procs_running":
+			stat.ProcsRunning, _ = strconv.Atoi(fields[1])
+		case fields[0] == "procs_blocked":
+			stat.ProcsBlocked, _ = strconv.Atoi(fields[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse /proc/stat: %w", err)
+	}
+
+	if _, ok := stat.CPUs["cpu"]; !ok {
+		return nil, fmt.Errorf("no cpu line in /proc/stat")
+	}
+
+	return stat, nil
+}
+
+// cpuDelta computes the utilization between two snapshots
+// Cores that went offline in between are left out
+func cpuDelta(previous, current *procStat) *CPUStats {
+	interval := current.Time.Sub(previous.Time).Seconds()
+
+	stats := &CPUStats{
+		Interval:        interval,
+		Cores:           make([]CPUUsage, 0, len(current.Order)),
+		ContextSwitches: counterDelta(previous.Ctxt, current.Ctxt),
+		Forks:           counterDelta(previous.Processes, current.Processes),
+		ProcsRunning:    current.ProcsRunning,
+		ProcsBlocked:    current.ProcsBlocked,
+	}
+
+	if interval > 0 {
+		stats.ContextRate = float64(stats.ContextSwitches) / interval
+		stats.ForkRate = float64(stats.Forks) / interval
+	}
+
+	for _, name := range current.Order {
+		before, ok := previous.CPUs[name]
+		if !ok {
+			continue
+		}
+
+		usage := cpuUsage(name, before, current.CPUs[name])
+		if name == "cpu" {
+			stats.Total = usage
+		} else {
+			stats.Cores = append(stats.Cores, usage)
+		}
+	}
+
+	return stats
+}
+
+// cpuUsage converts two counter snapshots of one CPU into percentages
+func cpuUsage(name string, before, after cpuTimes) CPUUsage {
+	usage := CPUUsage{CPU: name}
+
+	total := counterDelta(before.total(), after.total())
+	if total == 0 {
+		return usage
+	}
+
+	percent := func(b, a uint64) float64 {
+		return float64(counterDelta(b, a)) * 100 / float64(total)
+	}
+
+	usage.User = percent(before.User, after.User)
+	usage.Nice = percent(before.Nice, after.Nice)
+	usage.System = percent(before.System, after.System)
+	usage.Idle = percent(before.Idle, after.Idle)
+	usage.IOWait = percent(before.IOWait, after.IOWait)
+	usage.IRQ = percent(before.IRQ, after.IRQ)
+	usage.SoftIRQ = percent(before.SoftIRQ, after.SoftIRQ)
+	usage.Steal = percent(before.Steal, after.Steal)
+	usage.Busy = 100 - usage.Idle - usage.IOWait
+	if usage.Busy < 0 {
+		usage.Busy = 0
+	}
+
+	return usage
+}
+
+// counterDelta returns after - before, or 0 if the counter went backwards
+// (iowait is known to decrease on some kernels, and CPU hotplug resets counters)
+func counterDelta(before, after uint64) uint64 {
+	if after < before {
+		return 0
+	}
+	return after - before
+}
@@ -0,0 +1,258 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// diskSectorSize is the unit of the /proc/diskstats sector counters,
+// always 512 bytes whatever the device's real sector size
+const diskSectorSize = 512
+
+// DiskConfig selects the block devices reported by DiskCollector
+// By default only whole disks are reported (sda, nvme0n1, vda, md0, ...)
+type DiskConfig struct {
+	Partitions   bool     // also report partitions (sda1, nvme0n1p1)
+	DeviceMapper bool     // also report device-mapper volumes (LVM, dm-crypt)
+	Loop         bool     // also report loop, ram and zram devices
+	Include      []string // if set, only report device names matching one of these globs
+	Exclude      []string // skip device names matching one of these globs
+}
+
+// DiskIO is the I/O activity of one block device between two samples
+type DiskIO struct {
+	Device         string  `json:"device"`
+	Name           string  `json:"name"`             // device-mapper name (vg-lv), empty otherwise
+	ReadBytesRate  float64 `json:"read_bytes_rate"`  // bytes per second
+	WriteBytesRate float64 `json:"write_bytes_rate"` // bytes per second
+	ReadIOPS       float64 `json:"read_iops"`
+	WriteIOPS      float64 `json:"write_iops"`
+	ReadAwait      float64 `json:"read_await"`  // ms per completed read
+	WriteAwait     float64 `json:"write_await"` // ms per completed write
+	Await          float64 `json:"await"`       // ms per completed I/O
+	Utilization    float64 `json:"utilization"` // percent of time the device was busy
+	InProgress     uint64  `json:"in_progress"` // I/Os in flight at the end of the sample
+}
+
+// DiskStats is the I/O activity of all selected devices
+type DiskStats struct {
+	Interval float64  `json:"interval"` // seconds covered by the sample
+	Devices  []DiskIO `json:"devices"`
+}
+
+// diskCounters are the /proc/diskstats counters of one device
+type diskCounters struct {
+	Reads, ReadSectors, ReadTicks    uint64
+	Writes, WriteSectors, WriteTicks uint64
+	InProgress, IOTicks              uint64
+}
+
+// diskSample is a /proc/diskstats snapshot
+type diskSample struct {
+	Time    time.Time
+	Devices map[string]diskCounters
+	Order   []string
+}
+
+// DiskCollector computes block device I/O statistics from /proc/diskstats deltas
+type DiskCollector struct {
+	BaseCollector
+	diskstatsPath string
+	sysBlockPath  string
+	config        DiskConfig
+	committed     *diskSample // sample of the last delivered report
+	current       *diskSample // sample of the last Collect
+	mu            sync.Mutex
+}
+
+// NewDiskCollector creates a new disk I/O collector
+func NewDiskCollector(config DiskConfig) *DiskCollector {
+	return &DiskCollector{
+		BaseCollector: BaseCollector{name: "disk"},
+		diskstatsPath: "/proc/diskstats",
+		sysBlockPath:  "/sys/class/block",
+		config:        config,
+	}
+}
+
+// Collect returns the I/O statistics since the last delivered report
+// The first call only takes the baseline sample and reports nothing
+func (c *DiskCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Collecting disk I/O statistics")
+
+	file, err := os.Open(c.diskstatsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", c.diskstatsPath, err)
+	}
+	sample, err := parseDiskstats(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	sample.Time = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current = sample
+	if c.committed == nil {
+		logger.Info("No disk I/O baseline yet, skipping")
+		return nil, nil
+	}
+
+	interval := sample.Time.Sub(c.committed.Time).Seconds()
+	if interval <= 0 {
+		return nil, nil
+	}
+
+	stats := &DiskStats{
+		Interval: interval,
+		Devices:  make([]DiskIO, 0),
+	}
+
+	for _, device := range sample.Order {
+		before, ok := c.committed.Devices[device]
+		if !ok || !c.wantDevice(device) {
+			continue
+		}
+
+		deviceIO := diskDelta(before, sample.Devices[device], interval)
+		deviceIO.Device = device
+		deviceIO.Name = c.deviceMapperName(device)
+		stats.Devices = append(stats.Devices, deviceIO)
+	}
+
+	logger.Info(fmt.Sprintf("Disk I/O: %d devices over %.0fs", len(stats.Devices), interval))
+	return stats, nil
+}
+
+// Commit makes the last sample the baseline of the next collection
+func (c *DiskCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil {
+		c.committed = c.current
+	}
+	return nil
+}
+
+// wantDevice applies the device type and name filters
+func (c *DiskCollector) wantDevice(device string) bool {
+	if matchesAnyGlob(c.config.Exclude, device) {
+		return false
+	}
+	if len(c.config.Include) > 0 {
+		return matchesAnyGlob(c.config.Include, device)
+	}
+
+	switch {
+	case strings.HasPrefix(device, "dm-"):
+		return c.config.DeviceMapper
+	case strings.HasPrefix(device, "loop"), strings.HasPrefix(device, "ram"),
+		strings.HasPrefix(device, "zram"):
+		return c.config.Loop
+	case c.isPartition(device):
+		return c.config.Partitions
+	}
+
+	return true
+}
+
+// isPartition reports whether the device is a partition of another disk
+func (c *DiskCollector) isPartition(device string) bool {
+	_, err := os.Stat(filepath.Join(c.sysBlockPath, device, "partition"))
+	return err == nil
+}
+
+// deviceMapperName returns the name of a device-mapper device (vg-lv)
+func (c *DiskCollector) deviceMapperName(device string) string {
+	if !strings.HasPrefix(device, "dm-") {
+		return ""
+	}
+	name, err := os.ReadFile(filepath.Join(c.sysBlockPath, device, "dm", "name"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(name))
+}
+
+// parseDiskstats parses /proc/diskstats:
+// "8 0 sda 4512 1234 123456 2345 6789 4321 654321 9876 0 5432 12221 ..."
+func parseDiskstats(r io.Reader) (*diskSample, error) {
+	sample := &diskSample{Devices: make(map[string]diskCounters)}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+
+		values := make([]uint64, 11)
+		for i := range values {
+			values[i], _ = strconv.ParseUint(fields[i+3], 10, 64)
+		}
+
+		name := fields[2]
+		sample.Devices[name] = diskCounters{
+			Reads:        values[0],
+			ReadSectors:  values[2],
+			ReadTicks:    values[3],
+			Writes:       values[4],
+			WriteSectors: values[6],
+			WriteTicks:   values[7],
+			InProgress:   values[8],
+			IOTicks:      values[9],
+		}
+		sample.Order = append(sample.Order, name)
+	}
+
+	return sample, scanner.Err()
+}
+
+// diskDelta computes the rates of one device between two samples
+func diskDelta(before, after diskCounters, interval float64) DiskIO {
+	reads := counterDelta(before.Reads, after.Reads)
+	writes := counterDelta(before.Writes, after.Writes)
+	readTicks := counterDelta(before.ReadTicks, after.ReadTicks)
+	writeTicks := counterDelta(before.WriteTicks, after.WriteTicks)
+
+	deviceIO := DiskIO{
+		ReadBytesRate:  float64(counterDelta(before.ReadSectors, after.ReadSectors)*diskSectorSize) / interval,
+		WriteBytesRate: float64(counterDelta(before.WriteSectors, after.WriteSectors)*diskSectorSize) / interval,
+		ReadIOPS:       float64(reads) / interval,
+		WriteIOPS:      float64(writes) / interval,
+		Utilization:    float64(counterDelta(before.IOTicks, after.IOTicks)) * 100 / (interval * 1000),
+		InProgress:     after.InProgress,
+	}
+
+	if reads > 0 {
+		deviceIO.ReadAwait = float64(readTicks) / float64(reads)
+	}
+	if writes > 0 {
+		deviceIO.WriteAwait = float64(writeTicks) / float64(writes)
+	}
+	if reads+writes > 0 {
+		deviceIO.Await = float64(readTicks+writeTicks) / float64(reads+writes)
+	}
+	if deviceIO.Utilization > 100 {
+		deviceIO.Utilization = 100
+	}
+
+	return deviceIO
+}
@@ -0,0 +1,282 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// readDiskstatsFixture parses testdata/diskstats/<name>
+func readDiskstatsFixture(t *testing.T, name string) *diskSample {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", "diskstats", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	sample, err := parseDiskstats(file)
+	if err != nil {
+		t.Fatalf("parseDiskstats() error = %v", err)
+	}
+	return sample
+}
+
+// newSysBlock creates a fake /sys/class/block with partitions and
+// device-mapper names
+func newSysBlock(t *testing.T, partitions []string, dmNames map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, partition := range partitions {
+		if err := os.MkdirAll(filepath.Join(dir, partition), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, partition, "partition"), []byte("1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for device, name := range dmNames {
+		if err := os.MkdirAll(filepath.Join(dir, device, "dm"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, device, "dm", "name"), []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseDiskstats(t *testing.T) {
+	sample := readDiskstatsFixture(t, "before")
+
+	// sdb1 has the 4 counters of old kernels' partition lines and is skipped
+	wantOrder := []string{"sda", "sda1", "nvme0n1", "nvme0n1p1", "dm-0", "loop0", "zram0", "md0", "sdb"}
+	if !reflect.DeepEqual(sample.Order, wantOrder) {
+		t.Fatalf("Order = %v, want %v", sample.Order, wantOrder)
+	}
+
+	tests := []struct {
+		device string
+		want   diskCounters
+	}{
+		{
+			// 17 fields, with discard and flush counters
+			device: "sda",
+			want: diskCounters{Reads: 1000, ReadSectors: 80000, ReadTicks: 2000, Writes: 500, WriteSectors: 40000,
+				WriteTicks: 3000, InProgress: 0, IOTicks: 2500},
+		},
+		{
+			device: "nvme0n1",
+			want: diskCounters{Reads: 5000, ReadSectors: 400000, ReadTicks: 1000, Writes: 10000, WriteSectors: 800000,
+				WriteTicks: 20000, InProgress: 2, IOTicks: 9000},
+		},
+		{
+			// 11 counters, as before kernel 4.18
+			device: "md0",
+			want:   diskCounters{Reads: 300, ReadSectors: 2400, Writes: 200, WriteSectors: 1600},
+		},
+		{
+			device: "sdb",
+			want: diskCounters{Reads: 4294967200, ReadSectors: 100, ReadTicks: 10, Writes: 100, WriteSectors: 800,
+				WriteTicks: 50, IOTicks: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.device, func(t *testing.T) {
+			if got := sample.Devices[tt.device]; got != tt.want {
+				t.Errorf("Devices[%s] = %+v, want %+v", tt.device, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiskDelta(t *testing.T) {
+	before := readDiskstatsFixture(t, "before")
+	after := readDiskstatsFixture(t, "after")
+	const interval = 10.0
+
+	tests := []struct {
+		name   string
+		device string
+		want   DiskIO
+	}{
+		{
+			name:   "reads and writes",
+			device: "sda",
+			want: DiskIO{
+				ReadBytesRate:  16000 * diskSectorSize / interval,
+				WriteBytesRate: 8000 * diskSectorSize / interval,
+				ReadIOPS:       20,
+				WriteIOPS:      10,
+				ReadAwait:      3, // 600ms over 200 reads
+				WriteAwait:     8, // 800ms over 100 writes
+				Await:          float64(1400) / 300,
+				Utilization:    25, // 2500ms busy in 10s
+				InProgress:     1,
+			},
+		},
+		{
+			name:   "utilization capped",
+			device: "nvme0n1",
+			want: DiskIO{
+				WriteBytesRate: 200000 * diskSectorSize / interval,
+				WriteIOPS:      200,
+				WriteAwait:     2,
+				Await:          2,
+				Utilization:    100, // 11500ms busy in 10s
+				InProgress:     3,
+			},
+		},
+		{
+			name:   "idle",
+			device: "loop0",
+			want:   DiskIO{},
+		},
+		{
+			// The 32-bit reads counter wrapped: counted as no reads rather
+			// than four billion, and the read ticks have no reads to divide
+			name:   "counter wrap",
+			device: "sdb",
+			want: DiskIO{
+				ReadBytesRate:  80 * diskSectorSize / interval,
+				WriteBytesRate: 80 * diskSectorSize / interval,
+				WriteIOPS:      1,
+				WriteAwait:     2,
+				Await:          float64(22) / 10,
+				Utilization:    0.5,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diskDelta(before.Devices[tt.device], after.Devices[tt.device], interval)
+			if got != tt.want {
+				t.Errorf("diskDelta(%s) = %+v, want %+v", tt.device, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWantDevice(t *testing.T) {
+	sysBlock := newSysBlock(t, []string{"sda1", "nvme0n1p1", "sdb1"}, nil)
+
+	tests := []struct {
+		name   string
+		config DiskConfig
+		want   []string
+	}{
+		{
+			name:   "whole disks by default",
+			config: DiskConfig{},
+			want:   []string{"sda", "nvme0n1", "md0", "sdb"},
+		},
+		{
+			name:   "partitions",
+			config: DiskConfig{Partitions: true},
+			want:   []string{"sda", "sda1", "nvme0n1", "nvme0n1p1", "md0", "sdb"},
+		},
+		{
+			name:   "device mapper",
+			config: DiskConfig{DeviceMapper: true},
+			want:   []string{"sda", "nvme0n1", "dm-0", "md0", "sdb"},
+		},
+		{
+			name:   "loop, ram and zram",
+			config: DiskConfig{Loop: true},
+			want:   []string{"sda", "nvme0n1", "loop0", "zram0", "md0", "sdb"},
+		},
+		{
+			name:   "exclude",
+			config: DiskConfig{Partitions: true, Exclude: []string{"sd*"}},
+			want:   []string{"nvme0n1", "nvme0n1p1", "md0"},
+		},
+		{
+			// An include list replaces the type filters
+			name:   "include",
+			config: DiskConfig{Include: []string{"sda*", "dm-*", "loop*"}},
+			want:   []string{"sda", "sda1", "dm-0", "loop0"},
+		},
+		{
+			name:   "exclude wins over include",
+			config: DiskConfig{Include: []string{"sd*"}, Exclude: []string{"sda1"}},
+			want:   []string{"sda", "sdb"},
+		},
+	}
+
+	devices := readDiskstatsFixture(t, "before").Order
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &DiskCollector{sysBlockPath: sysBlock, config: tt.config}
+
+			got := make([]string, 0)
+			for _, device := range devices {
+				if c.wantDevice(device) {
+					got = append(got, device)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wantDevice() selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiskCollectorCollect(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("disk I/O collection is Linux only")
+	}
+
+	dir := t.TempDir()
+	diskstats := filepath.Join(dir, "diskstats")
+
+	c := NewDiskCollector(DiskConfig{DeviceMapper: true})
+	c.diskstatsPath = diskstats
+	c.sysBlockPath = newSysBlock(t, []string{"sda1", "nvme0n1p1"}, map[string]string{"dm-0": "vg0-root"})
+
+	copyFixture := func(name string) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join("testdata", "diskstats", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(diskstats, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copyFixture("before")
+	if data, err := c.Collect(); err != nil || data != nil {
+		t.Fatalf("first Collect() = %v, %v, want only a baseline", data, err)
+	}
+	if err := c.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	c.committed.Time = c.committed.Time.Add(-10 * time.Second)
+
+	copyFixture("after")
+	data, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	stats := data.(*DiskStats)
+
+	// sdc appeared since the baseline and has nothing to compare with
+	got := make([]string, 0)
+	for _, device := range stats.Devices {
+		got = append(got, device.Device+"="+device.Name)
+	}
+	want := []string{"sda=", "nvme0n1=", "dm-0=vg0-root", "md0=", "sdb="}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Collect() devices = %v, want %v", got, want)
+	}
+	if stats.Interval < 10 {
+		t.Errorf("Interval = %v, want at least 10", stats.Interval)
+	}
+}
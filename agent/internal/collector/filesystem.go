@@ -0,0 +1,363 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+// statfsTimeout bounds the statfs call of one mount, a dead NFS server
+// would otherwise block the whole collection
+const statfsTimeout = 5 * time.Second
+
+// pseudoFilesystems are the filesystem types skipped by default
+var pseudoFilesystems = []string{
+	"autofs", "binfmt_misc", "bpf", "cgroup", "cgroup2", "configfs", "debugfs",
+	"devpts", "devtmpfs", "efivarfs", "fuse.gvfsd-fuse", "fuse.lxcfs", "fusectl",
+	"hugetlbfs", "mqueue", "nsfs", "overlay", "proc", "pstore", "ramfs",
+	"rpc_pipefs", "securityfs", "selinuxfs", "squashfs", "sysfs", "tmpfs",
+	"tracefs", "nfsd",
+}
+
+// FilesystemConfig selects the mounts reported by FilesystemCollector
+type FilesystemConfig struct {
+	IncludeTypes  []string // report these types even if they are pseudo filesystems
+	ExcludeTypes  []string // skip these types in addition to the pseudo filesystems
+	IncludeMounts []string // if set, only report mount points matching one of these globs
+	ExcludeMounts []string // skip mount points matching one of these globs
+}
+
+// FilesystemUsage is the space and inode usage of one mounted filesystem
+type FilesystemUsage struct {
+	Device            string  `json:"device"`
+	MountPoint        string  `json:"mount_point"`
+	FSType            string  `json:"fs_type"`
+	Total             uint64  `json:"total"` // bytes
+	Used              uint64  `json:"used"`
+	Free              uint64  `json:"free"`         // including the blocks reserved for root
+	Available         uint64  `json:"available"`    // for unprivileged users
+	UsedPercent       float64 `json:"used_percent"` // like df: used / (used + available)
+	Inodes            uint64  `json:"inodes"`
+	InodesUsed        uint64  `json:"inodes_used"`
+	InodesFree        uint64  `json:"inodes_free"`
+	InodesUsedPercent float64 `json:"inodes_used_percent"`
+	ReadOnly          bool    `json:"read_only"`
+	RemountedRO       bool    `json:"remounted_ro"` // was read-write at the previous collection
+}
+
+// mountInfo is one line of /proc/self/mountinfo
+type mountInfo struct {
+	Device     string // major:minor
+	Root       string
+	MountPoint string
+	Options    string
+	FSType     string
+	Source     string
+	SuperOpts  string
+}
+
+// readOnly reports whether the mount or its superblock is read-only
+func (m mountInfo) readOnly() bool {
+	return hasOption(m.Options, "ro") || hasOption(m.SuperOpts, "ro")
+}
+
+// filesystemState remembers which mounts were read-write, to notice
+// read-only remounts after filesystem errors
+type filesystemState struct {
+	ReadWrite map[string]bool `json:"read_write"` // mount point -> was read-write
+}
+
+// FilesystemCollector reports usage of mounted filesystems
+type FilesystemCollector struct {
+	BaseCollector
+	mountinfoPath string
+	config        FilesystemConfig
+	statePath     string
+	committed     filesystemState
+	working       filesystemState
+	mu            sync.Mutex
+
+	// Mounts whose statfs is still blocked after timing out, a hung
+	// network filesystem is skipped until that call returns
+	blocked   map[string]bool
+	blockedMu sync.Mutex
+}
+
+// NewFilesystemCollector creates a new filesystem collector
+func NewFilesystemCollector(stateDir string, config FilesystemConfig) *FilesystemCollector {
+	c := &FilesystemCollector{
+		BaseCollector: BaseCollector{name: "filesystem"},
+		mountinfoPath: "/proc/self/mountinfo",
+		config:        config,
+		blocked:       make(map[string]bool),
+		statePath:     statePath(stateDir, "filesystems.json"),
+	}
+
+	if err := state.Load(c.statePath, &c.committed); err != nil {
+		logger.Warn("Failed to load filesystem state, starting fresh: " + err.Error())
+	}
+	if c.committed.ReadWrite == nil {
+		c.committed.ReadWrite = make(map[string]bool)
+	}
+
+	return c
+}
+
+// Collect collects the usage of every selected mount
+func (c *FilesystemCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Collecting filesystem usage")
+
+	file, err := os.Open(c.mountinfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", c.mountinfoPath, err)
+	}
+	mounts, err := parseMountinfo(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.working = filesystemState{ReadWrite: make(map[string]bool)}
+
+	usages := make([]FilesystemUsage, 0)
+	for _, mount := range c.selectMounts(mounts) {
+		usage, err := c.statfsWithTimeout(mount.MountPoint, statfsTimeout)
+		if err != nil {
+			logger.Warn("Failed to stat filesystem " + mount.MountPoint + ": " + err.Error())
+			if rw, ok := c.committed.ReadWrite[mount.MountPoint]; ok {
+				c.working.ReadWrite[mount.MountPoint] = rw
+			}
+			continue
+		}
+		if usage.Total == 0 {
+			continue
+		}
+
+		usage.Device = mount.Source
+		usage.MountPoint = mount.MountPoint
+		usage.FSType = mount.FSType
+		usage.ReadOnly = mount.readOnly()
+
+		if usage.ReadOnly && c.committed.ReadWrite[mount.MountPoint] {
+			usage.RemountedRO = true
+			logger.Warn("Filesystem " + mount.MountPoint + " was remounted read-only")
+		}
+		c.working.ReadWrite[mount.MountPoint] = !usage.ReadOnly
+
+		usages = append(usages, *usage)
+	}
+
+	logger.Info("Found " + fmt.Sprint(len(usages)) + " filesystems")
+	return usages, nil
+}
+
+// Commit remembers the read-write state of the last collection
+func (c *FilesystemCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.working.ReadWrite == nil {
+		return nil
+	}
+	c.committed = c.working
+	return state.Save(c.statePath, c.committed)
+}
+
+// selectMounts applies the type and mount point filters
+// Bind mounts and overmounts of the same filesystem are reported once,
+// under the shortest mount point
+func (c *FilesystemCollector) selectMounts(mounts []mountInfo) []mountInfo {
+	byDevice := make(map[string]int)
+	selected := make([]mountInfo, 0, len(mounts))
+
+	for _, mount := range mounts {
+		if !c.wantType(mount.FSType) || !c.wantMount(mount.MountPoint) {
+			continue
+		}
+
+		if i, ok := byDevice[mount.Device]; ok {
+			if len(mount.MountPoint) < len(selected[i].MountPoint) {
+				selected[i] = mount
+			}
+			continue
+		}
+
+		byDevice[mount.Device] = len(selected)
+		selected = append(selected, mount)
+	}
+
+	return selected
+}
+
+// wantType reports whether mounts of fsType should be reported
+func (c *FilesystemCollector) wantType(fsType string) bool {
+	if containsString(c.config.IncludeTypes, fsType) {
+		return true
+	}
+	return !containsString(c.config.ExcludeTypes, fsType) && !containsString(pseudoFilesystems, fsType)
+}
+
+// wantMount reports whether a mount point passes the include/exclude globs
+func (c *FilesystemCollector) wantMount(mountPoint string) bool {
+	if matchesAnyGlob(c.config.ExcludeMounts, mountPoint) {
+		return false
+	}
+	return len(c.config.IncludeMounts) == 0 || matchesAnyGlob(c.config.IncludeMounts, mountPoint)
+}
+
+// parseMountinfo parses /proc/self/mountinfo:
+// "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue"
+func parseMountinfo(r io.Reader) ([]mountInfo, error) {
+	mounts := make([]mountInfo, 0)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		// The optional fields end with a lone "-"
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || len(fields) < sep+3 {
+			continue
+		}
+
+		mount := mountInfo{
+			Device:     fields[2],
+			Root:       unescapeMountField(fields[3]),
+			MountPoint: unescapeMountField(fields[4]),
+			Options:    fields[5],
+			FSType:     fields[sep+1],
+			Source:     unescapeMountField(fields[sep+2]),
+		}
+		if len(fields) > sep+3 {
+			mount.SuperOpts = fields[sep+3]
+		}
+		mounts = append(mounts, mount)
+	}
+
+	return mounts, scanner.Err()
+}
+
+// unescapeMountField decodes the octal escapes (\040 for space) of mountinfo
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// hasOption reports whether a comma separated option list contains option
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnyGlob reports whether path matches one of the glob patterns
+func matchesAnyGlob(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// statfsWithTimeout runs statfs in a goroutine and gives up after timeout
+// A call that timed out cannot be cancelled, so the mount is marked blocked
+// until it returns and is not statted again meanwhile: a hung mount costs
+// one goroutine rather than one per collection
+func (c *FilesystemCollector) statfsWithTimeout(path string, timeout time.Duration) (*FilesystemUsage, error) {
+	type result struct {
+		usage *FilesystemUsage
+		err   error
+	}
+
+	c.blockedMu.Lock()
+	if c.blocked[path] {
+		c.blockedMu.Unlock()
+		return nil, fmt.Errorf("previous statfs still blocked")
+	}
+	c.blocked[path] = true
+	c.blockedMu.Unlock()
+
+	done := make(chan result, 1)
+	go func() {
+		usage, err := statfs(path)
+		c.blockedMu.Lock()
+		delete(c.blocked, path)
+		c.blockedMu.Unlock()
+		done <- result{usage, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.usage, r.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("statfs timed out after %v", timeout)
+	}
+}
+
+// usageFromCounts fills the usage figures from statfs block and inode counts
+func usageFromCounts(blockSize, blocks, bfree, bavail, files, ffree uint64) *FilesystemUsage {
+	usage := &FilesystemUsage{
+		Total:      blocks * blockSize,
+		Free:       bfree * blockSize,
+		Available:  bavail * blockSize,
+		Inodes:     files,
+		InodesFree: ffree,
+	}
+
+	if blocks >= bfree {
+		usage.Used = (blocks - bfree) * blockSize
+	}
+	if usage.Used+usage.Available > 0 {
+		usage.UsedPercent = float64(usage.Used) * 100 / float64(usage.Used+usage.Available)
+	}
+
+	if files >= ffree {
+		usage.InodesUsed = files - ffree
+	}
+	if files > 0 {
+		usage.InodesUsedPercent = float64(usage.InodesUsed) * 100 / float64(files)
+	}
+
+	return usage
+}
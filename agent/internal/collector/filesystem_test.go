@@ -0,0 +1,348 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// readMountinfoFixture parses testdata/mountinfo/host
+func readMountinfoFixture(t *testing.T) []mountInfo {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", "mountinfo", "host"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	mounts, err := parseMountinfo(file)
+	if err != nil {
+		t.Fatalf("parseMountinfo() error = %v", err)
+	}
+	return mounts
+}
+
+func TestParseMountinfo(t *testing.T) {
+	mounts := readMountinfoFixture(t)
+
+	// The malformed line and the line missing its source are skipped
+	if len(mounts) != 12 {
+		t.Fatalf("parseMountinfo() = %d mounts, want 12", len(mounts))
+	}
+
+	byMountPoint := make(map[string]mountInfo)
+	for _, mount := range mounts {
+		byMountPoint[mount.MountPoint] = mount
+	}
+
+	tests := []struct {
+		name         string
+		mountPoint   string
+		want         mountInfo
+		wantReadOnly bool
+	}{
+		{
+			name:       "no optional fields",
+			mountPoint: "/data",
+			want: mountInfo{Device: "253:3", Root: "/", MountPoint: "/data", Options: "rw,relatime",
+				FSType: "ext4", Source: "/dev/sdb1", SuperOpts: "ro,errors=remount-ro"},
+			wantReadOnly: true,
+		},
+		{
+			name:       "one optional field",
+			mountPoint: "/",
+			want: mountInfo{Device: "253:0", Root: "/", MountPoint: "/", Options: "rw,relatime",
+				FSType: "ext4", Source: "/dev/mapper/vg0-root", SuperOpts: "rw,errors=remount-ro"},
+		},
+		{
+			name:       "two optional fields",
+			mountPoint: "/var/lib/docker",
+			want: mountInfo{Device: "253:1", Root: "/", MountPoint: "/var/lib/docker", Options: "rw,relatime",
+				FSType: "xfs", Source: "/dev/mapper/vg0-docker", SuperOpts: "rw,attr2,inode64,noquota"},
+		},
+		{
+			name:       "octal escapes",
+			mountPoint: "/mnt/backup disk",
+			want: mountInfo{Device: "253:2", Root: "/", MountPoint: "/mnt/backup disk", Options: "ro,relatime",
+				FSType: "ext4", Source: `/dev/mapper/backup\disk`, SuperOpts: "rw"},
+			wantReadOnly: true,
+		},
+		{
+			name:       "bind mount root",
+			mountPoint: "/var/www",
+			want: mountInfo{Device: "253:0", Root: "/srv/www", MountPoint: "/var/www", Options: "rw,relatime",
+				FSType: "ext4", Source: "/dev/mapper/vg0-root", SuperOpts: "rw,errors=remount-ro"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := byMountPoint[tt.mountPoint]
+			if !ok {
+				t.Fatalf("mount %q not parsed", tt.mountPoint)
+			}
+			if got != tt.want {
+				t.Errorf("mount = %+v, want %+v", got, tt.want)
+			}
+			if got.readOnly() != tt.wantReadOnly {
+				t.Errorf("readOnly() = %v, want %v", got.readOnly(), tt.wantReadOnly)
+			}
+		})
+	}
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "/mnt/plain", want: "/mnt/plain"},
+		{in: `/mnt/a\040b`, want: "/mnt/a b"},
+		{in: `/mnt/tab\011end\040`, want: "/mnt/tab\tend "},
+		{in: `/mnt/new\012line`, want: "/mnt/new\nline"},
+		{in: `/mnt/back\134slash`, want: `/mnt/back\slash`},
+		{in: `/mnt/not\9octal`, want: `/mnt/not\9octal`},
+		{in: `/mnt/short\04`, want: `/mnt/short\04`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := unescapeMountField(tt.in); got != tt.want {
+				t.Errorf("unescapeMountField(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectMounts(t *testing.T) {
+	mounts := readMountinfoFixture(t)
+
+	tests := []struct {
+		name   string
+		config FilesystemConfig
+		want   []string
+	}{
+		{
+			name:   "defaults",
+			config: FilesystemConfig{},
+			// /var/www is a bind mount of / and is reported under /
+			want: []string{"/", "/boot", "/var/lib/docker", "/mnt/backup disk", "/data", "/mnt/nfs"},
+		},
+		{
+			name:   "include type",
+			config: FilesystemConfig{IncludeTypes: []string{"tmpfs"}},
+			want:   []string{"/run", "/", "/boot", "/var/lib/docker", "/mnt/backup disk", "/data", "/mnt/nfs"},
+		},
+		{
+			name:   "exclude type",
+			config: FilesystemConfig{ExcludeTypes: []string{"nfs4", "xfs"}},
+			want:   []string{"/", "/boot", "/mnt/backup disk", "/data"},
+		},
+		{
+			name:   "include type wins over exclude type",
+			config: FilesystemConfig{IncludeTypes: []string{"xfs"}, ExcludeTypes: []string{"xfs"}},
+			want:   []string{"/", "/boot", "/var/lib/docker", "/mnt/backup disk", "/data", "/mnt/nfs"},
+		},
+		{
+			name:   "include mounts",
+			config: FilesystemConfig{IncludeMounts: []string{"/", "/mnt/*"}},
+			want:   []string{"/", "/mnt/backup disk", "/mnt/nfs"},
+		},
+		{
+			name:   "exclude mounts",
+			config: FilesystemConfig{ExcludeMounts: []string{"/mnt/*", "/var/lib/*"}},
+			want:   []string{"/", "/boot", "/data"},
+		},
+		{
+			name:   "exclude wins over include",
+			config: FilesystemConfig{IncludeMounts: []string{"/mnt/*"}, ExcludeMounts: []string{"/mnt/nfs"}},
+			want:   []string{"/mnt/backup disk"},
+		},
+		{
+			name:   "excluded shorter mount point leaves the bind mount",
+			config: FilesystemConfig{ExcludeMounts: []string{"/"}},
+			want:   []string{"/boot", "/var/lib/docker", "/mnt/backup disk", "/var/www", "/data", "/mnt/nfs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &FilesystemCollector{config: tt.config}
+
+			got := make([]string, 0)
+			for _, mount := range c.selectMounts(mounts) {
+				got = append(got, mount.MountPoint)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectMounts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectMountsShortestMountPoint(t *testing.T) {
+	// The longer mount point comes first, the shorter one replaces it
+	mounts := []mountInfo{
+		{Device: "8:1", MountPoint: "/srv/data/mirror", FSType: "ext4"},
+		{Device: "8:1", MountPoint: "/srv/data", FSType: "ext4"},
+		{Device: "8:1", MountPoint: "/srv/data/other", FSType: "ext4"},
+	}
+
+	selected := (&FilesystemCollector{}).selectMounts(mounts)
+	if len(selected) != 1 || selected[0].MountPoint != "/srv/data" {
+		t.Errorf("selectMounts() = %+v, want only /srv/data", selected)
+	}
+}
+
+func TestFilesystemCollectorRemountedRO(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("filesystem collection is Linux only")
+	}
+
+	dir := t.TempDir()
+	mountPoint := t.TempDir()
+	mountinfo := filepath.Join(dir, "mountinfo")
+
+	writeMountinfo := func(options string) {
+		t.Helper()
+		line := fmt.Sprintf("40 28 8:17 / %s %s,relatime shared:60 - ext4 /dev/sdb1 rw\n", mountPoint, options)
+		if err := os.WriteFile(mountinfo, []byte(line), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	collect := func(c *FilesystemCollector) FilesystemUsage {
+		t.Helper()
+		data, err := c.Collect()
+		if err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+		usages := data.([]FilesystemUsage)
+		if len(usages) != 1 {
+			t.Fatalf("Collect() = %d filesystems, want 1", len(usages))
+		}
+		return usages[0]
+	}
+
+	tests := []struct {
+		name string
+		// options of the mount at the first and the second collection
+		before, after   string
+		commit          bool
+		wantRemountedRO bool
+	}{
+		{name: "read-write to read-only", before: "rw", after: "ro", commit: true, wantRemountedRO: true},
+		{name: "read-only from the start", before: "ro", after: "ro", commit: true, wantRemountedRO: false},
+		{name: "read-write throughout", before: "rw", after: "rw", commit: true, wantRemountedRO: false},
+		{name: "first collection not committed", before: "rw", after: "ro", commit: false, wantRemountedRO: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateDir := t.TempDir()
+
+			c := NewFilesystemCollector(stateDir, FilesystemConfig{})
+			c.mountinfoPath = mountinfo
+			writeMountinfo(tt.before)
+			if usage := collect(c); usage.RemountedRO {
+				t.Fatal("first collection reported a remount")
+			}
+			if tt.commit {
+				if err := c.Commit(); err != nil {
+					t.Fatalf("Commit() error = %v", err)
+				}
+			}
+
+			// The state survives a restart
+			c = NewFilesystemCollector(stateDir, FilesystemConfig{})
+			c.mountinfoPath = mountinfo
+			writeMountinfo(tt.after)
+			usage := collect(c)
+			if usage.ReadOnly != (tt.after == "ro") {
+				t.Errorf("ReadOnly = %v with options %s", usage.ReadOnly, tt.after)
+			}
+			if usage.RemountedRO != tt.wantRemountedRO {
+				t.Errorf("RemountedRO = %v, want %v", usage.RemountedRO, tt.wantRemountedRO)
+			}
+		})
+	}
+}
+
+func TestUsageFromCounts(t *testing.T) {
+	tests := []struct {
+		name                                     string
+		blockSize, blocks, bfree, bavail         uint64
+		files, ffree                             uint64
+		wantUsed                                 uint64
+		wantUsedPercent, wantInodesUsedPercent   float64
+		wantInodesUsed, wantTotal, wantAvailable uint64
+	}{
+		{
+			name:      "reserved blocks",
+			blockSize: 4096, blocks: 1000, bfree: 500, bavail: 450,
+			files: 200, ffree: 150,
+			// like df: 500 used of the 950 usable blocks
+			wantTotal: 4096000, wantUsed: 2048000, wantAvailable: 1843200,
+			wantUsedPercent: 500.0 * 100 / 950,
+			wantInodesUsed:  50, wantInodesUsedPercent: 25,
+		},
+		{
+			name:      "full for users",
+			blockSize: 1024, blocks: 100, bfree: 5, bavail: 0,
+			files: 10, ffree: 0,
+			wantTotal: 102400, wantUsed: 97280, wantAvailable: 0,
+			wantUsedPercent: 100,
+			wantInodesUsed:  10, wantInodesUsedPercent: 100,
+		},
+		{
+			name:      "empty",
+			blockSize: 4096, blocks: 100, bfree: 100, bavail: 100,
+			files: 10, ffree: 10,
+			wantTotal: 409600, wantUsed: 0, wantAvailable: 409600,
+		},
+		{
+			name:      "more free than total",
+			blockSize: 4096, blocks: 100, bfree: 120, bavail: 120,
+			files: 10, ffree: 12,
+			wantTotal: 409600, wantUsed: 0, wantAvailable: 491520,
+		},
+		{
+			name:      "no inode counts",
+			blockSize: 4096, blocks: 100, bfree: 50, bavail: 50,
+			files: 0, ffree: 0,
+			wantTotal: 409600, wantUsed: 204800, wantAvailable: 204800,
+			wantUsedPercent: 50,
+		},
+		{
+			name:      "zero blocks",
+			blockSize: 4096,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := usageFromCounts(tt.blockSize, tt.blocks, tt.bfree, tt.bavail, tt.files, tt.ffree)
+			if got.Total != tt.wantTotal || got.Used != tt.wantUsed || got.Available != tt.wantAvailable {
+				t.Errorf("Total, Used, Available = %d, %d, %d, want %d, %d, %d",
+					got.Total, got.Used, got.Available, tt.wantTotal, tt.wantUsed, tt.wantAvailable)
+			}
+			if got.Free != tt.bfree*tt.blockSize {
+				t.Errorf("Free = %d, want %d", got.Free, tt.bfree*tt.blockSize)
+			}
+			if got.UsedPercent != tt.wantUsedPercent {
+				t.Errorf("UsedPercent = %v, want %v", got.UsedPercent, tt.wantUsedPercent)
+			}
+			if got.Inodes != tt.files || got.InodesFree != tt.ffree || got.InodesUsed != tt.wantInodesUsed {
+				t.Errorf("Inodes, InodesFree, InodesUsed = %d, %d, %d, want %d, %d, %d",
+					got.Inodes, got.InodesFree, got.InodesUsed, tt.files, tt.ffree, tt.wantInodesUsed)
+			}
+			if got.InodesUsedPercent != tt.wantInodesUsedPercent {
+				t.Errorf("InodesUsedPercent = %v, want %v", got.InodesUsedPercent, tt.wantInodesUsedPercent)
+			}
+		})
+	}
+}
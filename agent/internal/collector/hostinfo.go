@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/proxy"
 )
 
 // HostInfo represents host information
@@ -28,12 +30,20 @@ type HostInfoCollector struct {
 }
 
 // NewHostInfoCollector creates a new host info collector
-func NewHostInfoCollector() *HostInfoCollector {
+// The public IP lookups go through the same proxy as the reports
+func NewHostInfoCollector(proxyConfig proxy.Config) *HostInfoCollector {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if err := proxyConfig.Apply(transport); err != nil {
+		logger.Warn("Invalid proxy for public IP lookups, using the environment: " + err.Error())
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
 	return &HostInfoCollector{
 		BaseCollector: BaseCollector{name: "hostinfo"},
 		ipAPIEndpoint: "https://api.ipify.org",
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   10 * time.Second,
+			Transport: transport,
 		},
 	}
 }
@@ -88,14 +98,19 @@ func (c *HostInfoCollector) getPublicIP() (string, error) {
 			return ip, nil
 		}
 		lastErr = err
+
+		// Every service goes through the same proxy
+		if proxy.Is(err) {
+			return "", fmt.Errorf("proxy failure: %w", err)
+		}
 	}
 
 	return "", fmt.Errorf("all IP services failed: %w", lastErr)
 }
 
 // fetchIP fetches IP from a specific service
-func (c *HostInfoCollector) fetchIP(url string) (string, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (c *HostInfoCollector) fetchIP(serviceURL string) (string, error) {
+	req, err := http.NewRequest("GET", serviceURL, nil)
 	if err != nil {
 		return "", err
 	}
@@ -103,12 +118,21 @@ func (c *HostInfoCollector) fetchIP(url string) (string, error) {
 	// Set user agent
 	req.Header.Set("User-Agent", "ZenoGuard-Agent/1.0")
 
+	var proxyURL *url.URL
+	if transport, ok := c.client.Transport.(*http.Transport); ok && transport.Proxy != nil {
+		proxyURL, _ = transport.Proxy(req)
+	}
+
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", err
+		return "", proxy.Wrap(err, proxyURL)
 	}
 	defer resp.Body.Close()
 
+	if err := proxy.CheckResponse(resp, proxyURL); err != nil {
+		return "", err
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("HTTP status: %d", resp.StatusCode)
 	}
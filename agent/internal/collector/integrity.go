@@ -0,0 +1,589 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+// DefaultIntegrityMaxHashSize is the size above which files are not hashed
+const DefaultIntegrityMaxHashSize = 16 * 1024 * 1024
+
+// DefaultIntegrityPaths are the files watched by file integrity monitoring
+// "~/" is expanded to the home directory of every local account
+var DefaultIntegrityPaths = []string{
+	"/etc/passwd",
+	"/etc/shadow",
+	"/etc/group",
+	"/etc/gshadow",
+	"/etc/sudoers",
+	"/etc/sudoers.d/*",
+	"/etc/ssh/sshd_config",
+	"/etc/ssh/sshd_config.d/*",
+	"~/.ssh/authorized_keys",
+	"~/.ssh/authorized_keys2",
+	"/etc/crontab",
+	"/etc/cron.d/*",
+	"/etc/cron.hourly/*",
+	"/etc/cron.daily/*",
+	"/etc/cron.weekly/*",
+	"/etc/cron.monthly/*",
+	"/var/spool/cron/*",
+	"/var/spool/cron/crontabs/*",
+	"/etc/systemd/system/*",
+	"/etc/systemd/system/*/*",
+	"/lib/systemd/system/*",
+	"/usr/lib/systemd/system/*",
+}
+
+// IntegrityConfig selects the files watched by file integrity monitoring
+type IntegrityConfig struct {
+	Paths       []string // globs, nil uses DefaultIntegrityPaths
+	ExtraPaths  []string // globs added to Paths
+	Exclude     []string // globs of paths to ignore
+	MaxHashSize int64    // files larger than this are compared by metadata only, 0 uses the default
+	NoInotify   bool     // only detect changes by rescanning every interval
+}
+
+// FileMeta is the recorded state of a monitored path
+type FileMeta struct {
+	Type    string    `json:"type"` // file, dir, symlink, other
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"` // octal permissions with setuid, setgid and sticky bits
+	UID     int       `json:"uid"`
+	GID     int       `json:"gid"`
+	Owner   string    `json:"owner,omitempty"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256,omitempty"` // empty for directories and files over the size limit
+	Target  string    `json:"target,omitempty"` // symlink target
+}
+
+// FileEvent is a change of a monitored path
+type FileEvent struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"` // created, modified, deleted
+	Path    string    `json:"path"`
+	Source  string    `json:"source"`  // inotify, scan
+	Changes []string  `json:"changes"` // fields that differ: type, content, size, mode, owner, mtime, target
+	Before  *FileMeta `json:"before,omitempty"`
+	After   *FileMeta `json:"after,omitempty"`
+}
+
+// IntegrityData is the result of a file integrity collection
+type IntegrityData struct {
+	Monitored int         `json:"monitored"` // paths in the baseline
+	Events    []FileEvent `json:"events"`
+}
+
+// integrityState is the persisted baseline
+type integrityState struct {
+	Initialized bool                `json:"initialized"`
+	Patterns    []string            `json:"patterns"` // configured globs the baseline was built from
+	Files       map[string]FileMeta `json:"files"`
+}
+
+// IntegrityCollector detects changes of critical files against a baseline
+// Every interval the paths are rescanned; with inotify, changes are also
+// picked up as they happen, so a file modified and restored between two
+// reports is still noticed
+type IntegrityCollector struct {
+	BaseCollector
+	config    IntegrityConfig
+	statePath string
+	users     map[int]string
+
+	live        map[string]FileMeta // current view, updated by scans and inotify
+	touched     map[string]bool     // paths inotify handled during the running scan, nil between scans
+	initialized bool
+	patterns    []globPattern // expanded globs of the last scan
+	basePattern []string      // configured globs of the baseline
+	pending     []FileEvent   // events not delivered yet
+	delivered   int           // pending events returned by the last Collect
+	snapshot    integrityState
+	watcher     *fileWatcher
+	watchFailed bool
+	mu          sync.Mutex
+}
+
+// NewIntegrityCollector creates a new file integrity collector
+func NewIntegrityCollector(stateDir string, config IntegrityConfig) *IntegrityCollector {
+	if config.Paths == nil {
+		config.Paths = DefaultIntegrityPaths
+	}
+	if config.MaxHashSize <= 0 {
+		config.MaxHashSize = DefaultIntegrityMaxHashSize
+	}
+
+	c := &IntegrityCollector{
+		BaseCollector: BaseCollector{name: "integrity"},
+		config:        config,
+		statePath:     statePath(stateDir, "integrity.json"),
+	}
+
+	var saved integrityState
+	if err := state.Load(c.statePath, &saved); err != nil {
+		logger.Warn("Failed to load file integrity baseline, starting fresh: " + err.Error())
+	}
+	c.initialized = saved.Initialized
+	c.basePattern = saved.Patterns
+	c.live = saved.Files
+	if c.live == nil {
+		c.live = make(map[string]FileMeta)
+	}
+
+	return c
+}
+
+// Collect rescans the monitored paths and returns the changes since the
+// last delivered report
+// The first scan creates the baseline and raises no events
+func (c *IntegrityCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Checking file integrity")
+
+	users := readUserNames()
+	c.mu.Lock()
+	c.users = users
+	c.touched = make(map[string]bool)
+	c.mu.Unlock()
+
+	patterns := c.expandPatterns()
+	scanned := c.scan(patterns)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.patterns = patterns
+
+	// The scan runs without the lock: a path inotify handled meanwhile may
+	// have been read before the change, the inotify view is the newer one
+	// and its event is already pending
+	for path := range c.touched {
+		if meta, ok := c.live[path]; ok && c.watches(path) {
+			scanned[path] = meta
+		} else {
+			delete(scanned, path)
+		}
+	}
+	c.touched = nil
+	if !c.initialized {
+		logger.Info(fmt.Sprintf("Created file integrity baseline of %d paths", len(scanned)))
+		c.initialized = true
+	} else {
+		now := time.Now()
+		for _, path := range baselinePaths(c.live, scanned) {
+			before, hadBefore := c.live[path]
+			after, hasAfter := scanned[path]
+
+			// Paths only covered by newly configured patterns join the
+			// baseline silently, paths of removed patterns leave it silently
+			if !hadBefore && !coveredBy(patterns, c.basePattern, path) {
+				continue
+			}
+			if !hasAfter && !coveredBy(patterns, nil, path) {
+				continue
+			}
+
+			if event := fileEvent(path, before, hadBefore, after, hasAfter, "scan", now); event != nil {
+				c.pending = append(c.pending, *event)
+			}
+		}
+	}
+	c.live = scanned
+	c.basePattern = c.configuredPaths()
+
+	c.snapshot = integrityState{
+		Initialized: true,
+		Patterns:    c.basePattern,
+		Files:       make(map[string]FileMeta, len(c.live)),
+	}
+	for path, meta := range c.live {
+		c.snapshot.Files[path] = meta
+	}
+	c.delivered = len(c.pending)
+
+	c.startWatcher()
+
+	data := &IntegrityData{
+		Monitored: len(c.live),
+		Events:    make([]FileEvent, len(c.pending)),
+	}
+	copy(data.Events, c.pending)
+
+	for _, event := range data.Events {
+		logger.Warn(fmt.Sprintf("File %s %s (%s)", event.Path, event.Event, strings.Join(event.Changes, ", ")))
+	}
+	logger.Info(fmt.Sprintf("Monitoring %d paths, %d changes", data.Monitored, len(data.Events)))
+	return data, nil
+}
+
+// Commit drops the delivered events and saves the baseline
+func (c *IntegrityCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.snapshot.Initialized {
+		return nil
+	}
+	c.pending = c.pending[c.delivered:]
+	c.delivered = 0
+	return state.Save(c.statePath, c.snapshot)
+}
+
+// globPattern is a configured path after "~/" expansion
+type globPattern struct {
+	Configured string // as configured, "~/.ssh/authorized_keys"
+	Glob       string // "/home/alice/.ssh/authorized_keys"
+}
+
+// configuredPaths returns the configured globs, before expansion
+func (c *IntegrityCollector) configuredPaths() []string {
+	return append(append([]string{}, c.config.Paths...), c.config.ExtraPaths...)
+}
+
+// expandPatterns returns the configured globs with "~/" expanded to every
+// home directory; a plain directory also covers its entries
+func (c *IntegrityCollector) expandPatterns() []globPattern {
+	homes := make([]string, 0)
+	seenHome := make(map[string]bool)
+	if entries, err := readPasswd(); err == nil {
+		for _, entry := range entries {
+			home := filepath.Clean(entry.Home)
+			if home == "/" || home == "." || seenHome[home] {
+				continue
+			}
+			seenHome[home] = true
+			homes = append(homes, home)
+		}
+	}
+
+	patterns := make([]globPattern, 0)
+	for _, configured := range c.configuredPaths() {
+		if strings.HasPrefix(configured, "~/") {
+			for _, home := range homes {
+				patterns = append(patterns, globPattern{configured, filepath.Join(home, configured[2:])})
+			}
+			continue
+		}
+
+		patterns = append(patterns, globPattern{configured, configured})
+		if !hasGlobMeta(configured) {
+			if info, err := os.Stat(configured); err == nil && info.IsDir() {
+				patterns = append(patterns, globPattern{configured, filepath.Join(configured, "*")})
+			}
+		}
+	}
+
+	return patterns
+}
+
+// scan records the state of every path matching the patterns
+// Patterns reaching the same directory through a symlink (/lib -> /usr/lib)
+// are only scanned once
+func (c *IntegrityCollector) scan(patterns []globPattern) map[string]FileMeta {
+	files := make(map[string]FileMeta)
+	seenPatterns := make(map[string]bool)
+
+	for _, pattern := range patterns {
+		if dir := filepath.Dir(pattern.Glob); !hasGlobMeta(dir) {
+			if real, err := filepath.EvalSymlinks(dir); err == nil {
+				key := filepath.Join(real, filepath.Base(pattern.Glob))
+				if seenPatterns[key] {
+					continue
+				}
+				seenPatterns[key] = true
+			}
+		}
+
+		matches, err := filepath.Glob(pattern.Glob)
+		if err != nil {
+			logger.Warn("Invalid file integrity pattern " + pattern.Configured + ": " + err.Error())
+			continue
+		}
+		for _, path := range matches {
+			if _, ok := files[path]; ok || matchesAnyGlob(c.config.Exclude, path) {
+				continue
+			}
+			if meta, err := c.readMeta(path); err == nil {
+				files[path] = *meta
+			}
+		}
+	}
+
+	return files
+}
+
+// watches reports whether path is monitored
+func (c *IntegrityCollector) watches(path string) bool {
+	return coveredBy(c.patterns, nil, path) && !matchesAnyGlob(c.config.Exclude, path)
+}
+
+// coveredBy reports whether path matches one of the patterns; if
+// configured is not nil, only patterns expanded from one of its globs count
+func coveredBy(patterns []globPattern, configured []string, path string) bool {
+	for _, pattern := range patterns {
+		if configured != nil && !containsString(configured, pattern.Configured) {
+			continue
+		}
+		if matched, _ := filepath.Match(pattern.Glob, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// startWatcher starts inotify, or refreshes the watched directories
+// Must be called with c.mu held
+func (c *IntegrityCollector) startWatcher() {
+	if c.config.NoInotify || c.watchFailed {
+		return
+	}
+
+	if c.watcher == nil {
+		watcher, err := newFileWatcher(c.handleChange)
+		if err != nil {
+			logger.Warn("Real-time file integrity monitoring unavailable, rescanning every interval: " + err.Error())
+			c.watchFailed = true
+			return
+		}
+		c.watcher = watcher
+	}
+
+	// inotify returns one watch descriptor per directory inode, so a
+	// directory reached through a symlink (/lib -> /usr/lib) is only
+	// watched under the first path, the one scan keeps in the baseline
+	dirs := make(map[string]bool)
+	seenReal := make(map[string]bool)
+	addDir := func(dir string) {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			real = dir
+		}
+		if seenReal[real] {
+			return
+		}
+		seenReal[real] = true
+		dirs[dir] = true
+	}
+	for _, pattern := range c.patterns {
+		if dir := filepath.Dir(pattern.Glob); !hasGlobMeta(dir) {
+			addDir(dir)
+		}
+	}
+	for _, path := range baselinePaths(c.live, nil) {
+		addDir(filepath.Dir(path))
+	}
+	c.watcher.watch(dirs)
+}
+
+// handleChange is called by the watcher when an entry of a watched
+// directory changed
+func (c *IntegrityCollector) handleChange(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.watches(path) {
+		return
+	}
+	if c.touched != nil {
+		c.touched[path] = true
+	}
+
+	before, hadBefore := c.live[path]
+	after, err := c.readMeta(path)
+	hasAfter := err == nil
+
+	var event *FileEvent
+	if hasAfter {
+		event = fileEvent(path, before, hadBefore, *after, true, "inotify", time.Now())
+		c.live[path] = *after
+	} else {
+		event = fileEvent(path, before, hadBefore, FileMeta{}, false, "inotify", time.Now())
+		delete(c.live, path)
+	}
+
+	if event == nil {
+		return
+	}
+
+	// A file is created empty and written afterwards, fold the write into
+	// the creation while it has not been delivered
+	if event.Event == "modified" {
+		for i := len(c.pending) - 1; i >= c.delivered; i-- {
+			if c.pending[i].Path == path {
+				if c.pending[i].Event == "created" {
+					c.pending[i].After = event.After
+					return
+				}
+				break
+			}
+		}
+	}
+
+	logger.Warn(fmt.Sprintf("File %s %s (%s)", event.Path, event.Event, strings.Join(event.Changes, ", ")))
+	c.pending = append(c.pending, *event)
+}
+
+// readMeta records the metadata and content hash of a path
+func (c *IntegrityCollector) readMeta(path string) (*FileMeta, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &FileMeta{
+		Size:    info.Size(),
+		Mode:    fileModeString(info.Mode()),
+		ModTime: info.ModTime().UTC(),
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		meta.UID = int(stat.Uid)
+		meta.GID = int(stat.Gid)
+		meta.Owner = c.users[meta.UID]
+	}
+
+	switch {
+	case info.Mode().IsRegular():
+		meta.Type = "file"
+		if info.Size() <= c.config.MaxHashSize {
+			hash, err := hashFile(path)
+			if err != nil {
+				return nil, err
+			}
+			meta.SHA256 = hash
+		}
+	case info.IsDir():
+		meta.Type = "dir"
+		meta.Size = 0
+	case info.Mode()&os.ModeSymlink != 0:
+		meta.Type = "symlink"
+		meta.Target, _ = os.Readlink(path)
+	default:
+		meta.Type = "other"
+	}
+
+	return meta, nil
+}
+
+// fileEvent compares two states of a path, nil if nothing changed
+func fileEvent(path string, before FileMeta, hadBefore bool, after FileMeta, hasAfter bool,
+	source string, now time.Time) *FileEvent {
+	event := &FileEvent{Time: now, Path: path, Source: source}
+
+	switch {
+	case !hadBefore && !hasAfter:
+		return nil
+	case !hadBefore:
+		event.Event = "created"
+		event.After = &after
+		event.Changes = []string{}
+	case !hasAfter:
+		event.Event = "deleted"
+		event.Before = &before
+		event.Changes = []string{}
+	default:
+		event.Changes = metaChanges(before, after)
+		if len(event.Changes) == 0 {
+			return nil
+		}
+		event.Event = "modified"
+		event.Before = &before
+		event.After = &after
+	}
+
+	return event
+}
+
+// metaChanges lists the fields that differ between two states
+func metaChanges(before, after FileMeta) []string {
+	changes := make([]string, 0)
+	if before.Type != after.Type {
+		changes = append(changes, "type")
+	}
+	if before.SHA256 != after.SHA256 {
+		changes = append(changes, "content")
+	}
+	if before.Size != after.Size {
+		changes = append(changes, "size")
+	}
+	if before.Mode != after.Mode {
+		changes = append(changes, "mode")
+	}
+	if before.UID != after.UID || before.GID != after.GID {
+		changes = append(changes, "owner")
+	}
+	// A directory mtime only says that an entry changed, and monitored
+	// entries are reported themselves
+	if !before.ModTime.Equal(after.ModTime) && !(before.Type == "dir" && after.Type == "dir") {
+		changes = append(changes, "mtime")
+	}
+	if before.Target != after.Target {
+		changes = append(changes, "target")
+	}
+	return changes
+}
+
+// hashFile returns the hex SHA-256 of a file
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fileModeString formats the permission bits like chmod, "0644" or "4755"
+func fileModeString(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// hasGlobMeta reports whether a pattern contains glob characters
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// baselinePaths returns the paths of two baselines, sorted
+func baselinePaths(a, b map[string]FileMeta) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIntegrityWatcherSymlinkedDirectory(t *testing.T) {
+	root := t.TempDir()
+	real := filepath.Join(root, "usr", "lib")
+	link := filepath.Join(root, "lib")
+	if err := os.MkdirAll(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(real, "sshd.service"), []byte("[Unit]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewIntegrityCollector(t.TempDir(), IntegrityConfig{
+		Paths: []string{link + "/*", real + "/*"},
+	})
+	if _, err := c.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	if c.watcher == nil {
+		t.Skip("inotify unavailable")
+	}
+
+	if _, ok := c.live[filepath.Join(link, "sshd.service")]; !ok || len(c.live) != 1 {
+		t.Fatalf("baseline = %v, want only the path under %s", c.live, link)
+	}
+
+	c.watcher.mu.Lock()
+	defer c.watcher.mu.Unlock()
+	for _, dir := range c.watcher.dirs {
+		if dir != link {
+			t.Errorf("watching %s, want only %s", dir, link)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+// sshJournalMatches selects sshd entries, journalctl ORs groups separated by "+"
+var sshJournalMatches = []string{
+	"SYSLOG_IDENTIFIER=sshd",
+	"+", "SYSLOG_IDENTIFIER=sshd-session",
+	"+", "_SYSTEMD_UNIT=ssh.service",
+	"+", "_SYSTEMD_UNIT=sshd.service",
+}
+
+// maxJournalEntries limits the entries read in one cycle, the output of
+// journalctl is held in memory. With a cursor or --since, journalctl --lines
+// returns the oldest entries, the rest is read from the cursor next cycle
+var maxJournalEntries = 10000
+
+// journalState records where reading the journal stopped
+type journalState struct {
+	Cursor string `json:"cursor,omitempty"` // __CURSOR of the last entry read
+	Since  int64  `json:"since,omitempty"`  // unix time to start from when there is no cursor yet
+}
+
+// journalEntry is a single journal entry with its fields
+type journalEntry map[string]string
+
+// JournalReader reads entries matching a filter from the systemd journal
+// Like LogTailer, the position is only persisted on Commit
+type JournalReader struct {
+	statePath string
+	matches   []string
+	committed journalState
+	pending   *journalState
+	mu        sync.Mutex
+
+	// runCommand runs journalctl, replaceable so the reader can be fed
+	// journalctl -o export fixtures without a live systemd
+	runCommand func(name string, args ...string) ([]byte, error)
+}
+
+// NewJournalReader creates a journal reader for the given journalctl matches
+// that persists its cursor to statePath
+func NewJournalReader(statePath string, matches []string) *JournalReader {
+	r := &JournalReader{
+		statePath: statePath,
+		matches:   matches,
+		runCommand: func(name string, args ...string) ([]byte, error) {
+			return exec.Command(name, args...).Output()
+		},
+	}
+
+	if err := state.Load(statePath, &r.committed); err != nil {
+		logger.Warn("Failed to load journal state, starting fresh: " + err.Error())
+		r.committed = journalState{}
+	}
+
+	return r
+}
+
+// JournalAvailable reports whether journalctl can be used on this host
+func JournalAvailable() bool {
+	_, err := exec.LookPath("journalctl")
+	return err == nil
+}
+
+// ReadNew returns the entries logged since the last commit, rendered
+// as syslog lines so they go through the same parser as log files
+// On first use it only records the current time and returns nothing, the
+// same way LogTailer starts at the end of a file it has never seen
+func (r *JournalReader) ReadNew() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cursor := r.committed
+	if cursor.Cursor == "" && cursor.Since == 0 {
+		logger.Info("Reading journal entries from now on: " + strings.Join(r.matches, " "))
+		r.pending = &journalState{Since: time.Now().Unix()}
+		return nil, nil
+	}
+
+	args := []string{"-o", "export", "--no-pager"}
+	if cursor.Cursor != "" {
+		args = append(args, "--after-cursor="+cursor.Cursor)
+	} else {
+		args = append(args, fmt.Sprintf("--since=@%d", cursor.Since))
+	}
+	args = append(args, fmt.Sprintf("--lines=%d", maxJournalEntries))
+	args = append(args, r.matches...)
+
+	output, err := r.runCommand("journalctl", args...)
+	if err != nil {
+		return nil, fmt.Errorf("journalctl failed: %w", err)
+	}
+
+	entries, err := parseJournalExport(bytes.NewReader(output))
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) >= maxJournalEntries {
+		logger.Warn("Read the maximum of %d journal entries, the rest follows next cycle", maxJournalEntries)
+	}
+
+	lines := make([]string, 0, len(entries))
+	next := cursor
+	for _, entry := range entries {
+		if cursor := entry["__CURSOR"]; cursor != "" {
+			next = journalState{Cursor: cursor}
+		}
+
+		if line := entry.syslogLine(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	r.pending = &next
+	logger.Info(fmt.Sprintf("Read %d entries from journal", len(lines)))
+	return lines, nil
+}
+
+// Commit persists the journal position reached by the last ReadNew
+func (r *JournalReader) Commit() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil {
+		return nil
+	}
+
+	r.committed = *r.pending
+	r.pending = nil
+
+	return state.Save(r.statePath, r.committed)
+}
+
+// syslogLine renders the entry like an rsyslog high-precision line:
+// "2026-10-15T10:00:00.123456+02:00 hostname sshd[1234]: message"
+// The RFC3339 timestamp keeps the year, unlike the traditional format
+func (e journalEntry) syslogLine() string {
+	message := e["MESSAGE"]
+	if message == "" {
+		return ""
+	}
+
+	usec, err := strconv.ParseInt(e["__REALTIME_TIMESTAMP"], 10, 64)
+	if err != nil {
+		return ""
+	}
+	timestamp := time.UnixMicro(usec).Local().Format("2006-01-02T15:04:05.000000Z07:00")
+
+	hostname := e["_HOSTNAME"]
+	if hostname == "" {
+		hostname = "localhost"
+	}
+
+	pid := e["SYSLOG_PID"]
+	if pid == "" {
+		pid = e["_PID"]
+	}
+	if pid == "" {
+		pid = "0"
+	}
+
+	identifier := e["SYSLOG_IDENTIFIER"]
+	if identifier == "" {
+		identifier = e["_COMM"]
+	}
+	if identifier == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s %s %s[%s]: %s", timestamp, hostname, identifier, pid, message)
+}
+
+// parseJournalExport parses the journal export format (journalctl -o export)
+// Entries are separated by an empty line. Fields are "KEY=value" lines, or
+// for binary-safe values "KEY\n" followed by a little-endian uint64 length,
+// the raw data and a newline
+func parseJournalExport(r io.Reader) ([]journalEntry, error) {
+	reader := bufio.NewReader(r)
+	entries := make([]journalEntry, 0)
+	entry := make(journalEntry)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read journal export: %w", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+
+		// Empty line terminates the entry
+		if line == "" {
+			if len(entry) > 0 {
+				entries = append(entries, entry)
+				entry = make(journalEntry)
+			}
+			if err == io.EOF {
+				break
+			}
+			continue
+		}
+
+		if idx := strings.IndexByte(line, '='); idx >= 0 {
+			entry[line[:idx]] = line[idx+1:]
+		} else {
+			value, err := readJournalBinaryField(reader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read journal field %s: %w", line, err)
+			}
+			entry[line] = value
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if len(entry) > 0 {
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// readJournalBinaryField reads the length-prefixed value of a binary field
+func readJournalBinaryField(reader *bufio.Reader) (string, error) {
+	var size uint64
+	if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+		return "", err
+	}
+
+	// Guard against corrupt input asking for absurd allocations
+	if size > 16*1024*1024 {
+		return "", fmt.Errorf("field too large: %d bytes", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return "", err
+	}
+
+	// Each binary field is followed by a newline
+	if b, err := reader.ReadByte(); err != nil || b != '\n' {
+		return "", fmt.Errorf("missing field terminator")
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,316 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+// listenerProtocols are the /proc/net tables scanned for listeners
+var listenerProtocols = []string{"tcp", "tcp6", "udp", "udp6"}
+
+// Listener is a socket waiting for connections or datagrams
+type Listener struct {
+	Protocol string `json:"protocol"` // tcp, tcp6, udp, udp6
+	Address  string `json:"address"`  // 0.0.0.0 and :: for all addresses
+	Port     int    `json:"port"`
+	PID      int    `json:"pid,omitempty"` // 0 if the owner could not be resolved
+	Process  string `json:"process,omitempty"`
+	Exe      string `json:"exe,omitempty"`
+	User     string `json:"user"`
+	UID      int    `json:"uid"`
+}
+
+// key identifies a listener across collections
+// The owner is left out, it cannot always be resolved; diffListeners
+// compares it separately
+func (l Listener) key() string {
+	return l.Protocol + " " + l.Address + " " + strconv.Itoa(l.Port)
+}
+
+// ownerChanged reports whether another program took over a listener
+// An owner that could not be resolved matches any owner
+func ownerChanged(before, after Listener) bool {
+	return before.Exe != "" && after.Exe != "" && before.Exe != after.Exe
+}
+
+// ListenerEvent is a listener that appeared or disappeared
+type ListenerEvent struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"` // listener_added, listener_removed
+	Listener Listener  `json:"listener"`
+}
+
+// ListenerData is the result of a listening ports collection
+type ListenerData struct {
+	Listeners []Listener      `json:"listeners"`
+	Events    []ListenerEvent `json:"events"` // changes since the last delivered report
+}
+
+// listenerState is the persisted listener snapshot
+type listenerState struct {
+	Initialized bool                `json:"initialized"` // false until the first snapshot is saved
+	Listeners   map[string]Listener `json:"listeners"`
+	Pending     map[string]Listener `json:"pending"` // transient listeners seen once, not reported yet
+}
+
+// ListenerCollector inventories listening TCP and UDP sockets
+type ListenerCollector struct {
+	BaseCollector
+	procPath  string
+	statePath string
+	committed listenerState
+	working   listenerState
+	mu        sync.Mutex
+}
+
+// NewListenerCollector creates a new listening ports collector
+func NewListenerCollector(stateDir string) *ListenerCollector {
+	c := &ListenerCollector{
+		BaseCollector: BaseCollector{name: "listeners"},
+		procPath:      "/proc",
+		statePath:     statePath(stateDir, "listeners.json"),
+	}
+
+	if err := state.Load(c.statePath, &c.committed); err != nil {
+		logger.Warn("Failed to load listener state, starting fresh: " + err.Error())
+	}
+	// Rebuild the keys, older versions made the executable part of them
+	listeners := make(map[string]Listener, len(c.committed.Listeners))
+	for _, listener := range c.committed.Listeners {
+		listeners[listener.key()] = listener
+	}
+	c.committed.Listeners = listeners
+
+	return c
+}
+
+// Collect returns the current listeners and the changes since the last
+// delivered report
+// The first snapshot is the baseline and raises no events
+func (c *ListenerCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Collecting listening ports")
+
+	listeners, err := c.readListeners()
+	if err != nil {
+		return nil, err
+	}
+	ephemeral := readLocalPortRange(c.procPath)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.working = listenerState{
+		Initialized: true,
+		Listeners:   make(map[string]Listener, len(listeners)),
+		Pending:     make(map[string]Listener),
+	}
+	for _, listener := range listeners {
+		key := listener.key()
+		previous, known := c.committed.Listeners[key]
+		_, seenBefore := c.committed.Pending[key]
+
+		// Keep the last known owner while it cannot be resolved, so a later
+		// takeover is still noticed
+		if known && listener.Exe == "" {
+			listener.Exe = previous.Exe
+		}
+
+		// Unconnected UDP client sockets (resolvers, NTP, DHCP) get a port
+		// from the ephemeral range and come and go between collections:
+		// such a socket is only reported once two snapshots in a row have it
+		if c.committed.Initialized && !known && !seenBefore && isTransient(listener, ephemeral) {
+			c.working.Pending[key] = listener
+			continue
+		}
+		c.working.Listeners[key] = listener
+	}
+
+	data := &ListenerData{
+		Listeners: listeners,
+		Events:    make([]ListenerEvent, 0),
+	}
+	if c.committed.Initialized {
+		data.Events = diffListeners(c.committed.Listeners, c.working.Listeners, time.Now())
+	}
+
+	for _, event := range data.Events {
+		if event.Event == "listener_added" {
+			logger.Warn(fmt.Sprintf("New listener: %s %s:%d (%s, pid %d)", event.Listener.Protocol,
+				event.Listener.Address, event.Listener.Port, event.Listener.Exe, event.Listener.PID))
+		}
+	}
+
+	logger.Info(fmt.Sprintf("Found %d listeners, %d changes", len(data.Listeners), len(data.Events)))
+	return data, nil
+}
+
+// Commit makes the last snapshot the baseline of the next collection
+func (c *ListenerCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.working.Initialized {
+		return nil
+	}
+	c.committed = c.working
+	return state.Save(c.statePath, c.committed)
+}
+
+// readListeners reads the listening sockets and resolves their owners
+func (c *ListenerCollector) readListeners() ([]Listener, error) {
+	sockets := make([]netSocket, 0)
+	for _, protocol := range listenerProtocols {
+		table, err := readNetSockets(c.procPath, protocol)
+		if err != nil {
+			// tcp6/udp6 are missing when IPv6 is disabled
+			logger.Debug("Failed to read /proc/net/%s: %v", protocol, err)
+			continue
+		}
+		for _, socket := range table {
+			if isListening(socket) {
+				sockets = append(sockets, socket)
+			}
+		}
+	}
+	if len(sockets) == 0 {
+		return []Listener{}, nil
+	}
+
+	wanted := make(map[uint64]bool, len(sockets))
+	for _, socket := range sockets {
+		wanted[socket.Inode] = true
+	}
+	owners := socketOwners(c.procPath, wanted)
+	users := readUserNames()
+
+	// SO_REUSEPORT and forked workers give several sockets per address,
+	// they are reported once
+	seen := make(map[string]bool)
+	listeners := make([]Listener, 0, len(sockets))
+	for _, socket := range sockets {
+		listener := Listener{
+			Protocol: socket.Protocol,
+			Address:  socket.LocalIP.String(),
+			Port:     socket.LocalPort,
+			UID:      socket.UID,
+			User:     users[socket.UID],
+		}
+		if listener.User == "" {
+			listener.User = strconv.Itoa(socket.UID)
+		}
+		if owner, ok := owners[socket.Inode]; ok {
+			listener.PID = owner.PID
+			listener.Process = owner.Name
+			// An executable replaced by an upgrade reads as "path (deleted)"
+			listener.Exe = strings.TrimSuffix(owner.Exe, " (deleted)")
+		}
+
+		if seen[listener.key()] {
+			continue
+		}
+		seen[listener.key()] = true
+		listeners = append(listeners, listener)
+	}
+
+	sort.Slice(listeners, func(i, j int) bool {
+		if listeners[i].Protocol != listeners[j].Protocol {
+			return listeners[i].Protocol < listeners[j].Protocol
+		}
+		if listeners[i].Port != listeners[j].Port {
+			return listeners[i].Port < listeners[j].Port
+		}
+		return listeners[i].Address < listeners[j].Address
+	})
+
+	return listeners, nil
+}
+
+// portRange is an inclusive range of ports
+type portRange struct {
+	low, high int
+}
+
+// contains reports whether port is in the range
+func (r portRange) contains(port int) bool {
+	return port >= r.low && port <= r.high
+}
+
+// defaultLocalPortRange is the kernel default of ip_local_port_range
+var defaultLocalPortRange = portRange{low: 32768, high: 60999}
+
+// readLocalPortRange returns the ports the kernel picks for sockets that
+// are not bound explicitly, the range applies to IPv6 as well
+func readLocalPortRange(procPath string) portRange {
+	data, err := os.ReadFile(filepath.Join(procPath, "sys/net/ipv4/ip_local_port_range"))
+	if err != nil {
+		return defaultLocalPortRange
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return defaultLocalPortRange
+	}
+	low, err1 := strconv.Atoi(fields[0])
+	high, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil || low > high {
+		return defaultLocalPortRange
+	}
+	return portRange{low: low, high: high}
+}
+
+// isListening reports whether a socket accepts connections (TCP) or is a
+// bound UDP socket without a peer
+func isListening(socket netSocket) bool {
+	switch socket.Protocol {
+	case "tcp", "tcp6":
+		return socket.State == tcpListen
+	default:
+		return socket.State == udpUnconnected && socket.LocalPort != 0
+	}
+}
+
+// isTransient reports whether a listener may be a short-lived UDP client
+// socket rather than a service
+func isTransient(listener Listener, ephemeral portRange) bool {
+	return (listener.Protocol == "udp" || listener.Protocol == "udp6") && ephemeral.contains(listener.Port)
+}
+
+// diffListeners returns the listeners added and removed between two snapshots
+func diffListeners(before, after map[string]Listener, now time.Time) []ListenerEvent {
+	events := make([]ListenerEvent, 0)
+
+	for key, listener := range after {
+		previous, ok := before[key]
+		if !ok || ownerChanged(previous, listener) {
+			events = append(events, ListenerEvent{Time: now, Event: "listener_added", Listener: listener})
+		}
+	}
+	for key, listener := range before {
+		current, ok := after[key]
+		if !ok || ownerChanged(listener, current) {
+			events = append(events, ListenerEvent{Time: now, Event: "listener_removed", Listener: listener})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Event != events[j].Event {
+			return events[i].Event < events[j].Event
+		}
+		return events[i].Listener.key() < events[j].Listener.key()
+	})
+	return events
+}
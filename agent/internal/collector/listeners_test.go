@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeUDPTable writes a /proc/net/udp table of unconnected sockets bound
+// to the given ports on all addresses
+func writeUDPTable(t *testing.T, procPath string, ports ...int) {
+	t.Helper()
+
+	lines := []string{"  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode"}
+	for i, port := range ports {
+		lines = append(lines, fmt.Sprintf("%4d: 00000000:%04X 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 %d 2 0000000000000000 0",
+			i, port, 1000+port))
+	}
+	if err := os.WriteFile(filepath.Join(procPath, "net", "udp"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenerCollectorTransientUDP(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("listeners are collected on Linux only")
+	}
+
+	procPath := t.TempDir()
+	for _, dir := range []string{"net", "sys/net/ipv4"} {
+		if err := os.MkdirAll(filepath.Join(procPath, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(procPath, "sys/net/ipv4/ip_local_port_range"), []byte("32768\t60999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewListenerCollector(t.TempDir())
+	c.procPath = procPath
+
+	steps := []struct {
+		name   string
+		ports  []int
+		events []string
+	}{
+		{name: "baseline", ports: []int{514, 40000}},
+		{name: "service port", ports: []int{514, 40000, 5353}, events: []string{"listener_added udp 5353"}},
+		{name: "ephemeral port seen once", ports: []int{514, 40000, 5353, 41000}},
+		{name: "ephemeral port seen twice", ports: []int{514, 40000, 5353, 41000}, events: []string{"listener_added udp 41000"}},
+		{name: "client sockets churning", ports: []int{514, 40000, 5353, 41000, 42000}},
+		{name: "churned socket gone", ports: []int{514, 40000, 5353, 41000, 43000}},
+		{name: "reported ephemeral port gone", ports: []int{514, 40000, 5353}, events: []string{"listener_removed udp 41000"}},
+	}
+
+	for _, step := range steps {
+		writeUDPTable(t, procPath, step.ports...)
+
+		result, err := c.Collect()
+		if err != nil {
+			t.Fatalf("%s: Collect() error = %v", step.name, err)
+		}
+		data := result.(*ListenerData)
+
+		var events []string
+		for _, event := range data.Events {
+			events = append(events, fmt.Sprintf("%s %s %d", event.Event, event.Listener.Protocol, event.Listener.Port))
+		}
+		if strings.Join(events, ", ") != strings.Join(step.events, ", ") {
+			t.Errorf("%s: events = %q, want %q", step.name, events, step.events)
+		}
+		if len(data.Listeners) != len(step.ports) {
+			t.Errorf("%s: got %d listeners, want %d", step.name, len(data.Listeners), len(step.ports))
+		}
+
+		if err := c.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiffListenersOwner(t *testing.T) {
+	sshd := Listener{Protocol: "tcp", Address: "0.0.0.0", Port: 22, Exe: "/usr/sbin/sshd"}
+	unresolved := sshd
+	unresolved.Exe = ""
+	other := sshd
+	other.Exe = "/tmp/.x/sshd"
+
+	tests := []struct {
+		name   string
+		before Listener
+		after  Listener
+		events []string
+	}{
+		{name: "same owner", before: sshd, after: sshd},
+		{name: "owner not resolved", before: sshd, after: unresolved},
+		{name: "owner resolved again", before: unresolved, after: sshd},
+		{name: "other program", before: sshd, after: other,
+			events: []string{"listener_added /tmp/.x/sshd", "listener_removed /usr/sbin/sshd"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := diffListeners(
+				map[string]Listener{tt.before.key(): tt.before},
+				map[string]Listener{tt.after.key(): tt.after},
+				time.Now())
+
+			var got []string
+			for _, event := range events {
+				got = append(got, event.Event+" "+event.Listener.Exe)
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.events, ", ") {
+				t.Errorf("events = %q, want %q", got, tt.events)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// MemoryStats is the memory and swap usage with paging rates
+type MemoryStats struct {
+	Total        uint64  `json:"total"` // bytes
+	Available    uint64  `json:"available"`
+	Used         uint64  `json:"used"` // total - available
+	Free         uint64  `json:"free"`
+	Buffers      uint64  `json:"buffers"`
+	Cached       uint64  `json:"cached"` // page cache including reclaimable slab, like free(1)
+	Slab         uint64  `json:"slab"`
+	SReclaimable uint64  `json:"sreclaimable"`
+	Shmem        uint64  `json:"shmem"`
+	UsedPercent  float64 `json:"used_percent"`
+
+	SwapTotal       uint64  `json:"swap_total"`
+	SwapFree        uint64  `json:"swap_free"`
+	SwapUsed        uint64  `json:"swap_used"`
+	SwapUsedPercent float64 `json:"swap_used_percent"`
+
+	// Rates from /proc/vmstat, per second over Interval
+	// All zero on the first collection, which has no previous sample
+	Interval       float64 `json:"interval"`      // seconds
+	SwapInRate     float64 `json:"swap_in_rate"`  // pages
+	SwapOutRate    float64 `json:"swap_out_rate"` // pages
+	PageFaultRate  float64 `json:"page_fault_rate"`
+	MajorFaultRate float64 `json:"major_fault_rate"`
+}
+
+// vmstatCounters are the /proc/vmstat counters used for rates
+type vmstatCounters struct {
+	Time       time.Time
+	SwapIn     uint64
+	SwapOut    uint64
+	PageFaults uint64
+	MajFaults  uint64
+}
+
+// MemoryCollector reads /proc/meminfo and /proc/vmstat
+type MemoryCollector struct {
+	BaseCollector
+	meminfoPath string
+	vmstatPath  string
+	committed   *vmstatCounters // sample of the last delivered report
+	current     *vmstatCounters // sample of the last Collect
+	mu          sync.Mutex
+}
+
+// NewMemoryCollector creates a new memory collector
+func NewMemoryCollector() *MemoryCollector {
+	return &MemoryCollector{
+		BaseCollector: BaseCollector{name: "memory"},
+		meminfoPath:   "/proc/meminfo",
+		vmstatPath:    "/proc/vmstat",
+	}
+}
+
+// Collect collects memory usage and the paging rates since the last delivered report
+func (c *MemoryCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Collecting memory information")
+
+	meminfo, err := readProcValues(c.meminfoPath, ':')
+	if err != nil {
+		return nil, err
+	}
+
+	stats := memoryFromMeminfo(meminfo)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	vmstat, err := readProcValues(c.vmstatPath, ' ')
+	if err != nil {
+		logger.Warn("Failed to read paging counters: " + err.Error())
+	} else {
+		c.current = &vmstatCounters{
+			Time:       time.Now(),
+			SwapIn:     vmstat["pswpin"],
+			SwapOut:    vmstat["pswpout"],
+			PageFaults: vmstat["pgfault"],
+			MajFaults:  vmstat["pgmajfault"],
+		}
+		if c.committed != nil {
+			applyVmstatRates(stats, c.committed, c.current)
+		}
+	}
+
+	logger.Info("Memory: %.1f%% used, swap %.1f%% used",
+		stats.UsedPercent, stats.SwapUsedPercent)
+	return stats, nil
+}
+
+// Commit makes the last paging sample the baseline of the next collection
+func (c *MemoryCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil {
+		c.committed = c.current
+	}
+	return nil
+}
+
+// memoryFromMeminfo computes the memory figures from /proc/meminfo values (kB)
+func memoryFromMeminfo(meminfo map[string]uint64) *MemoryStats {
+	kb := func(key string) uint64 {
+		return meminfo[key] * 1024
+	}
+
+	stats := &MemoryStats{
+		Total:        kb("MemTotal"),
+		Free:         kb("MemFree"),
+		Buffers:      kb("Buffers"),
+		Cached:       kb("Cached") + kb("SReclaimable"),
+		Slab:         kb("Slab"),
+		SReclaimable: kb("SReclaimable"),
+		Shmem:        kb("Shmem"),
+		SwapTotal:    kb("SwapTotal"),
+		SwapFree:     kb("SwapFree"),
+	}
+
+	// MemAvailable exists since Linux 3.14, estimate it on older kernels
+	if _, ok := meminfo["MemAvailable"]; ok {
+		stats.Available = kb("MemAvailable")
+	} else {
+		stats.Available = stats.Free + stats.Buffers + stats.Cached
+	}
+	if stats.Available > stats.Total {
+		stats.Available = stats.Total
+	}
+
+	stats.Used = stats.Total - stats.Available
+	if stats.Total > 0 {
+		stats.UsedPercent = float64(stats.Used) * 100 / float64(stats.Total)
+	}
+
+	if stats.SwapTotal >= stats.SwapFree {
+		stats.SwapUsed = stats.SwapTotal - stats.SwapFree
+	}
+	if stats.SwapTotal > 0 {
+		stats.SwapUsedPercent = float64(stats.SwapUsed) * 100 / float64(stats.SwapTotal)
+	}
+
+	return stats
+}
+
+// applyVmstatRates fills the paging rates from two vmstat samples
+func applyVmstatRates(stats *MemoryStats, previous, current *vmstatCounters) {
+	interval := current.Time.Sub(previous.Time).Seconds()
+	if interval <= 0 {
+		return
+	}
+
+	stats.Interval = interval
+	stats.SwapInRate = float64(counterDelta(previous.SwapIn, current.SwapIn)) / interval
+	stats.SwapOutRate = float64(counterDelta(previous.SwapOut, current.SwapOut)) / interval
+	stats.PageFaultRate = float64(counterDelta(previous.PageFaults, current.PageFaults)) / interval
+	stats.MajorFaultRate = float64(counterDelta(previous.MajFaults, current.MajFaults)) / interval
+}
+
+// readProcValues reads a /proc file of "key<sep> value [unit]" lines
+func readProcValues(path string, sep byte) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return parseProcValues(file, sep)
+}
+
+// parseProcValues parses "key<sep> value [unit]" lines, lines that do not
+// hold a number are skipped
+func parseProcValues(r io.Reader, sep byte) (map[string]uint64, error) {
+	values := make(map[string]uint64)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.IndexByte(line, sep)
+		if i <= 0 {
+			continue
+		}
+
+		fields := strings.Fields(line[i+1:])
+		if len(fields) == 0 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSpace(line[:i])] = value
+	}
+
+	return values, scanner.Err()
+}
@@ -0,0 +1,189 @@
+package collector
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// passwdPath is the local account database
+const passwdPath = "/etc/passwd"
+
+// passwdEntry is a line of /etc/passwd
+type passwdEntry struct {
+	Name  string
+	UID   int
+	GID   int
+	Gecos string
+	Home  string
+	Shell string
+}
+
+// parsePasswd parses /etc/passwd formatted data
+// Comments, NIS "+" entries and malformed lines are skipped
+func parsePasswd(r io.Reader) ([]passwdEntry, error) {
+	entries := make([]passwdEntry, 0)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 {
+			continue
+		}
+
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		gid, err := strconv.Atoi(fields[3])
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, passwdEntry{
+			Name:  fields[0],
+			UID:   uid,
+			GID:   gid,
+			Gecos: fields[4],
+			Home:  fields[5],
+			Shell: fields[6],
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// readPasswd reads the local account database
+func readPasswd() ([]passwdEntry, error) {
+	file, err := os.Open(passwdPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parsePasswd(file)
+}
+
+// groupPath and shadowPath are the local group and password databases
+const (
+	groupPath  = "/etc/group"
+	shadowPath = "/etc/shadow"
+)
+
+// groupEntry is a line of /etc/group
+type groupEntry struct {
+	Name    string
+	GID     int
+	Members []string
+}
+
+// shadowEntry is the part of an /etc/shadow line the agent looks at
+// Hash is only kept in memory to detect changes, it is never reported
+type shadowEntry struct {
+	Name       string
+	Hash       string
+	LastChange int // days since 1970-01-01, -1 if empty
+}
+
+// parseGroup parses /etc/group formatted data
+func parseGroup(r io.Reader) ([]groupEntry, error) {
+	entries := make([]groupEntry, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // large member lists
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		if len(fields) < 4 {
+			continue
+		}
+
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+
+		members := make([]string, 0)
+		for _, member := range strings.Split(fields[3], ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				members = append(members, member)
+			}
+		}
+
+		entries = append(entries, groupEntry{Name: fields[0], GID: gid, Members: members})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// parseShadow parses /etc/shadow formatted data
+func parseShadow(r io.Reader) ([]shadowEntry, error) {
+	entries := make([]shadowEntry, 0)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			continue
+		}
+
+		lastChange, err := strconv.Atoi(fields[2])
+		if err != nil {
+			lastChange = -1
+		}
+
+		entries = append(entries, shadowEntry{Name: fields[0], Hash: fields[1], LastChange: lastChange})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// readGroup reads the local group database
+func readGroup() ([]groupEntry, error) {
+	file, err := os.Open(groupPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseGroup(file)
+}
+
+// readShadow reads the local password database, which needs root
+func readShadow() ([]shadowEntry, error) {
+	file, err := os.Open(shadowPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseShadow(file)
+}
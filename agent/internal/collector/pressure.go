@@ -0,0 +1,235 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// pressureResources are the files read under /proc/pressure
+var pressureResources = []string{"cpu", "memory", "io"}
+
+// PressureLine is one "some" or "full" line of a PSI file
+type PressureLine struct {
+	Avg10   float64 `json:"avg10"` // percent of time stalled, 10s average
+	Avg60   float64 `json:"avg60"`
+	Avg300  float64 `json:"avg300"`
+	Total   uint64  `json:"total"`   // cumulative stall time, microseconds
+	Delta   uint64  `json:"delta"`   // stall time since the previous report, microseconds
+	Percent float64 `json:"percent"` // percent of time stalled since the previous report
+}
+
+// PressureResource is the pressure of one resource
+// "some": at least one task stalled, "full": all non-idle tasks stalled
+type PressureResource struct {
+	Some PressureLine  `json:"some"`
+	Full *PressureLine `json:"full"` // nil for cpu on kernels before 5.13
+}
+
+// PressureStats is the Pressure Stall Information of the host
+type PressureStats struct {
+	Interval float64           `json:"interval"` // seconds covered by Delta and Percent, 0 on the first collection
+	CPU      *PressureResource `json:"cpu"`
+	Memory   *PressureResource `json:"memory"`
+	IO       *PressureResource `json:"io"`
+}
+
+// pressureSample is a snapshot of the cumulative stall totals
+type pressureSample struct {
+	Time   time.Time
+	Totals map[string]uint64 // "cpu/some" -> total
+}
+
+// PressureCollector reads Pressure Stall Information (Linux 4.20+)
+// Kernels without PSI, or booted with psi=0, are skipped silently
+type PressureCollector struct {
+	BaseCollector
+	pressurePath string
+	unavailable  bool
+	committed    *pressureSample // sample of the last delivered report
+	current      *pressureSample // sample of the last Collect
+	mu           sync.Mutex
+}
+
+// NewPressureCollector creates a new PSI collector
+func NewPressureCollector() *PressureCollector {
+	return &PressureCollector{
+		BaseCollector: BaseCollector{name: "pressure"},
+		pressurePath:  "/proc/pressure",
+	}
+}
+
+// Collect reads the pressure of cpu, memory and io
+func (c *PressureCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.unavailable {
+		return nil, nil
+	}
+
+	logger.Info("Collecting pressure stall information")
+
+	stats := &PressureStats{}
+	sample := &pressureSample{Time: time.Now(), Totals: make(map[string]uint64)}
+
+	for _, resource := range pressureResources {
+		pressure, err := readPressureFile(filepath.Join(c.pressurePath, resource))
+		if err != nil {
+			if isPressureUnsupported(err) {
+				logger.Info("Pressure stall information not available on this kernel, disabling")
+				c.unavailable = true
+				return nil, nil
+			}
+			logger.Warn("Failed to read " + resource + " pressure: " + err.Error())
+			continue
+		}
+
+		sample.Totals[resource+"/some"] = pressure.Some.Total
+		if pressure.Full != nil {
+			sample.Totals[resource+"/full"] = pressure.Full.Total
+		}
+
+		switch resource {
+		case "cpu":
+			stats.CPU = pressure
+		case "memory":
+			stats.Memory = pressure
+		case "io":
+			stats.IO = pressure
+		}
+	}
+
+	c.current = sample
+	if c.committed != nil {
+		c.applyDeltas(stats, c.committed, sample)
+	}
+
+	if stats.CPU != nil && stats.Memory != nil && stats.IO != nil {
+		logger.Info("Pressure avg60: cpu %.2f%%, memory %.2f%%, io %.2f%%",
+			stats.CPU.Some.Avg60, stats.Memory.Some.Avg60, stats.IO.Some.Avg60)
+	}
+	return stats, nil
+}
+
+// Commit makes the last sample the baseline of the next collection
+func (c *PressureCollector) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil {
+		c.committed = c.current
+	}
+	return nil
+}
+
+// applyDeltas computes the stall time and the stalled share of time between
+// two samples
+func (c *PressureCollector) applyDeltas(stats *PressureStats, previous, current *pressureSample) {
+	interval := current.Time.Sub(previous.Time)
+	if interval <= 0 {
+		return
+	}
+	stats.Interval = interval.Seconds()
+
+	apply := func(line *PressureLine, key string) {
+		before, ok := previous.Totals[key]
+		if !ok {
+			return
+		}
+		line.Delta = counterDelta(before, current.Totals[key])
+		line.Percent = float64(line.Delta) * 100 / float64(interval.Microseconds())
+	}
+
+	resources := map[string]*PressureResource{"cpu": stats.CPU, "memory": stats.Memory, "io": stats.IO}
+	for name, resource := range resources {
+		if resource == nil {
+			continue
+		}
+		apply(&resource.Some, name+"/some")
+		if resource.Full != nil {
+			apply(resource.Full, name+"/full")
+		}
+	}
+}
+
+// isPressureUnsupported reports whether err means PSI is missing or disabled
+func isPressureUnsupported(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.EOPNOTSUPP)
+}
+
+// readPressureFile reads one /proc/pressure file
+func readPressureFile(path string) (*PressureResource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parsePressure(file)
+}
+
+// parsePressure parses a PSI file:
+// "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+// "full avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+func parsePressure(r io.Reader) (*PressureResource, error) {
+	resource := &PressureResource{}
+	foundSome := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		line := PressureLine{}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "avg10":
+				line.Avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				line.Avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				line.Avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				line.Total, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+
+		switch fields[0] {
+		case "some":
+			resource.Some = line
+			foundSome = true
+		case "full":
+			resource.Full = &line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !foundSome {
+		return nil, fmt.Errorf("no \"some\" line in pressure file")
+	}
+	return resource, nil
+}
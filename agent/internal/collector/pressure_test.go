@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPressureApplyDeltas(t *testing.T) {
+	start := time.Now()
+	previous := &pressureSample{Time: start, Totals: map[string]uint64{
+		"cpu/some":    1_000_000,
+		"memory/some": 5_000_000,
+		"memory/full": 2_000_000,
+	}}
+	current := &pressureSample{Time: start.Add(10 * time.Second), Totals: map[string]uint64{
+		"cpu/some":    3_000_000, // 2s stalled out of 10s
+		"memory/some": 4_000_000, // counter reset
+		"memory/full": 2_500_000,
+		"io/some":     700_000, // no previous sample
+	}}
+	stats := &PressureStats{
+		CPU:    &PressureResource{Some: PressureLine{Total: 3_000_000}},
+		Memory: &PressureResource{Some: PressureLine{Total: 4_000_000}, Full: &PressureLine{Total: 2_500_000}},
+		IO:     &PressureResource{Some: PressureLine{Total: 700_000}},
+	}
+
+	(&PressureCollector{}).applyDeltas(stats, previous, current)
+
+	tests := []struct {
+		name    string
+		line    PressureLine
+		delta   uint64
+		percent float64
+	}{
+		{name: "cpu some", line: stats.CPU.Some, delta: 2_000_000, percent: 20},
+		{name: "memory some after reset", line: stats.Memory.Some, delta: 0, percent: 0},
+		{name: "memory full", line: *stats.Memory.Full, delta: 500_000, percent: 5},
+		{name: "io some without previous", line: stats.IO.Some, delta: 0, percent: 0},
+	}
+
+	if stats.Interval != 10 {
+		t.Errorf("Interval = %v, want 10", stats.Interval)
+	}
+	for _, tt := range tests {
+		if tt.line.Delta != tt.delta || tt.line.Percent != tt.percent {
+			t.Errorf("%s: delta %d, percent %v, want %d, %v", tt.name, tt.line.Delta, tt.line.Percent, tt.delta, tt.percent)
+		}
+	}
+}
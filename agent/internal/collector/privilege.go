@@ -0,0 +1,296 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// PrivilegeEvent represents a sudo or su event
+type PrivilegeEvent struct {
+	Time       time.Time `json:"time"`
+	Tool       string    `json:"tool"`  // sudo, su
+	Event      string    `json:"event"` // command, session_open, auth_failure, not_in_sudoers, denied
+	User       string    `json:"user"`  // invoking user
+	TargetUser string    `json:"target_user"`
+	TTY        string    `json:"tty"`
+	PWD        string    `json:"pwd"`
+	Command    string    `json:"command"`
+	Attempts   int       `json:"attempts"` // incorrect password attempts
+	Reason     string    `json:"reason"`   // sudo's reason for a refusal
+	Success    bool      `json:"success"`
+	PID        int       `json:"pid"`
+}
+
+// privilegeJournalMatches selects sudo and su entries
+var privilegeJournalMatches = []string{
+	"SYSLOG_IDENTIFIER=sudo",
+	"+", "SYSLOG_IDENTIFIER=su",
+}
+
+// sudo and su message formats
+var (
+	// "3 incorrect password attempts"
+	sudoAttemptsPattern = regexp.MustCompile(`^(\d+) incorrect password attempts?$`)
+
+	// "pam_unix(su-l:session): session opened for user root(uid=0) by alice(uid=1000)"
+	suSessionPattern = regexp.MustCompile(
+		`^pam_unix\(su(?:-l)?:session\): session opened for user ([^\s(]+)(?:\(uid=\d+\))? by ([^\s(]*)`,
+	)
+
+	// "pam_unix(su:auth): authentication failure; logname=alice uid=1000 ... user=root"
+	suAuthFailurePattern = regexp.MustCompile(
+		`^pam_unix\(su(?:-l)?:auth\): authentication failure;(.*)$`,
+	)
+
+	// Debian: "(to root) alice on pts/0", "FAILED SU (to root) alice on pts/0"
+	suDebianPattern = regexp.MustCompile(
+		`^(FAILED SU )?\(to (\S+)\) (\S+) on (\S+)$`,
+	)
+)
+
+// PrivilegeCollector collects sudo and su events from the auth logs
+type PrivilegeCollector struct {
+	BaseCollector
+	logPaths []string
+	tailer   *LogTailer
+	journal  *JournalReader
+}
+
+// NewPrivilegeCollector creates a new privilege escalation collector
+// It reads the same sources as SSHCollector with its own read positions
+func NewPrivilegeCollector(stateDir string) *PrivilegeCollector {
+	return &PrivilegeCollector{
+		BaseCollector: BaseCollector{name: "privilege"},
+		logPaths:      authLogPaths(),
+		tailer:        NewLogTailer(statePath(stateDir, "privilege_tail.json")),
+		journal:       NewJournalReader(statePath(stateDir, "privilege_journal.json"), privilegeJournalMatches),
+	}
+}
+
+// Collect collects sudo and su events logged since the last commit
+func (c *PrivilegeCollector) Collect() (interface{}, error) {
+	logger.Info("Collecting privilege escalation events")
+
+	lines := make([]string, 0)
+	foundLog := false
+
+	for _, logPath := range c.logPaths {
+		if _, err := os.Stat(logPath); err != nil {
+			continue
+		}
+		foundLog = true
+
+		fileLines, err := c.tailer.ReadNew(logPath)
+		if err != nil {
+			logger.Warn("Failed to read " + logPath + ": " + err.Error())
+			continue
+		}
+		lines = append(lines, fileLines...)
+	}
+
+	if !foundLog && runtime.GOOS == "linux" && JournalAvailable() {
+		journalLines, err := c.journal.ReadNew()
+		if err != nil {
+			logger.Warn("Failed to read sudo/su journal: " + err.Error())
+		} else {
+			lines = append(lines, journalLines...)
+		}
+	}
+
+	events := parsePrivilegeLines(lines, time.Now())
+	logger.Info("Found " + fmt.Sprint(len(events)) + " privilege escalation events")
+	return events, nil
+}
+
+// Commit persists the log positions reached by the last Collect
+func (c *PrivilegeCollector) Commit() error {
+	if err := c.tailer.Commit(); err != nil {
+		return err
+	}
+	return c.journal.Commit()
+}
+
+// parsePrivilegeLines extracts sudo and su events from syslog lines
+// su logs the same switch several times (Debian's "(to root)" line and the
+// PAM line), those are merged per PID
+func parsePrivilegeLines(lines []string, now time.Time) []PrivilegeEvent {
+	events := make([]PrivilegeEvent, 0)
+	suEvents := make(map[string]int) // pid/event -> index in events
+
+	for _, line := range lines {
+		record, ok := parseSyslogRecord(line, now)
+		if !ok {
+			continue
+		}
+
+		switch record.Program {
+		case "sudo":
+			if event, ok := parseSudoMessage(record.Message); ok {
+				event.Time = record.Time
+				event.PID = record.PID
+				events = append(events, event)
+			}
+
+		case "su", "su-l":
+			event, ok := parseSuMessage(record.Message)
+			if !ok {
+				continue
+			}
+			event.Time = record.Time
+			event.PID = record.PID
+
+			key := fmt.Sprintf("%d/%s", record.PID, event.Event)
+			if i, seen := suEvents[key]; seen && record.PID != 0 {
+				mergePrivilegeEvent(&events[i], event)
+				continue
+			}
+			suEvents[key] = len(events)
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// parseSudoMessage parses a sudo log message:
+// "alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/apt update"
+// "alice : 3 incorrect password attempts ; TTY=pts/0 ; ... ; COMMAND=/bin/ls"
+// "bob : user NOT in sudoers ; TTY=pts/0 ; ... ; COMMAND=/bin/bash"
+func parseSudoMessage(message string) (PrivilegeEvent, bool) {
+	sep := strings.Index(message, " : ")
+	if sep < 0 {
+		return PrivilegeEvent{}, false
+	}
+
+	event := PrivilegeEvent{
+		Tool:    "sudo",
+		Event:   "command",
+		User:    strings.TrimSpace(message[:sep]),
+		Success: true,
+	}
+	rest := message[sep+3:]
+
+	// COMMAND is always last and may itself contain " ; "
+	if idx := strings.Index(rest, "COMMAND="); idx >= 0 {
+		event.Command = rest[idx+len("COMMAND="):]
+		rest = rest[:idx]
+	}
+
+	fields := 0
+	for _, part := range strings.Split(rest, " ; ") {
+		part = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(part), ";"))
+		if part == "" {
+			continue
+		}
+
+		key, value, hasValue := strings.Cut(part, "=")
+		if hasValue && key == strings.ToUpper(key) {
+			fields++
+			switch key {
+			case "TTY":
+				event.TTY = value
+			case "PWD":
+				event.PWD = value
+			case "USER":
+				event.TargetUser = value
+			}
+			continue
+		}
+
+		// Anything before the KEY=value fields is the reason for a refusal
+		event.Success = false
+		event.Reason = part
+		if m := sudoAttemptsPattern.FindStringSubmatch(part); m != nil {
+			event.Event = "auth_failure"
+			event.Attempts, _ = strconv.Atoi(m[1])
+		} else if strings.Contains(part, "NOT in sudoers") {
+			event.Event = "not_in_sudoers"
+		} else {
+			event.Event = "denied"
+		}
+	}
+
+	if fields == 0 {
+		return PrivilegeEvent{}, false
+	}
+
+	return event, true
+}
+
+// parseSuMessage parses su session openings and failures
+func parseSuMessage(message string) (PrivilegeEvent, bool) {
+	if m := suSessionPattern.FindStringSubmatch(message); m != nil {
+		return PrivilegeEvent{
+			Tool:       "su",
+			Event:      "session_open",
+			TargetUser: m[1],
+			User:       m[2],
+			Success:    true,
+		}, true
+	}
+
+	if m := suDebianPattern.FindStringSubmatch(message); m != nil {
+		event := PrivilegeEvent{
+			Tool:       "su",
+			Event:      "session_open",
+			TargetUser: m[2],
+			User:       m[3],
+			TTY:        m[4],
+			Success:    true,
+		}
+		if m[1] != "" {
+			event.Event = "auth_failure"
+			event.Success = false
+		}
+		return event, true
+	}
+
+	if m := suAuthFailurePattern.FindStringSubmatch(message); m != nil {
+		fields := parseKeyValues(m[1])
+		user := fields["ruser"]
+		if user == "" {
+			user = fields["logname"]
+		}
+		return PrivilegeEvent{
+			Tool:       "su",
+			Event:      "auth_failure",
+			User:       user,
+			TargetUser: fields["user"],
+			TTY:        fields["tty"],
+			Success:    false,
+		}, true
+	}
+
+	return PrivilegeEvent{}, false
+}
+
+// mergePrivilegeEvent fills the empty fields of dst from src
+func mergePrivilegeEvent(dst *PrivilegeEvent, src PrivilegeEvent) {
+	if dst.User == "" {
+		dst.User = src.User
+	}
+	if dst.TargetUser == "" {
+		dst.TargetUser = src.TargetUser
+	}
+	if dst.TTY == "" {
+		dst.TTY = src.TTY
+	}
+}
+
+// parseKeyValues parses space separated key=value pairs as logged by PAM
+func parseKeyValues(s string) map[string]string {
+	values := make(map[string]string)
+	for _, field := range strings.Fields(s) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,137 @@
+package collector
+
+import "testing"
+
+func TestParseSudoMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		ok      bool
+		want    PrivilegeEvent
+	}{
+		{
+			name:    "command",
+			message: "alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/systemctl restart nginx",
+			ok:      true,
+			want: PrivilegeEvent{Tool: "sudo", Event: "command", User: "alice", TargetUser: "root", TTY: "pts/0",
+				PWD: "/home/alice", Command: "/usr/bin/systemctl restart nginx", Success: true},
+		},
+		{
+			name:    "command containing the field separator",
+			message: "alice : TTY=pts/0 ; PWD=/tmp ; USER=root ; COMMAND=/bin/sh -c echo a ; echo b",
+			ok:      true,
+			want: PrivilegeEvent{Tool: "sudo", Event: "command", User: "alice", TargetUser: "root", TTY: "pts/0",
+				PWD: "/tmp", Command: "/bin/sh -c echo a ; echo b", Success: true},
+		},
+		{
+			name:    "incorrect password",
+			message: "alice : 3 incorrect password attempts ; TTY=pts/1 ; PWD=/home/alice ; USER=root ; COMMAND=/bin/bash",
+			ok:      true,
+			want: PrivilegeEvent{Tool: "sudo", Event: "auth_failure", User: "alice", TargetUser: "root", TTY: "pts/1",
+				PWD: "/home/alice", Command: "/bin/bash", Attempts: 3, Reason: "3 incorrect password attempts"},
+		},
+		{
+			name:    "single incorrect password",
+			message: "alice : 1 incorrect password attempt ; TTY=pts/1 ; PWD=/home/alice ; USER=root ; COMMAND=/bin/bash",
+			ok:      true,
+			want: PrivilegeEvent{Tool: "sudo", Event: "auth_failure", User: "alice", TargetUser: "root", TTY: "pts/1",
+				PWD: "/home/alice", Command: "/bin/bash", Attempts: 1, Reason: "1 incorrect password attempt"},
+		},
+		{
+			name:    "not in sudoers",
+			message: "mallory : user NOT in sudoers ; TTY=pts/2 ; PWD=/home/mallory ; USER=root ; COMMAND=/bin/cat /etc/shadow",
+			ok:      true,
+			want: PrivilegeEvent{Tool: "sudo", Event: "not_in_sudoers", User: "mallory", TargetUser: "root", TTY: "pts/2",
+				PWD: "/home/mallory", Command: "/bin/cat /etc/shadow", Reason: "user NOT in sudoers"},
+		},
+		{
+			name:    "command not allowed",
+			message: "bob : command not allowed ; TTY=pts/3 ; PWD=/ ; USER=root ; COMMAND=/usr/bin/passwd",
+			ok:      true,
+			want: PrivilegeEvent{Tool: "sudo", Event: "denied", User: "bob", TargetUser: "root", TTY: "pts/3",
+				PWD: "/", Command: "/usr/bin/passwd", Reason: "command not allowed"},
+		},
+		{
+			name:    "pam line",
+			message: "pam_unix(sudo:session): session opened for user root(uid=0) by alice(uid=1000)",
+		},
+		{
+			name:    "no fields",
+			message: "alice : something happened",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSudoMessage(tt.message)
+			if ok != tt.ok {
+				t.Fatalf("parseSudoMessage() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("parseSudoMessage() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSuMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		ok      bool
+		want    PrivilegeEvent
+	}{
+		{
+			name:    "pam session opened",
+			message: "pam_unix(su-l:session): session opened for user root(uid=0) by alice(uid=1000)",
+			ok:      true,
+			want:    PrivilegeEvent{Tool: "su", Event: "session_open", User: "alice", TargetUser: "root", Success: true},
+		},
+		{
+			name:    "pam session opened without uid",
+			message: "pam_unix(su:session): session opened for user postgres by root",
+			ok:      true,
+			want:    PrivilegeEvent{Tool: "su", Event: "session_open", User: "root", TargetUser: "postgres", Success: true},
+		},
+		{
+			name:    "debian success",
+			message: "(to root) alice on pts/0",
+			ok:      true,
+			want:    PrivilegeEvent{Tool: "su", Event: "session_open", User: "alice", TargetUser: "root", TTY: "pts/0", Success: true},
+		},
+		{
+			name:    "debian failure",
+			message: "FAILED SU (to root) alice on pts/0",
+			ok:      true,
+			want:    PrivilegeEvent{Tool: "su", Event: "auth_failure", User: "alice", TargetUser: "root", TTY: "pts/0"},
+		},
+		{
+			name:    "pam authentication failure",
+			message: "pam_unix(su:auth): authentication failure; logname=alice uid=1000 euid=0 tty=pts/0 ruser=alice rhost=  user=root",
+			ok:      true,
+			want:    PrivilegeEvent{Tool: "su", Event: "auth_failure", User: "alice", TargetUser: "root", TTY: "pts/0"},
+		},
+		{
+			name:    "pam authentication failure without ruser",
+			message: "pam_unix(su-l:auth): authentication failure; logname=bob uid=1001 euid=0 tty=/dev/pts/4 ruser= rhost=  user=root",
+			ok:      true,
+			want:    PrivilegeEvent{Tool: "su", Event: "auth_failure", User: "bob", TargetUser: "root", TTY: "/dev/pts/4"},
+		},
+		{
+			name:    "session closed",
+			message: "pam_unix(su-l:session): session closed for user root",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSuMessage(tt.message)
+			if ok != tt.ok {
+				t.Fatalf("parseSuMessage() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("parseSuMessage() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
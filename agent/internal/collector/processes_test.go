@@ -0,0 +1,24 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicksDuration(t *testing.T) {
+	tests := []struct {
+		ticks uint64
+		want  time.Duration
+	}{
+		{ticks: 0, want: 0},
+		{ticks: 150, want: 1500 * time.Millisecond},
+		{ticks: 9_300_000_000, want: 93_000_000 * time.Second}, // ~2.9 years of uptime
+		{ticks: 31_536_000_001, want: 315_360_000*time.Second + 10*time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := ticksDuration(tt.ticks); got != tt.want {
+			t.Errorf("ticksDuration(%d) = %v, want %v", tt.ticks, got, tt.want)
+		}
+	}
+}
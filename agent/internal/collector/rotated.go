@@ -0,0 +1,261 @@
+package collector
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// fingerprintBytes is how much of a file's head identifies its content
+const fingerprintBytes = 1024
+
+// maxCatchUpBytes limits how much rotated data is read in one catch-up
+var maxCatchUpBytes int64 = 64 * 1024 * 1024
+
+// rotatedSuffixPattern matches logrotate suffixes:
+// auth.log.1, auth.log.2.gz, secure-20261015, secure-20261015.gz
+var rotatedSuffixPattern = regexp.MustCompile(`^(\.\d+|-\d{8}(\d{2})?)(\.gz)?$`)
+
+// rotatedFile is a rotated sibling of a log file
+type rotatedFile struct {
+	path    string
+	inode   uint64
+	modTime int64
+	gzipped bool
+	number  int    // numeric suffix (auth.log.2), 0 if dated
+	date    string // date suffix (secure-20261015), "" if numbered
+}
+
+// rotatedSiblings returns the rotated copies of path in chronological order
+func rotatedSiblings(path string) ([]rotatedFile, error) {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]rotatedFile, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base) {
+			continue
+		}
+
+		suffix := name[len(base):]
+		if !rotatedSuffixPattern.MatchString(suffix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		file := rotatedFile{
+			path:    filepath.Join(dir, name),
+			inode:   fileInode(info),
+			modTime: info.ModTime().UnixNano(),
+			gzipped: strings.HasSuffix(suffix, ".gz"),
+		}
+
+		stem := strings.TrimSuffix(suffix, ".gz")
+		if strings.HasPrefix(stem, "-") {
+			file.date = stem[1:]
+		} else {
+			file.number, _ = strconv.Atoi(stem[1:])
+		}
+
+		files = append(files, file)
+	}
+
+	sortRotated(files)
+	return files, nil
+}
+
+// sortRotated orders rotated copies oldest first
+// When every copy uses the same suffix scheme, the suffix decides: a higher
+// number is older, an earlier date is older. Numbers and dates cannot be
+// compared with each other, so a mix is ordered by modification time alone
+// The path breaks the remaining ties, so the order is always total
+func sortRotated(files []rotatedFile) {
+	numbered, dated := 0, 0
+	for _, file := range files {
+		if file.date != "" {
+			dated++
+		} else {
+			numbered++
+		}
+	}
+
+	key := func(a, b rotatedFile) int {
+		switch {
+		case dated == 0:
+			return b.number - a.number
+		case numbered == 0:
+			return strings.Compare(a.date, b.date)
+		}
+		return 0
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if c := key(a, b); c != 0 {
+			return c < 0
+		}
+		if a.modTime != b.modTime {
+			return a.modTime < b.modTime
+		}
+		return a.path < b.path
+	})
+}
+
+// catchUp reads the lines that were written to path after pos but moved
+// away by rotation before they could be read
+// The rotated copy holding the checkpoint is found by inode or by content
+// fingerprint (for compressed and copytruncate'd copies) and read from the
+// checkpoint offset, every newer copy is read in full
+func catchUp(path string, pos tailPosition) []string {
+	siblings, err := rotatedSiblings(path)
+	if err != nil {
+		logger.Warn("Failed to list rotated logs for " + path + ": " + err.Error())
+		return nil
+	}
+
+	start := -1
+	for i, sibling := range siblings {
+		if sibling.containsCheckpoint(pos) {
+			start = i
+			break
+		}
+	}
+
+	lines := make([]string, 0)
+	budget := maxCatchUpBytes
+
+	readSibling := func(sibling rotatedFile, offset int64) {
+		if budget <= 0 {
+			logger.Warn("Catch-up limit reached, skipping " + sibling.path)
+			return
+		}
+
+		fileLines, n, err := sibling.readFrom(offset, budget)
+		if err != nil {
+			logger.Warn("Failed to read rotated log " + sibling.path + ": " + err.Error())
+			return
+		}
+
+		budget -= n
+		lines = append(lines, fileLines...)
+		logger.Info(fmt.Sprintf("Caught up %d lines from %s", len(fileLines), sibling.path))
+	}
+
+	if start >= 0 {
+		readSibling(siblings[start], pos.Offset)
+		for _, sibling := range siblings[start+1:] {
+			readSibling(sibling, 0)
+		}
+		return lines
+	}
+
+	// The checkpointed file is gone, fall back to modification times.
+	// Without a recorded time there is no way to tell old from new
+	if pos.ModTime == 0 {
+		return lines
+	}
+
+	for _, sibling := range siblings {
+		if sibling.modTime > pos.ModTime {
+			readSibling(sibling, 0)
+		}
+	}
+
+	return lines
+}
+
+// containsCheckpoint reports whether this rotated copy is the file pos was taken from
+func (f rotatedFile) containsCheckpoint(pos tailPosition) bool {
+	if !f.gzipped && pos.Inode != 0 && f.inode == pos.Inode {
+		return true
+	}
+
+	if pos.FingerprintLen == 0 {
+		return false
+	}
+
+	reader, closer, err := f.open()
+	if err != nil {
+		return false
+	}
+	defer closer()
+
+	fp, n, err := fingerprint(reader, pos.FingerprintLen)
+	return err == nil && n == pos.FingerprintLen && fp == pos.Fingerprint
+}
+
+// readFrom reads complete lines after offset, returning the bytes consumed
+func (f rotatedFile) readFrom(offset int64, limit int64) ([]string, int64, error) {
+	reader, closer, err := f.open()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer closer()
+
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, reader, offset); err != nil {
+			if err == io.EOF {
+				return nil, 0, nil
+			}
+			return nil, 0, err
+		}
+	}
+
+	return readLines(reader, limit)
+}
+
+// open opens the file, transparently decompressing gzip files
+func (f rotatedFile) open() (io.Reader, func(), error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !f.gzipped {
+		return file, func() { file.Close() }, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return gz, func() {
+		gz.Close()
+		file.Close()
+	}, nil
+}
+
+// fingerprint hashes up to size bytes from the head of r
+// It returns the hex digest and the number of bytes hashed
+func fingerprint(r io.Reader, size int) (string, int, error) {
+	buf := make([]byte, size)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", 0, err
+	}
+
+	sum := sha256.Sum256(buf[:n])
+	return hex.EncodeToString(sum[:]), n, nil
+}
@@ -0,0 +1,192 @@
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeRotated writes a rotated log, gzip-compressed if the name ends in
+// .gz, with the given modification time
+func writeRotated(t *testing.T, path, content string, modified time.Time) {
+	t.Helper()
+
+	data := []byte(content)
+	if filepath.Ext(path) == ".gz" {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data = buf.Bytes()
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotatedSiblings(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		files map[string]time.Duration // name -> age
+		want  []string
+	}{
+		{
+			name: "numbered and compressed",
+			files: map[string]time.Duration{
+				"auth.log.1":     time.Hour,
+				"auth.log.2.gz":  2 * time.Hour,
+				"auth.log.10.gz": 3 * time.Hour,
+				"auth.log.3.gz":  4 * time.Hour, // touched out of order, the number wins
+			},
+			want: []string{"auth.log.10.gz", "auth.log.3.gz", "auth.log.2.gz", "auth.log.1"},
+		},
+		{
+			name: "dated",
+			files: map[string]time.Duration{
+				"auth.log-20261015":    time.Hour,
+				"auth.log-20261001.gz": time.Minute, // touched out of order, the date wins
+				"auth.log-20261008.gz": 2 * time.Hour,
+			},
+			want: []string{"auth.log-20261001.gz", "auth.log-20261008.gz", "auth.log-20261015"},
+		},
+		{
+			name: "mixed schemes by modification time",
+			files: map[string]time.Duration{
+				"auth.log.1":           time.Hour,
+				"auth.log-20261001.gz": 3 * time.Hour,
+				"auth.log.2.gz":        2 * time.Hour,
+				"auth.log-20261015":    30 * time.Minute,
+			},
+			want: []string{"auth.log-20261001.gz", "auth.log.2.gz", "auth.log.1", "auth.log-20261015"},
+		},
+		{
+			name: "other files ignored",
+			files: map[string]time.Duration{
+				"auth.log.1":     time.Hour,
+				"auth.log.bak":   time.Hour,
+				"auth.log.1.xz":  time.Hour,
+				"auth.logger":    time.Hour,
+				"auth.log-2026":  time.Hour,
+				"other.log.1.gz": time.Hour,
+			},
+			want: []string{"auth.log.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeRotated(t, filepath.Join(dir, "auth.log"), "", now)
+			for name, age := range tt.files {
+				writeRotated(t, filepath.Join(dir, name), "line\n", now.Add(-age))
+			}
+
+			siblings, err := rotatedSiblings(filepath.Join(dir, "auth.log"))
+			if err != nil {
+				t.Fatalf("rotatedSiblings() error = %v", err)
+			}
+			got := make([]string, len(siblings))
+			for i, sibling := range siblings {
+				got[i] = filepath.Base(sibling.path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rotatedSiblings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortRotatedTies(t *testing.T) {
+	files := []rotatedFile{
+		{path: "/var/log/auth.log.1.gz", number: 1, modTime: 5},
+		{path: "/var/log/auth.log.1", number: 1, modTime: 5},
+		{path: "/var/log/auth.log.2", number: 2, modTime: 1},
+	}
+
+	sortRotated(files)
+
+	want := []string{"/var/log/auth.log.2", "/var/log/auth.log.1", "/var/log/auth.log.1.gz"}
+	for i, file := range files {
+		if file.path != want[i] {
+			t.Fatalf("sortRotated() order %d = %s, want %s", i, file.path, want[i])
+		}
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	now := time.Now()
+	checkpointed := "old 1\nold 2\nold 3\n"
+
+	// The checkpoint was taken after "old 1" of a file since rotated twice:
+	// it is now compressed as .2.gz, and .1 holds the file after it
+	fp, fpLen, err := fingerprint(bytes.NewReader([]byte("old 1\n")), fingerprintBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := tailPosition{Inode: 1, Offset: 6, Fingerprint: fp, FingerprintLen: fpLen, ModTime: now.Add(-3 * time.Hour).UnixNano()}
+
+	tests := []struct {
+		name   string
+		budget int64
+		pos    tailPosition
+		want   []string
+	}{
+		{
+			name:   "from the checkpoint in a gzip copy",
+			budget: 1 << 20,
+			pos:    pos,
+			want:   []string{"old 2", "old 3", "mid 1", "mid 2"},
+		},
+		{
+			name:   "budget",
+			budget: 12,
+			pos:    pos,
+			// "old 2" and "old 3" use up the budget, the next file is skipped
+			want: []string{"old 2", "old 3"},
+		},
+		{
+			name:   "checkpointed file gone",
+			budget: 1 << 20,
+			pos:    tailPosition{Inode: 1, Offset: 6, Fingerprint: "gone", FingerprintLen: 6, ModTime: now.Add(-90 * time.Minute).UnixNano()},
+			want:   []string{"mid 1", "mid 2"},
+		},
+		{
+			name:   "checkpointed file gone without a time",
+			budget: 1 << 20,
+			pos:    tailPosition{Inode: 1, Offset: 6, Fingerprint: "gone", FingerprintLen: 6},
+			want:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "auth.log")
+			writeRotated(t, path, "new 1\n", now)
+			writeRotated(t, path+".1", "mid 1\nmid 2\n", now.Add(-time.Hour))
+			writeRotated(t, path+".2.gz", checkpointed, now.Add(-2*time.Hour))
+
+			saved := maxCatchUpBytes
+			maxCatchUpBytes = tt.budget
+			defer func() { maxCatchUpBytes = saved }()
+
+			if got := catchUp(path, tt.pos); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("catchUp() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Socket states of /proc/net/tcp (include/net/tcp_states.h)
+const (
+	tcpEstablished = 0x01
+	tcpListen      = 0x0A
+	udpUnconnected = 0x07 // TCP_CLOSE, a bound UDP socket without a peer
+)
+
+// tcpStateNames are the names of the /proc/net/tcp states
+var tcpStateNames = map[int]string{
+	0x01: "ESTABLISHED",
+	0x02: "SYN_SENT",
+	0x03: "SYN_RECV",
+	0x04: "FIN_WAIT1",
+	0x05: "FIN_WAIT2",
+	0x06: "TIME_WAIT",
+	0x07: "CLOSE",
+	0x08: "CLOSE_WAIT",
+	0x09: "LAST_ACK",
+	0x0A: "LISTEN",
+	0x0B: "CLOSING",
+	0x0C: "NEW_SYN_RECV",
+}
+
+// netSocket is one line of /proc/net/{tcp,tcp6,udp,udp6}
+type netSocket struct {
+	Protocol   string // tcp, tcp6, udp, udp6
+	LocalIP    net.IP
+	LocalPort  int
+	RemoteIP   net.IP
+	RemotePort int
+	State      int
+	UID        int
+	Inode      uint64
+}
+
+// socketProcess is the process owning a socket
+type socketProcess struct {
+	PID  int
+	Name string
+	Exe  string
+}
+
+// readNetSockets reads the sockets of one /proc/net table
+func readNetSockets(procPath, protocol string) ([]netSocket, error) {
+	file, err := os.Open(filepath.Join(procPath, "net", protocol))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseNetSockets(file, protocol)
+}
+
+// parseNetSockets parses a /proc/net/tcp formatted table:
+// "0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000 0 0 12345 ..."
+func parseNetSockets(r io.Reader, protocol string) ([]netSocket, error) {
+	sockets := make([]netSocket, 0)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[0] == "sl" {
+			continue
+		}
+
+		localIP, localPort, err := parseHexAddress(fields[1])
+		if err != nil {
+			continue
+		}
+		remoteIP, remotePort, err := parseHexAddress(fields[2])
+		if err != nil {
+			continue
+		}
+		state, err := strconv.ParseInt(fields[3], 16, 32)
+		if err != nil {
+			continue
+		}
+
+		socket := netSocket{
+			Protocol:   protocol,
+			LocalIP:    localIP,
+			LocalPort:  localPort,
+			RemoteIP:   remoteIP,
+			RemotePort: remotePort,
+			State:      int(state),
+		}
+		socket.UID, _ = strconv.Atoi(fields[7])
+		socket.Inode, _ = strconv.ParseUint(fields[9], 10, 64)
+		sockets = append(sockets, socket)
+	}
+
+	return sockets, scanner.Err()
+}
+
+// parseHexAddress parses "0100007F:0277" (IPv4) or the 32 digit IPv6
+// form, the address is stored as host-endian 32-bit words
+func parseHexAddress(s string) (net.IP, int, error) {
+	address, portHex, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid address %q", s)
+	}
+
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port %q", portHex)
+	}
+
+	raw, err := hex.DecodeString(address)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address %q", address)
+	}
+
+	// Every 32-bit word is little-endian on the architectures we support
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+
+	// Show IPv4-mapped addresses of tcp6 sockets as plain IPv4
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	return ip, int(port), nil
+}
+
+// socketOwners maps socket inodes to their owning process by reading the
+// /proc/[pid]/fd links, only the inodes in wanted are resolved
+// Processes of other users are skipped when the agent is not root
+func socketOwners(procPath string, wanted map[uint64]bool) map[uint64]socketProcess {
+	owners := make(map[uint64]socketProcess)
+	if len(wanted) == 0 {
+		return owners
+	}
+
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return owners
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		fdDir := filepath.Join(procPath, entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+
+		var process *socketProcess
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(link[len("socket:["):], "]"), 10, 64)
+			if err != nil || !wanted[inode] {
+				continue
+			}
+			if _, ok := owners[inode]; ok {
+				continue // shared with another process, keep the first one found
+			}
+
+			if process == nil {
+				process = readSocketProcess(procPath, pid)
+			}
+			owners[inode] = *process
+		}
+	}
+
+	return owners
+}
+
+// readSocketProcess reads the name and executable of a process
+func readSocketProcess(procPath string, pid int) *socketProcess {
+	dir := filepath.Join(procPath, strconv.Itoa(pid))
+	process := &socketProcess{PID: pid}
+
+	if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		process.Name = strings.TrimSpace(string(comm))
+	}
+	if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+		process.Exe = exe
+	}
+	return process
+}
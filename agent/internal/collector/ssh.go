@@ -1,11 +1,9 @@
 package collector
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
-	"regexp"
 	"runtime"
 	"strings"
 	"time"
@@ -24,16 +22,33 @@ type SSHLogin struct {
 	Protocol        string `json:"protocol"`  // usually ssh2
 	SessionDuration int64  `json:"session_duration"` // seconds
 	IsActive        bool   `json:"is_active"`         // currently logged in
+	PID             int    `json:"pid,omitempty"`     // sshd process that logged the entry
+	KeyType         string `json:"key_type,omitempty"`        // publickey logins: ED25519, RSA, RSA-CERT, ...
+	KeyFingerprint  string `json:"key_fingerprint,omitempty"` // publickey logins: SHA256:...
+
+	Timestamp time.Time `json:"-"` // parsed Time, zero if unknown
+}
+
+// SSHData is the result of an SSH collection
+type SSHData struct {
+	Logins   []SSHLogin   `json:"logins"`
+	Sessions []SSHSession `json:"sessions"` // closed this cycle or still open
+
+	BruteForce []BruteForceEvent `json:"bruteforce"` // thresholds crossed this cycle
 }
 
 // SSHCollector collects SSH login information
 type SSHCollector struct {
 	BaseCollector
 	logPaths []string
+	tailer   *LogTailer
+	journal  *JournalReader // used when no log file exists (journald-only hosts)
+	sessions *sessionTracker
+	detector *bruteForceDetector
 }
 
-// NewSSHCollector creates a new SSH collector
-func NewSSHCollector() *SSHCollector {
+// authLogPaths returns the syslog files that may hold authentication events
+func authLogPaths() []string {
 	paths := []string{
 		"/var/log/auth.log",      // Debian/Ubuntu
 		"/var/log/secure",        // CentOS/RHEL/Amazon Linux
@@ -48,10 +63,34 @@ func NewSSHCollector() *SSHCollector {
 		}, paths...)
 	}
 
+	return paths
+}
+
+// NewSSHCollector creates a new SSH collector
+// Read positions are persisted under stateDir so that each log line is
+// reported once across restarts
+func NewSSHCollector(stateDir string, bruteForce BruteForceConfig) *SSHCollector {
 	return &SSHCollector{
 		BaseCollector: BaseCollector{name: "ssh"},
-		logPaths:      paths,
+		logPaths:      authLogPaths(),
+		tailer:        NewLogTailer(statePath(stateDir, "ssh_tail.json")),
+		journal:       NewJournalReader(statePath(stateDir, "ssh_journal.json"), sshJournalMatches),
+		sessions:      newSessionTracker(statePath(stateDir, "ssh_sessions.json")),
+		detector:      newBruteForceDetector(bruteForce),
+	}
+}
+
+// Commit persists the log positions reached by the last Collect
+// It should be called once the collected data has been delivered
+func (c *SSHCollector) Commit() error {
+	if err := c.tailer.Commit(); err != nil {
+		return err
+	}
+	if err := c.journal.Commit(); err != nil {
+		return err
 	}
+	c.detector.commit()
+	return c.sessions.commit()
 }
 
 // Collect collects SSH login information
@@ -59,10 +98,14 @@ func (c *SSHCollector) Collect() (interface{}, error) {
 	logger.Info("Collecting SSH login information")
 
 	logins := make([]SSHLogin, 0)
+	c.sessions.reset()
+	c.detector.reset()
 
 	// Try each log path
+	foundLog := false
 	for _, logPath := range c.logPaths {
 		if _, err := os.Stat(logPath); err == nil {
+			foundLog = true
 			fileLogins, err := c.parseLogFile(logPath)
 			if err != nil {
 				logger.Warn("Failed to parse " + logPath + ": " + err.Error())
@@ -73,9 +116,39 @@ func (c *SSHCollector) Collect() (interface{}, error) {
 		}
 	}
 
+	// journald-only hosts (recent Debian, Fedora, Arch) have no auth log file
+	if !foundLog && runtime.GOOS == "linux" && JournalAvailable() {
+		lines, err := c.journal.ReadNew()
+		if err != nil {
+			logger.Warn("Failed to read sshd journal: " + err.Error())
+		} else {
+			journalLogins := c.parseLines(lines)
+			logins = append(logins, journalLogins...)
+			logger.Info("Found " + fmt.Sprint(len(journalLogins)) + " SSH log entries in journal")
+		}
+	}
+
+	// Close sessions whose sshd went away without logging a disconnect
+	now := time.Now()
+	c.sessions.expireStale(now)
+	c.detector.expire(now)
+
+	bruteForce := c.detector.detected()
+	for _, event := range bruteForce {
+		logger.Warn(fmt.Sprintf("SSH brute force detected: %s %s%s, %d failures, %d usernames",
+			event.Kind, event.IP, event.User, event.Failures, event.DistinctUsers))
+	}
+	logins = c.detector.summarize(logins)
+
 	// Collect active sessions and calculate durations
 	logins = c.enrichWithActiveSessions(logins)
 
+	result := &SSHData{
+		Logins:     logins,
+		Sessions:   c.sessions.sessions(now),
+		BruteForce: bruteForce,
+	}
+
 	// If no log entries found but we have active sessions, return those
 	// This handles systems like macOS where SSH logs are in binary format
 	if len(logins) == 0 {
@@ -83,148 +156,50 @@ func (c *SSHCollector) Collect() (interface{}, error) {
 		activeSessions := c.collectActiveSessions()
 		if len(activeSessions) > 0 {
 			logger.Info("Returning " + fmt.Sprint(len(activeSessions)) + " active sessions")
-			return activeSessions, nil
+			result.Logins = activeSessions
 		}
 	}
 
-	logger.Info("Total SSH log entries collected: " + fmt.Sprint(len(logins)))
-	return logins, nil
+	logger.Info(fmt.Sprintf("Total SSH log entries collected: %d, sessions: %d",
+		len(result.Logins), len(result.Sessions)))
+	return result, nil
 }
 
-// parseLogFile parses an SSH log file
+// parseLogFile parses the SSH log lines appended to a log file since the last commit
 func (c *SSHCollector) parseLogFile(logPath string) ([]SSHLogin, error) {
-	file, err := os.Open(logPath)
+	lines, err := c.tailer.ReadNew(logPath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
+	return c.parseLines(lines), nil
+}
+
+// parseLines parses SSH login entries from syslog lines
+// Every sshd line is also fed to the session tracker
+func (c *SSHCollector) parseLines(lines []string) []SSHLogin {
 	logins := make([]SSHLogin, 0)
-	scanner := bufio.NewScanner(file)
-
-	// Regular expressions for different log formats
-	// Ubuntu/Debian format: Jan 30 10:00:00 hostname sshd[1234]: Accepted password for root from 1.2.3.4 port 22 ssh2
-	acceptedPattern := regexp.MustCompile(
-		`(\w+\s+\d+\s+\d+:\d+:\d+).*sshd\[\d+\]:\s+(Accepted|Failed)\s+(\w+)\s+for\s+(\w+)\s+from\s+([\d.]+)\s+port\s+(\d+)`,
-	)
-
-	// CentOS/RHEL format: Jan 30 10:00:00 hostname sshd[1234]: Failed password for root from 1.2.3.4 port 22 ssh2
-	// Also handle publickey auth
-	publickeyPattern := regexp.MustCompile(
-		`(\w+\s+\d+\s+\d+:\d+:\d+).*sshd\[\d+\]:\s+(Accepted|Failed)\s+publickey\s+for\s+(\w+)\s+from\s+([\d.]+)\s+port\s+(\d+)`,
-	)
-
-	// Invalid user pattern
-	invalidUserPattern := regexp.MustCompile(
-		`(\w+\s+\d+\s+\d+:\d+:\d+).*sshd\[\d+\]:\s+(Invalid user)\s+(\w+)\s+from\s+([\d.]+)\s+port\s+(\d+)`,
-	)
-
-	// Get current year for adding to log times
-	currentYear := time.Now().Year()
-
-	// Parse line by line (only last 1000 lines for performance)
-	lines := make([]string, 0)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-		if len(lines) > 1000 {
-			lines = lines[1:]
-		}
-	}
+	now := time.Now()
 
 	// Process from oldest to newest
 	for _, line := range lines {
-		// Try accepted/failed pattern
-		matches := acceptedPattern.FindStringSubmatch(line)
-		if len(matches) >= 7 {
-			login := c.parseLoginFromMatches(matches, true)
-			// Add year to time
-			login.Time = fmt.Sprintf("%d %s", currentYear, login.Time)
-			logins = append(logins, login)
+		record, ok := parseSyslogLine(line, now)
+		if !ok {
 			continue
 		}
 
-		// Try publickey pattern
-		matches = publickeyPattern.FindStringSubmatch(line)
-		if len(matches) >= 6 {
-			login := SSHLogin{
-				Time:     matches[1],
-				User:     matches[4],
-				IP:       matches[5],
-				Method:   "publickey",
-				Success:  strings.Contains(matches[2], "Accepted"),
-				Port:     c.parseInt(matches[6]),
-				Protocol: "ssh2",
-			}
-			// Add year to time
-			login.Time = fmt.Sprintf("%d %s", currentYear, login.Time)
-			logins = append(logins, login)
+		login, ok := loginFromRecord(record)
+		if !ok {
+			c.sessions.observe(record, nil)
 			continue
 		}
 
-		// Try invalid user pattern
-		matches = invalidUserPattern.FindStringSubmatch(line)
-		if len(matches) >= 6 {
-			login := SSHLogin{
-				Time:     matches[1],
-				User:     matches[3], // Invalid username
-				IP:       matches[4],
-				Method:   "password",
-				Success:  false,
-				Port:     c.parseInt(matches[5]),
-				Protocol: "ssh2",
-			}
-			// Add year to time
-			login.Time = fmt.Sprintf("%d %s", currentYear, login.Time)
-			logins = append(logins, login)
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	// Filter logins from last 15 minutes (to avoid sending too much data)
-	filteredLogins := c.filterRecentLogins(logins, 15*time.Minute)
-
-	return filteredLogins, nil
-}
-
-// parseLoginFromMatches parses login from regex matches
-func (c *SSHCollector) parseLoginFromMatches(matches []string, hasMethod bool) SSHLogin {
-	login := SSHLogin{
-		Time:     matches[1],
-		User:     matches[4],
-		IP:       matches[5],
-		Method:   "password",
-		Success:  strings.Contains(matches[2], "Accepted"),
-		Port:     c.parseInt(matches[6]),
-		Protocol: "ssh2",
-	}
-
-	if hasMethod && len(matches) > 3 {
-		login.Method = matches[3]
-	}
-
-	return login
-}
-
-// filterRecentLogins filters logins to only include recent ones
-func (c *SSHCollector) filterRecentLogins(logins []SSHLogin, duration time.Duration) []SSHLogin {
-	recentLogins := make([]SSHLogin, 0)
-	cutoffTime := time.Now().Add(-duration)
-
-	for _, login := range logins {
-		// Parse time from log format (e.g., "Jan 30 10:00:00")
-		// Add current year since log doesn't include it
-		t, err := time.Parse("2006 Jan 2 15:04:05",
-			fmt.Sprintf("%d %s", time.Now().Year(), login.Time))
-
-		if err == nil && t.After(cutoffTime) {
-			recentLogins = append(recentLogins, login)
-		}
+		c.sessions.observe(record, &login)
+		c.detector.observe(record, login)
+		logins = append(logins, login)
 	}
 
-	return recentLogins
+	return logins
 }
 
 // parseInt safely parses an integer
@@ -282,16 +257,23 @@ func (c *SSHCollector) enrichWithActiveSessions(logins []SSHLogin) []SSHLogin {
 			continue
 		}
 
-		// Check if this login is currently active
+		// Prefer the session paired by sshd PID
+		if session, ok := c.sessions.lookup(logins[i]); ok {
+			logins[i].IsActive = session.End.IsZero()
+			logins[i].SessionDuration = session.Duration
+			if logins[i].IsActive {
+				logins[i].SessionDuration = c.calculateDurationFromTime(logins[i].Timestamp)
+			}
+			continue
+		}
+
+		// Fall back to matching `who` output by user@ip
 		key := logins[i].User + "@" + logins[i].IP
 		if activeSessionMap[key] {
 			logins[i].IsActive = true
-			// Calculate duration from login time to now
-			logins[i].SessionDuration = c.calculateDurationFromLogin(logins[i].Time)
+			logins[i].SessionDuration = c.calculateDurationFromTime(logins[i].Timestamp)
 		} else {
 			logins[i].IsActive = false
-			// For completed sessions, we'd need to parse logout events
-			// This is more complex and would require additional log parsing
 			logins[i].SessionDuration = 0
 		}
 	}
@@ -299,8 +281,18 @@ func (c *SSHCollector) enrichWithActiveSessions(logins []SSHLogin) []SSHLogin {
 	return logins
 }
 
-// collectActiveSessions collects currently active SSH sessions using `who` command
+// collectActiveSessions collects currently active SSH sessions
+// On Linux utmp is read directly, `who` is only used where that fails
 func (c *SSHCollector) collectActiveSessions() []SSHLogin {
+	if runtime.GOOS == "linux" {
+		sessions, err := c.collectUtmpSessions()
+		if err == nil {
+			logger.Info("Found " + fmt.Sprint(len(sessions)) + " active sessions")
+			return sessions
+		}
+		logger.Warn("Failed to read utmp, falling back to who: " + err.Error())
+	}
+
 	sessions := make([]SSHLogin, 0)
 
 	// Try `who -u` command first
@@ -336,6 +328,39 @@ func (c *SSHCollector) collectActiveSessions() []SSHLogin {
 	return sessions
 }
 
+// collectUtmpSessions returns the remote sessions recorded in utmp
+func (c *SSHCollector) collectUtmpSessions() ([]SSHLogin, error) {
+	records, err := activeUtmpSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]SSHLogin, 0)
+	for _, record := range records {
+		// Only remote sessions carry an address
+		ip := record.RemoteIP()
+		if ip == "" {
+			continue
+		}
+
+		session := SSHLogin{
+			User:            record.User,
+			IP:              ip,
+			Time:            record.Time.Format("2006-01-02 15:04:05"),
+			Success:         true,
+			IsActive:        true,
+			Protocol:        "ssh2",
+			SessionDuration: c.calculateDurationFromTime(record.Time),
+			Timestamp:       record.Time,
+		}
+		logger.Debug("Parsed SSH session: user=%s ip=%s time=%s duration=%d active=%v",
+			session.User, session.IP, session.Time, session.SessionDuration, session.IsActive)
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
 // parseWhoLine parses a line from `who` or `w` command output
 func (c *SSHCollector) parseWhoLine(line string) SSHLogin {
 	fields := strings.Fields(line)
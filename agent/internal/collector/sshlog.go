@@ -0,0 +1,250 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Syslog header formats
+var (
+	// Traditional BSD syslog: "Jan 30 10:00:00 hostname rest"
+	bsdSyslogPattern = regexp.MustCompile(
+		`^([A-Z][a-z]{2}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2})\s+(\S+)\s+(.*)$`,
+	)
+
+	// rsyslog RFC3339 high-precision template:
+	// "2026-10-15T10:00:00.123456+02:00 hostname rest"
+	rfc3339SyslogPattern = regexp.MustCompile(
+		`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2}))\s+(\S+)\s+(.*)$`,
+	)
+
+	// Program tag, optionally preceded by a busybox syslogd "facility.level"
+	// field (Alpine/OpenRC): "auth.info sshd[1234]: message"
+	// The PID is optional, older sudo logs as plain "sudo:"
+	programTagPattern = regexp.MustCompile(
+		`^(?:[a-z0-9]+\.[a-z]+\s+)?([\w.-]+)(?:\[(\d+)\])?:\s+(.*)$`,
+	)
+)
+
+// sshd message formats
+// Addresses are IPv4 or IPv6 literals (optionally with a zone). User names
+// may contain dashes, dots, @ and spaces, and are chosen by the client for
+// failed logins, so the address is always taken from the last
+// "from <ip> port <n>" of the message: a name such as
+// "x from 10.0.0.1 port 1" must not supply the source address
+var (
+	// "Accepted password for root from 1.2.3.4 port 22 ssh2"
+	// "Failed password for invalid user deploy-bot from 2001:db8::1 port 22 ssh2"
+	// "Failed none for invalid user john.doe from 1.2.3.4 port 22 ssh2"
+	// "Accepted keyboard-interactive/pam for alice@corp from 1.2.3.4 port 22 ssh2"
+	authResultPattern = regexp.MustCompile(
+		`^(Accepted|Failed)\s+(\S+)\s+for\s+(invalid user\s+)?(.*)\s+from\s+([0-9A-Fa-f:.]+(?:%[\w.-]+)?)\s+port\s+(\d+)(?:\s+ssh2(?::\s*(.*?))?)?\s*$`,
+	)
+
+	// Key of a publickey login, matched against what follows the final
+	// "port <n> ssh2:" so the user name cannot supply a fingerprint
+	// "ED25519 SHA256:bJ1HtmqVwWeJ2AQZ3ul8T2i4zdAZu2gdd1fA9aXq3eo"
+	// "RSA-CERT SHA256:... ID alice (serial 1) CA ED25519 SHA256:..."
+	// OpenSSH before 6.8 logs MD5 fingerprints ("RSA 3f:a2:...")
+	keyFingerprintPattern = regexp.MustCompile(`^([A-Z0-9-]+)\s+(\S+)(?:\s.*)?$`)
+
+	// "Invalid user admin from 1.2.3.4 port 22"
+	// "Invalid user admin from 1.2.3.4" (OpenSSH < 7.5 has no port)
+	invalidUserPattern = regexp.MustCompile(
+		`^Invalid user\s+(.*)\s+from\s+([0-9A-Fa-f:.]+(?:%[\w.-]+)?)(?:\s+port\s+(\d+))?\s*$`,
+	)
+)
+
+// sshLogTimeFormat is the format of SSHLogin.Time
+const sshLogTimeFormat = "2006 Jan 2 15:04:05"
+
+// syslogRecord is a parsed syslog line
+type syslogRecord struct {
+	Time     time.Time
+	Hostname string
+	Program  string
+	PID      int
+	Message  string
+}
+
+// parseSyslogLine splits an sshd syslog line into its header fields and
+// message. It returns false for lines not written by sshd
+func parseSyslogLine(line string, now time.Time) (syslogRecord, bool) {
+	record, ok := parseSyslogRecord(line, now)
+	if !ok || (record.Program != "sshd" && record.Program != "sshd-session") {
+		return syslogRecord{}, false
+	}
+	return record, true
+}
+
+// parseSyslogRecord splits a syslog line into its header fields and message
+func parseSyslogRecord(line string, now time.Time) (syslogRecord, bool) {
+	var record syslogRecord
+	var rest string
+
+	if m := rfc3339SyslogPattern.FindStringSubmatch(line); m != nil {
+		t, err := time.Parse(time.RFC3339Nano, normalizeRFC3339Offset(m[1]))
+		if err != nil {
+			return record, false
+		}
+		record.Time = t.Local()
+		record.Hostname = m[2]
+		rest = m[3]
+	} else if m := bsdSyslogPattern.FindStringSubmatch(line); m != nil {
+		t, ok := parseBSDTimestamp(m[1], now)
+		if !ok {
+			return record, false
+		}
+		record.Time = t
+		record.Hostname = m[2]
+		rest = m[3]
+	} else {
+		return record, false
+	}
+
+	m := programTagPattern.FindStringSubmatch(rest)
+	if m == nil {
+		return record, false
+	}
+
+	record.Program = m[1]
+	record.PID, _ = strconv.Atoi(m[2])
+	record.Message = m[3]
+	return record, true
+}
+
+// parseBSDTimestamp parses "Jan _2 15:04:05", which carries no year
+// The year is taken from now, or the previous year if that would put the
+// entry more than a day in the future (log written before New Year)
+func parseBSDTimestamp(stamp string, now time.Time) (time.Time, bool) {
+	t, err := time.ParseInLocation("2006 Jan _2 15:04:05",
+		strconv.Itoa(now.Year())+" "+collapseSpaces(stamp), now.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+
+	return t, true
+}
+
+// parseSSHMessage turns an sshd message into a login entry
+// It returns false for messages that are not authentication results
+func parseSSHMessage(message string) (SSHLogin, bool) {
+	if m := authResultPattern.FindStringSubmatch(message); m != nil {
+		port, _ := strconv.Atoi(m[6])
+		login := SSHLogin{
+			User:     sanitizeLogField(m[4]),
+			IP:       m[5],
+			Method:   sanitizeLogField(m[2]),
+			Success:  m[1] == "Accepted",
+			Port:     port,
+			Protocol: "ssh2",
+		}
+		if k := keyFingerprintPattern.FindStringSubmatch(m[7]); k != nil {
+			login.KeyType = k[1]
+			login.KeyFingerprint = k[2]
+		}
+		return login, true
+	}
+
+	if m := invalidUserPattern.FindStringSubmatch(message); m != nil {
+		port, _ := strconv.Atoi(m[3])
+		return SSHLogin{
+			User:     sanitizeLogField(m[1]),
+			IP:       m[2],
+			Method:   "password",
+			Success:  false,
+			Port:     port,
+			Protocol: "ssh2",
+		}, true
+	}
+
+	return SSHLogin{}, false
+}
+
+// parseSSHLogLine parses a single auth log line into a login entry
+func parseSSHLogLine(line string, now time.Time) (SSHLogin, bool) {
+	record, ok := parseSyslogLine(line, now)
+	if !ok {
+		return SSHLogin{}, false
+	}
+
+	return loginFromRecord(record)
+}
+
+// loginFromRecord builds the login entry carried by an sshd record
+func loginFromRecord(record syslogRecord) (SSHLogin, bool) {
+	login, ok := parseSSHMessage(record.Message)
+	if !ok {
+		return SSHLogin{}, false
+	}
+
+	login.Time = record.Time.Format(sshLogTimeFormat)
+	login.Timestamp = record.Time
+	login.PID = record.PID
+	return login, true
+}
+
+// sanitizeLogField escapes control characters and invalid UTF-8 in a value
+// taken from a log message, user names are chosen by the client and must
+// not carry terminal escapes or line breaks into reports
+// "\x1b[0m\tadmin" becomes `\x1b[0m\x09admin`
+func sanitizeLogField(s string) string {
+	clean := true
+	for _, r := range s {
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(&b, "\\x%02x", s[i])
+		case r < 0x80 && unicode.IsControl(r):
+			fmt.Fprintf(&b, "\\x%02x", r)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, "\\u%04x", r)
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// normalizeRFC3339Offset turns a "+0200" offset into "+02:00"
+func normalizeRFC3339Offset(stamp string) string {
+	n := len(stamp)
+	if n > 5 && (stamp[n-5] == '+' || stamp[n-5] == '-') {
+		return stamp[:n-2] + ":" + stamp[n-2:]
+	}
+	return stamp
+}
+
+// collapseSpaces replaces runs of spaces with a single space, syslog pads
+// single-digit days ("Jan  5")
+func collapseSpaces(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' && len(out) > 0 && out[len(out)-1] == ' ' {
+			continue
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
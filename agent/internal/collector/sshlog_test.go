@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSSHLogLine(t *testing.T) {
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		line string
+		ok   bool
+		want SSHLogin
+	}{
+		{
+			name: "debian accepted publickey",
+			line: "Oct 15 10:02:11 web-01 sshd[2143]: Accepted publickey for deploy from 192.0.2.10 port 51514 ssh2: ED25519 SHA256:bJ1HtmqVwWeJ2AQZ3ul8T2i4zdAZu2gdd1fA9aXq3eo",
+			ok:   true,
+			want: SSHLogin{User: "deploy", IP: "192.0.2.10", Method: "publickey", Success: true, Port: 51514, PID: 2143,
+				KeyType: "ED25519", KeyFingerprint: "SHA256:bJ1HtmqVwWeJ2AQZ3ul8T2i4zdAZu2gdd1fA9aXq3eo"},
+		},
+		{
+			name: "debian rsyslog high precision timestamp",
+			line: "2026-10-15T10:02:11.482913+02:00 web-01 sshd[2143]: Accepted password for root from 192.0.2.10 port 51514 ssh2",
+			ok:   true,
+			want: SSHLogin{User: "root", IP: "192.0.2.10", Method: "password", Success: true, Port: 51514, PID: 2143},
+		},
+		{
+			name: "debian sshd-session",
+			line: "Oct 15 10:02:11 web-01 sshd-session[2144]: Failed password for alice from 198.51.100.7 port 40022 ssh2",
+			ok:   true,
+			want: SSHLogin{User: "alice", IP: "198.51.100.7", Method: "password", Port: 40022, PID: 2144},
+		},
+		{
+			name: "rhel failed password invalid user",
+			line: "Oct  5 03:14:07 rhel9 sshd[88121]: Failed password for invalid user oracle from 203.0.113.9 port 4242 ssh2",
+			ok:   true,
+			want: SSHLogin{User: "oracle", IP: "203.0.113.9", Method: "password", Port: 4242, PID: 88121},
+		},
+		{
+			name: "rhel keyboard-interactive",
+			line: "Oct  5 03:14:07 rhel9 sshd[88122]: Accepted keyboard-interactive/pam for alice@corp from 2001:db8::1 port 2222 ssh2",
+			ok:   true,
+			want: SSHLogin{User: "alice@corp", IP: "2001:db8::1", Method: "keyboard-interactive/pam", Success: true, Port: 2222, PID: 88122},
+		},
+		{
+			name: "rhel invalid user",
+			line: "Oct  5 03:14:06 rhel9 sshd[88121]: Invalid user oracle from 203.0.113.9 port 4242",
+			ok:   true,
+			want: SSHLogin{User: "oracle", IP: "203.0.113.9", Method: "password", Port: 4242, PID: 88121},
+		},
+		{
+			name: "amazon linux certificate login",
+			line: "Oct 15 09:00:01 ip-172-31-5-10 sshd[3310]: Accepted publickey for ec2-user from 10.1.2.3 port 60122 ssh2: RSA-CERT SHA256:Zm9vYmFyYmF6 ID ec2-user (serial 7) CA ED25519 SHA256:Q0FrZXk",
+			ok:   true,
+			want: SSHLogin{User: "ec2-user", IP: "10.1.2.3", Method: "publickey", Success: true, Port: 60122, PID: 3310,
+				KeyType: "RSA-CERT", KeyFingerprint: "SHA256:Zm9vYmFyYmF6"},
+		},
+		{
+			name: "amazon linux failed none",
+			line: "Oct 15 09:00:02 ip-172-31-5-10 sshd[3311]: Failed none for invalid user john.doe from 10.1.2.4 port 60123 ssh2",
+			ok:   true,
+			want: SSHLogin{User: "john.doe", IP: "10.1.2.4", Method: "none", Port: 60123, PID: 3311},
+		},
+		{
+			name: "alpine busybox syslogd",
+			line: "Oct 15 08:30:00 alpine auth.info sshd[912]: Accepted password for root from 192.168.1.20 port 50100 ssh2",
+			ok:   true,
+			want: SSHLogin{User: "root", IP: "192.168.1.20", Method: "password", Success: true, Port: 50100, PID: 912},
+		},
+		{
+			name: "alpine invalid user without port",
+			line: "Oct 15 08:30:01 alpine auth.info sshd[913]: Invalid user admin from 192.168.1.21",
+			ok:   true,
+			want: SSHLogin{User: "admin", IP: "192.168.1.21", Method: "password", PID: 913},
+		},
+		{
+			name: "user name spoofing the source address",
+			line: "Oct 15 10:02:11 web-01 sshd[2145]: Failed password for invalid user x from 10.0.0.1 port 1 from 203.0.113.9 port 4242 ssh2",
+			ok:   true,
+			want: SSHLogin{User: "x from 10.0.0.1 port 1", IP: "203.0.113.9", Method: "password", Port: 4242, PID: 2145},
+		},
+		{
+			name: "invalid user spoofing the source address",
+			line: "Oct 15 10:02:10 web-01 sshd[2145]: Invalid user x from 10.0.0.1 port 1 from 203.0.113.9 port 4242",
+			ok:   true,
+			want: SSHLogin{User: "x from 10.0.0.1 port 1", IP: "203.0.113.9", Method: "password", Port: 4242, PID: 2145},
+		},
+		{
+			name: "user name spoofing a key fingerprint",
+			line: "Oct 15 10:02:11 web-01 sshd[2146]: Failed password for invalid user a ssh2: RSA SHA256:fake from 203.0.113.9 port 4242 ssh2",
+			ok:   true,
+			want: SSHLogin{User: "a ssh2: RSA SHA256:fake", IP: "203.0.113.9", Method: "password", Port: 4242, PID: 2146},
+		},
+		{
+			name: "user name spoofing a fingerprint before the real one",
+			line: "Oct 15 10:02:11 web-01 sshd[2147]: Failed publickey for invalid user a from 10.0.0.1 port 1 ssh2: RSA SHA256:fake from 203.0.113.9 port 4242 ssh2: ED25519 SHA256:real",
+			ok:   true,
+			want: SSHLogin{User: "a from 10.0.0.1 port 1 ssh2: RSA SHA256:fake", IP: "203.0.113.9", Method: "publickey", Port: 4242, PID: 2147,
+				KeyType: "ED25519", KeyFingerprint: "SHA256:real"},
+		},
+		{
+			name: "control characters in the user name",
+			line: "Oct 15 10:02:11 web-01 sshd[2148]: Invalid user \x1b]0;pwned\x07ad\xffmin from 203.0.113.9 port 4242",
+			ok:   true,
+			want: SSHLogin{User: `\x1b]0;pwned\x07ad\xffmin`, IP: "203.0.113.9", Method: "password", Port: 4242, PID: 2148},
+		},
+		{
+			name: "other program",
+			line: "Oct 15 10:02:11 web-01 sudo[2146]: Accepted password for root from 192.0.2.10 port 51514 ssh2",
+		},
+		{
+			name: "not an authentication result",
+			line: "Oct 15 10:02:11 web-01 sshd[2143]: pam_unix(sshd:session): session opened for user deploy(uid=1000) by (uid=0)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSSHLogLine(tt.line, now)
+			if ok != tt.ok {
+				t.Fatalf("parseSSHLogLine() ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+
+			got.Time = ""
+			got.Timestamp = time.Time{}
+			tt.want.Protocol = "ssh2"
+			if got != tt.want {
+				t.Errorf("parseSSHLogLine() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyslogTimestamps(t *testing.T) {
+	now := time.Date(2026, time.January, 1, 0, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		line string
+		want time.Time
+	}{
+		{
+			name: "bsd timestamp from the previous year",
+			line: "Dec 31 23:59:58 web-01 sshd[1]: Accepted password for root from 192.0.2.1 port 1 ssh2",
+			want: time.Date(2025, time.December, 31, 23, 59, 58, 0, time.UTC),
+		},
+		{
+			name: "padded single digit day",
+			line: "Jan  1 00:10:00 web-01 sshd[1]: Accepted password for root from 192.0.2.1 port 1 ssh2",
+			want: time.Date(2026, time.January, 1, 0, 10, 0, 0, time.UTC),
+		},
+		{
+			name: "rfc3339 offset without colon",
+			line: "2026-01-01T01:10:00+0100 web-01 sshd[1]: Accepted password for root from 192.0.2.1 port 1 ssh2",
+			want: time.Date(2026, time.January, 1, 0, 10, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, ok := parseSyslogLine(tt.line, now)
+			if !ok {
+				t.Fatal("parseSyslogLine() failed")
+			}
+			if !record.Time.Equal(tt.want) {
+				t.Errorf("Time = %v, want %v", record.Time, tt.want)
+			}
+		})
+	}
+}
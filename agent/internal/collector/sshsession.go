@@ -0,0 +1,283 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+// SSHSession represents an SSH session from login to disconnect
+type SSHSession struct {
+	PID              int       `json:"pid"` // sshd process that logged the session
+	User             string    `json:"user"`
+	IP               string    `json:"ip"`
+	Port             int       `json:"port"` // client source port
+	Method           string    `json:"method"`
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`               // zero while active
+	Duration         int64     `json:"duration"`          // seconds
+	DisconnectReason string    `json:"disconnect_reason"` // empty while active
+	IsActive         bool      `json:"is_active"`
+}
+
+// sshd session lifecycle messages
+// The pam session line is logged under the sshd PID that logged the
+// matching "Accepted" line, the others usually come from the post-auth
+// child process and are matched on the client address and port
+var (
+	// "Received disconnect from 1.2.3.4 port 50000:11: disconnected by user"
+	receivedDisconnectPattern = regexp.MustCompile(
+		`^Received disconnect from ([0-9A-Fa-f:.]+(?:%[\w.-]+)?) port (\d+):(\d+):\s*(.*?)\s*$`,
+	)
+
+	// "Disconnected from user alice 1.2.3.4 port 50000"
+	disconnectedPattern = regexp.MustCompile(
+		`^Disconnected from user (.*) ([0-9A-Fa-f:.]+(?:%[\w.-]+)?) port (\d+)\s*$`,
+	)
+
+	// "pam_unix(sshd:session): session closed for user alice"
+	sessionClosedPattern = regexp.MustCompile(
+		`^pam_unix\(sshd:session\): session closed for user (\S+)`,
+	)
+
+	// "Timeout, client not responding from user alice 1.2.3.4 port 50000"
+	// "Timeout, client not responding." (OpenSSH < 8.0)
+	timeoutPattern = regexp.MustCompile(
+		`^Timeout, client not responding(?: from user (.*) ([0-9A-Fa-f:.]+(?:%[\w.-]+)?) port (\d+))?`,
+	)
+
+	// "Connection reset by 1.2.3.4 port 50000"
+	// "Connection closed by user alice 1.2.3.4 port 50000"
+	connectionEndedPattern = regexp.MustCompile(
+		`^Connection (reset|closed) by (?:(?:authenticating |invalid )?user (.*) )?([0-9A-Fa-f:.]+(?:%[\w.-]+)?) port (\d+)`,
+	)
+)
+
+// sessionState is the persisted part of the tracker
+type sessionState struct {
+	Open map[int]*SSHSession `json:"open"` // open sessions by sshd PID
+}
+
+// sessionTracker pairs "Accepted" lines with the disconnect lines of the
+// same session, by sshd PID or client address
+// Like LogTailer it works on a copy of the committed state, so re-reading
+// the same lines after a failed report does not apply them twice
+type sessionTracker struct {
+	statePath string
+	committed sessionState
+	working   sessionState
+	closed    []SSHSession
+	mu        sync.Mutex
+}
+
+// newSessionTracker creates a tracker that persists open sessions to statePath
+func newSessionTracker(statePath string) *sessionTracker {
+	t := &sessionTracker{
+		statePath: statePath,
+		committed: sessionState{Open: make(map[int]*SSHSession)},
+	}
+
+	if err := state.Load(statePath, &t.committed); err != nil {
+		logger.Warn("Failed to load SSH session state, starting fresh: " + err.Error())
+	}
+	if t.committed.Open == nil {
+		t.committed.Open = make(map[int]*SSHSession)
+	}
+
+	t.reset()
+	return t
+}
+
+// reset starts a new collection cycle from the committed state
+func (t *sessionTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.working = sessionState{Open: make(map[int]*SSHSession, len(t.committed.Open))}
+	for pid, session := range t.committed.Open {
+		copied := *session
+		t.working.Open[pid] = &copied
+	}
+	t.closed = nil
+}
+
+// observe feeds one sshd record to the tracker
+// login is the parsed entry when the record is an authentication result
+func (t *sessionTracker) observe(record syslogRecord, login *SSHLogin) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pid := record.PID
+
+	if login != nil {
+		if !login.Success {
+			return
+		}
+
+		// A new login under a PID we still consider open means we missed
+		// the end of the previous session
+		if previous, ok := t.working.Open[pid]; ok {
+			t.close(previous, record.Time, "unknown (sshd pid reused)")
+		}
+
+		t.working.Open[pid] = &SSHSession{
+			PID:    pid,
+			User:   login.User,
+			IP:     login.IP,
+			Port:   login.Port,
+			Method: login.Method,
+			Start:  record.Time,
+		}
+		return
+	}
+
+	message := record.Message
+	if m := receivedDisconnectPattern.FindStringSubmatch(message); m != nil {
+		if session := t.find(pid, "", m[1], m[2]); session != nil {
+			session.DisconnectReason = fmt.Sprintf("%s (code %s)", m[4], m[3])
+		}
+		return
+	}
+	if m := timeoutPattern.FindStringSubmatch(message); m != nil {
+		if session := t.find(pid, m[1], m[2], m[3]); session != nil {
+			session.DisconnectReason = "client timeout"
+		}
+		return
+	}
+	if m := connectionEndedPattern.FindStringSubmatch(message); m != nil {
+		if session := t.find(pid, m[2], m[3], m[4]); session != nil && session.DisconnectReason == "" {
+			session.DisconnectReason = "connection " + m[1]
+		}
+		return
+	}
+
+	var session *SSHSession
+	if m := disconnectedPattern.FindStringSubmatch(message); m != nil {
+		session = t.find(pid, m[1], m[2], m[3])
+	} else if sessionClosedPattern.MatchString(message) {
+		session = t.find(pid, "", "", "")
+	}
+	if session == nil {
+		return
+	}
+
+	reason := session.DisconnectReason
+	if reason == "" {
+		reason = "session closed"
+	}
+	t.close(session, record.Time, reason)
+}
+
+// find returns the open session a lifecycle message belongs to
+// The session of the logging PID is used unless the message names another
+// client; otherwise the session is matched on client address, port and,
+// when the message has one, user
+func (t *sessionTracker) find(pid int, user, ip, port string) *SSHSession {
+	clientPort, _ := strconv.Atoi(port)
+	matches := func(session *SSHSession) bool {
+		return session.IP == ip && session.Port == clientPort && (user == "" || session.User == sanitizeLogField(user))
+	}
+
+	if session, ok := t.working.Open[pid]; ok && (ip == "" || matches(session)) {
+		return session
+	}
+	if ip == "" {
+		return nil
+	}
+
+	for _, session := range t.working.Open {
+		if matches(session) {
+			return session
+		}
+	}
+	return nil
+}
+
+// close finishes a session and moves it to the closed list
+func (t *sessionTracker) close(session *SSHSession, end time.Time, reason string) {
+	session.End = end
+	session.DisconnectReason = reason
+	session.IsActive = false
+	if end.After(session.Start) {
+		session.Duration = int64(end.Sub(session.Start).Seconds())
+	}
+
+	delete(t.working.Open, session.PID)
+	t.closed = append(t.closed, *session)
+}
+
+// expireStale closes open sessions whose sshd process no longer exists,
+// which happens when the disconnect was never logged (crash, kill -9)
+func (t *sessionTracker) expireStale(now time.Time) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for pid, session := range t.working.Open {
+		if !processExists(pid) {
+			t.close(session, now, "sshd process exited")
+		}
+	}
+}
+
+// sessions returns the sessions closed in this cycle followed by the ones
+// still open, with durations computed up to now for the open ones
+func (t *sessionTracker) sessions(now time.Time) []SSHSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]SSHSession, 0, len(t.closed)+len(t.working.Open))
+	result = append(result, t.closed...)
+
+	for _, session := range t.working.Open {
+		active := *session
+		active.IsActive = true
+		if now.After(active.Start) {
+			active.Duration = int64(now.Sub(active.Start).Seconds())
+		}
+		result = append(result, active)
+	}
+
+	return result
+}
+
+// lookup returns the tracked session for a login, open or closed this cycle
+func (t *sessionTracker) lookup(login SSHLogin) (SSHSession, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if session, ok := t.working.Open[login.PID]; ok && session.Start.Equal(login.Timestamp) {
+		return *session, true
+	}
+
+	for _, session := range t.closed {
+		if session.PID == login.PID && session.Start.Equal(login.Timestamp) {
+			return session, true
+		}
+	}
+
+	return SSHSession{}, false
+}
+
+// commit persists the open sessions of the current cycle
+func (t *sessionTracker) commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.committed = sessionState{Open: make(map[int]*SSHSession, len(t.working.Open))}
+	for pid, session := range t.working.Open {
+		copied := *session
+		t.committed.Open[pid] = &copied
+	}
+
+	return state.Save(t.statePath, t.committed)
+}
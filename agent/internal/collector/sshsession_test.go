@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionTrackerDisconnectReason(t *testing.T) {
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		lines  []string
+		reason string
+	}{
+		{
+			name: "disconnect logged by the post-auth child",
+			lines: []string{
+				"Oct 15 10:00:00 web-01 sshd[1001]: Accepted publickey for alice from 192.0.2.10 port 50000 ssh2: ED25519 SHA256:abc",
+				"Oct 15 10:05:00 web-01 sshd[1050]: Received disconnect from 192.0.2.10 port 50000:11: disconnected by user",
+				"Oct 15 10:05:00 web-01 sshd[1050]: Disconnected from user alice 192.0.2.10 port 50000",
+				"Oct 15 10:05:00 web-01 sshd[1001]: pam_unix(sshd:session): session closed for user alice",
+			},
+			reason: "disconnected by user (code 11)",
+		},
+		{
+			name: "client timeout",
+			lines: []string{
+				"Oct 15 10:00:00 web-01 sshd[1001]: Accepted password for alice from 192.0.2.10 port 50000 ssh2",
+				"Oct 15 10:05:00 web-01 sshd[1050]: Timeout, client not responding from user alice 192.0.2.10 port 50000",
+				"Oct 15 10:05:00 web-01 sshd[1001]: pam_unix(sshd:session): session closed for user alice",
+			},
+			reason: "client timeout",
+		},
+		{
+			name: "connection reset",
+			lines: []string{
+				"Oct 15 10:00:00 web-01 sshd[1001]: Accepted password for alice from 192.0.2.10 port 50000 ssh2",
+				"Oct 15 10:05:00 web-01 sshd[1050]: Connection reset by 192.0.2.10 port 50000",
+				"Oct 15 10:05:00 web-01 sshd[1001]: pam_unix(sshd:session): session closed for user alice",
+			},
+			reason: "connection reset",
+		},
+		{
+			name: "other client of the same user",
+			lines: []string{
+				"Oct 15 10:00:00 web-01 sshd[1001]: Accepted password for alice from 192.0.2.10 port 50000 ssh2",
+				"Oct 15 10:05:00 web-01 sshd[1050]: Received disconnect from 192.0.2.10 port 50001:11: disconnected by user",
+				"Oct 15 10:05:00 web-01 sshd[1001]: pam_unix(sshd:session): session closed for user alice",
+			},
+			reason: "session closed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newSessionTracker("")
+			for _, line := range tt.lines {
+				record, ok := parseSyslogLine(line, now)
+				if !ok {
+					t.Fatalf("parseSyslogLine(%q) failed", line)
+				}
+				var login *SSHLogin
+				if parsed, ok := loginFromRecord(record); ok {
+					login = &parsed
+				}
+				tracker.observe(record, login)
+			}
+
+			sessions := tracker.sessions(now)
+			if len(sessions) != 1 {
+				t.Fatalf("got %d sessions, want 1", len(sessions))
+			}
+			if sessions[0].IsActive {
+				t.Fatal("session still active")
+			}
+			if sessions[0].DisconnectReason != tt.reason {
+				t.Errorf("DisconnectReason = %q, want %q", sessions[0].DisconnectReason, tt.reason)
+			}
+		})
+	}
+}
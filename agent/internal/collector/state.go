@@ -0,0 +1,12 @@
+package collector
+
+import "path/filepath"
+
+// statePath returns the path of a state file inside stateDir
+// An empty stateDir disables persistence
+func statePath(stateDir, name string) string {
+	if stateDir == "" {
+		return ""
+	}
+	return filepath.Join(stateDir, name)
+}
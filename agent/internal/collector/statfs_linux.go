@@ -0,0 +1,19 @@
+package collector
+
+import "syscall"
+
+// statfs returns the space and inode usage of the filesystem mounted at path
+func statfs(path string) (*FilesystemUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return nil, err
+	}
+
+	// f_frsize is the unit of the block counts, f_bsize only the preferred I/O size
+	blockSize := uint64(st.Frsize)
+	if blockSize == 0 {
+		blockSize = uint64(st.Bsize)
+	}
+
+	return usageFromCounts(blockSize, st.Blocks, st.Bfree, st.Bavail, st.Files, st.Ffree), nil
+}
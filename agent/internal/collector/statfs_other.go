@@ -0,0 +1,10 @@
+//go:build !linux
+
+package collector
+
+import "fmt"
+
+// statfs is only implemented on Linux, where FilesystemCollector runs
+func statfs(path string) (*FilesystemUsage, error) {
+	return nil, fmt.Errorf("statfs is not supported on this platform")
+}
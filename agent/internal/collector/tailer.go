@@ -0,0 +1,303 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+const (
+	// maxTailBytes limits how much of a file is read in one cycle
+	// The remainder is picked up by the next cycle
+	maxTailBytes = 16 * 1024 * 1024
+)
+
+// tailPosition records how far a log file has been read
+type tailPosition struct {
+	Inode          uint64 `json:"inode"`
+	Offset         int64  `json:"offset"`
+	Fingerprint    string `json:"fingerprint,omitempty"`     // hash of the file head
+	FingerprintLen int    `json:"fingerprint_len,omitempty"` // bytes covered by Fingerprint
+	ModTime        int64  `json:"mod_time,omitempty"`        // file mtime (unix nanoseconds)
+}
+
+// LogTailer reads log files incrementally and remembers where it stopped
+// Positions are only persisted on Commit, so lines returned by ReadNew are
+// returned again until the caller confirms they have been handled
+type LogTailer struct {
+	statePath string
+	committed map[string]tailPosition
+	pending   map[string]tailPosition
+	mu        sync.Mutex
+}
+
+// NewLogTailer creates a tailer that persists its positions to statePath
+// An empty statePath keeps positions in memory only
+func NewLogTailer(statePath string) *LogTailer {
+	t := &LogTailer{
+		statePath: statePath,
+		committed: make(map[string]tailPosition),
+		pending:   make(map[string]tailPosition),
+	}
+
+	if err := state.Load(statePath, &t.committed); err != nil {
+		logger.Warn("Failed to load tail state, starting fresh: " + err.Error())
+		t.committed = make(map[string]tailPosition)
+	}
+
+	return t
+}
+
+// ReadNew returns the complete lines appended to path since the last commit
+// A file seen for the first time is read from its end. When the file was
+// rotated or truncated since the last commit, the rotated copies are read
+// first to recover the lines written in between, then the file is read from
+// the beginning
+func (t *LogTailer) ReadNew(path string) ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	inode := fileInode(info)
+	size := info.Size()
+
+	fp, fpLen, err := fingerprint(file, fingerprintBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	current := tailPosition{
+		Inode:          inode,
+		Fingerprint:    fp,
+		FingerprintLen: fpLen,
+		ModTime:        info.ModTime().UnixNano(),
+	}
+
+	pos, known := t.committed[path]
+	if !known {
+		logger.Info(fmt.Sprintf("Tailing %s from end (offset %d)", path, size))
+		current.Offset = size
+		t.pending[path] = current
+		return nil, nil
+	}
+
+	lines := make([]string, 0)
+	offset := pos.Offset
+
+	if gap := t.detectGap(file, pos, inode, size); gap != "" {
+		logger.Info(fmt.Sprintf("%s %s since last checkpoint, catching up from rotated logs", path, gap))
+		lines = append(lines, catchUp(path, pos)...)
+		offset = 0
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	newLines, n, err := readLines(file, maxTailBytes)
+	if err != nil {
+		return nil, err
+	}
+	lines = append(lines, newLines...)
+
+	current.Offset = offset + n
+	t.pending[path] = current
+	return lines, nil
+}
+
+// detectGap reports how the file changed in a way that breaks continuity
+// with the checkpoint, or "" if reading can continue from pos.Offset
+func (t *LogTailer) detectGap(file *os.File, pos tailPosition, inode uint64, size int64) string {
+	if pos.Inode != inode {
+		return fmt.Sprintf("was rotated (inode %d -> %d)", pos.Inode, inode)
+	}
+
+	if size < pos.Offset {
+		return fmt.Sprintf("was truncated (%d < %d)", size, pos.Offset)
+	}
+
+	// Same inode and large enough, but the head may have been rewritten
+	// (copytruncate followed by quick regrowth)
+	if pos.FingerprintLen > 0 {
+		if _, err := file.Seek(0, io.SeekStart); err == nil {
+			fp, n, err := fingerprint(file, pos.FingerprintLen)
+			if err == nil && (n != pos.FingerprintLen || fp != pos.Fingerprint) {
+				return "was replaced"
+			}
+		}
+	}
+
+	return ""
+}
+
+// ReadNewRecords returns the whole fixed-size records appended to a binary
+// file (wtmp, btmp) since the last commit
+// It follows the same rules as ReadNew. After rotation the rest of the old
+// file is read from its uncompressed rotated copy, if there is one
+func (t *LogTailer) ReadNewRecords(path string, recordSize int64) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	inode := fileInode(info)
+	size := info.Size() - info.Size()%recordSize
+
+	pos, known := t.committed[path]
+	if !known {
+		logger.Info(fmt.Sprintf("Tailing %s from end (offset %d)", path, size))
+		t.pending[path] = tailPosition{Inode: inode, Offset: size, ModTime: info.ModTime().UnixNano()}
+		return nil, nil
+	}
+
+	data := make([]byte, 0)
+	offset := pos.Offset
+
+	if pos.Inode != inode {
+		logger.Info(fmt.Sprintf("%s was rotated (inode %d -> %d), reading from start", path, pos.Inode, inode))
+		data = append(data, readRotatedRecords(path, pos, recordSize)...)
+		offset = 0
+	} else if size < offset {
+		logger.Info(fmt.Sprintf("%s was truncated (%d < %d), reading from start", path, size, offset))
+		offset = 0
+	}
+
+	length := size - offset
+	if length > maxTailBytes {
+		length = maxTailBytes - maxTailBytes%recordSize
+	}
+
+	records, err := readRecordsAt(file, offset, length, recordSize)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, records...)
+
+	t.pending[path] = tailPosition{Inode: inode, Offset: offset + int64(len(records)), ModTime: info.ModTime().UnixNano()}
+	return data, nil
+}
+
+// readRecordsAt reads up to length bytes of whole records at offset
+// The file may have shrunk since the stat: only the bytes actually read
+// count, cut to whole records so a partial one is read again next time
+func readRecordsAt(r io.ReaderAt, offset, length, recordSize int64) ([]byte, error) {
+	buf := make([]byte, length)
+	n, err := r.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:int64(n)-int64(n)%recordSize], nil
+}
+
+// readRotatedRecords reads the records written to the rotated copy of path
+// after pos
+func readRotatedRecords(path string, pos tailPosition, recordSize int64) []byte {
+	siblings, err := rotatedSiblings(path)
+	if err != nil {
+		return nil
+	}
+
+	for _, sibling := range siblings {
+		if sibling.gzipped || sibling.inode != pos.Inode {
+			continue
+		}
+
+		data, err := os.ReadFile(sibling.path)
+		if err != nil || int64(len(data)) <= pos.Offset {
+			return nil
+		}
+
+		data = data[pos.Offset:]
+		return data[:int64(len(data))-int64(len(data))%recordSize]
+	}
+
+	return nil
+}
+
+// Commit persists the positions reached by ReadNew since the last commit
+func (t *LogTailer) Commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.pending) == 0 {
+		return nil
+	}
+
+	for path, pos := range t.pending {
+		t.committed[path] = pos
+	}
+	t.pending = make(map[string]tailPosition)
+
+	return state.Save(t.statePath, t.committed)
+}
+
+// readLines reads complete lines from r, up to roughly limit bytes
+// A trailing line without newline is left for the next read, since the
+// writer may still be in the middle of it. It returns the number of bytes
+// consumed by the complete lines
+func readLines(r io.Reader, limit int64) ([]string, int64, error) {
+	lines := make([]string, 0)
+	reader := bufio.NewReader(r)
+	var consumed int64
+
+	for consumed < limit {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		consumed += int64(len(line))
+		lines = append(lines, trimLineEnding(line))
+	}
+
+	return lines, consumed, nil
+}
+
+// trimLineEnding strips a trailing "\n" or "\r\n"
+func trimLineEnding(line string) string {
+	n := len(line)
+	if n > 0 && line[n-1] == '\n' {
+		n--
+	}
+	if n > 0 && line[n-1] == '\r' {
+		n--
+	}
+	return line[:n]
+}
+
+// fileInode returns the inode number of a file, or 0 if unavailable
+func fileInode(info os.FileInfo) uint64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(stat.Ino)
+}
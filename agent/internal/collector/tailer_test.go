@@ -0,0 +1,298 @@
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// appendFile appends data to path, creating it if needed
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readNew calls ReadNew and fails the test on error
+func readNew(t *testing.T, tailer *LogTailer, path string) []string {
+	t.Helper()
+
+	lines, err := tailer.ReadNew(path)
+	if err != nil {
+		t.Fatalf("ReadNew() error = %v", err)
+	}
+	if lines == nil {
+		lines = []string{}
+	}
+	return lines
+}
+
+// commit calls Commit and fails the test on error
+func commit(t *testing.T, tailer *LogTailer) {
+	t.Helper()
+
+	if err := tailer.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+}
+
+func TestLogTailerReadNew(t *testing.T) {
+	tests := []struct {
+		name string
+		// change is applied to the log after a first read and commit of
+		// "old 1\nold 2\n"
+		change func(t *testing.T, path string)
+		want   []string
+	}{
+		{
+			name: "append",
+			change: func(t *testing.T, path string) {
+				appendFile(t, path, "new 1\r\nnew 2\npartial")
+			},
+			want: []string{"new 1", "new 2"},
+		},
+		{
+			name:   "nothing new",
+			change: func(t *testing.T, path string) {},
+			want:   []string{},
+		},
+		{
+			name: "truncated",
+			change: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte("n\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"n"},
+		},
+		{
+			name: "head rewritten in place",
+			change: func(t *testing.T, path string) {
+				// copytruncate followed by regrowth past the old offset
+				if err := os.WriteFile(path, []byte("copy 1\ncopy 2\ncopy 3\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"copy 1", "copy 2", "copy 3"},
+		},
+		{
+			name: "rotated to a new inode",
+			change: func(t *testing.T, path string) {
+				appendFile(t, path, "old 3\n")
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				appendFile(t, path, "new 1\n")
+			},
+			want: []string{"old 3", "new 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "auth.log")
+			appendFile(t, path, "before\n")
+
+			tailer := NewLogTailer("")
+			if lines := readNew(t, tailer, path); len(lines) != 0 {
+				t.Fatalf("first read = %v, want nothing: new files are read from the end", lines)
+			}
+			commit(t, tailer)
+
+			appendFile(t, path, "old 1\nold 2\n")
+			if lines := readNew(t, tailer, path); !reflect.DeepEqual(lines, []string{"old 1", "old 2"}) {
+				t.Fatalf("second read = %v", lines)
+			}
+			commit(t, tailer)
+
+			tt.change(t, path)
+			if lines := readNew(t, tailer, path); !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("ReadNew() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogTailerCommit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.log")
+	statePath := filepath.Join(dir, "tail.json")
+	appendFile(t, path, "before\n")
+
+	tailer := NewLogTailer(statePath)
+	readNew(t, tailer, path)
+	commit(t, tailer)
+
+	// Lines are returned again until they are committed
+	appendFile(t, path, "line 1\nline 2\n")
+	for i := 0; i < 2; i++ {
+		if lines := readNew(t, tailer, path); !reflect.DeepEqual(lines, []string{"line 1", "line 2"}) {
+			t.Fatalf("uncommitted read %d = %q", i, lines)
+		}
+	}
+	commit(t, tailer)
+
+	// A partial line waits for its newline
+	appendFile(t, path, "line 3")
+	if lines := readNew(t, tailer, path); len(lines) != 0 {
+		t.Fatalf("read of a partial line = %q", lines)
+	}
+	commit(t, tailer)
+
+	// A restart continues from the saved position
+	appendFile(t, path, " end\nline 4\n")
+	restarted := NewLogTailer(statePath)
+	if lines := readNew(t, restarted, path); !reflect.DeepEqual(lines, []string{"line 3 end", "line 4"}) {
+		t.Errorf("read after restart = %q", lines)
+	}
+
+	// Without a commit the restart state stays where it was
+	again := NewLogTailer(statePath)
+	if lines := readNew(t, again, path); !reflect.DeepEqual(lines, []string{"line 3 end", "line 4"}) {
+		t.Errorf("read after an uncommitted restart = %q", lines)
+	}
+}
+
+func TestLogTailerReadNewRecords(t *testing.T) {
+	const recordSize = 4
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wtmp")
+	statePath := filepath.Join(dir, "tail.json")
+	appendFile(t, path, "AAAA")
+
+	read := func(tailer *LogTailer) string {
+		t.Helper()
+		data, err := tailer.ReadNewRecords(path, recordSize)
+		if err != nil {
+			t.Fatalf("ReadNewRecords() error = %v", err)
+		}
+		return string(data)
+	}
+
+	tailer := NewLogTailer(statePath)
+	if got := read(tailer); got != "" {
+		t.Fatalf("first read = %q, want nothing", got)
+	}
+	commit(t, tailer)
+
+	// Only whole records are returned, the partial one waits
+	appendFile(t, path, "BBBBCC")
+	if got := read(tailer); got != "BBBB" {
+		t.Fatalf("read = %q, want BBBB", got)
+	}
+	commit(t, tailer)
+
+	appendFile(t, path, "CC")
+	restarted := NewLogTailer(statePath)
+	if got := read(restarted); got != "CCCC" {
+		t.Fatalf("read after restart = %q, want CCCC", got)
+	}
+	commit(t, restarted)
+
+	// Rotation: the rest of the old file comes from its uncompressed copy
+	appendFile(t, path, "DDDD")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "EEEE")
+	if got := read(restarted); got != "DDDDEEEE" {
+		t.Fatalf("read after rotation = %q, want DDDDEEEE", got)
+	}
+	commit(t, restarted)
+
+	appendFile(t, path, "GGGG")
+	if got := read(restarted); got != "GGGG" {
+		t.Fatalf("read = %q, want GGGG", got)
+	}
+	commit(t, restarted)
+
+	// Truncation below the position starts over
+	if err := os.WriteFile(path, []byte("FFFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(restarted); got != "FFFF" {
+		t.Fatalf("read after truncation = %q, want FFFF", got)
+	}
+}
+
+// shortReaderAt returns at most n bytes, as ReadAt does on a file that
+// shrank after it was stat'ed
+type shortReaderAt struct {
+	data []byte
+	n    int
+	err  error
+}
+
+func (r shortReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), r.n)], r.data[off:])
+	return n, r.err
+}
+
+func TestReadRecordsAt(t *testing.T) {
+	data := []byte("AAAABBBBCCCC")
+
+	tests := []struct {
+		name    string
+		reader  shortReaderAt
+		offset  int64
+		length  int64
+		want    string
+		wantErr bool
+	}{
+		{name: "full read", reader: shortReaderAt{data: data, n: 12}, length: 12, want: "AAAABBBBCCCC"},
+		{name: "from offset", reader: shortReaderAt{data: data, n: 12}, offset: 4, length: 8, want: "BBBBCCCC"},
+		{name: "short read", reader: shortReaderAt{data: data, n: 6, err: io.EOF}, length: 12, want: "AAAA"},
+		{name: "short read of a partial record", reader: shortReaderAt{data: data, n: 3, err: io.EOF}, length: 12, want: ""},
+		{name: "past the end", reader: shortReaderAt{data: data, n: 12}, offset: 12, length: 4, want: ""},
+		{name: "read error", reader: shortReaderAt{data: data, n: 4, err: errors.New("i/o error")}, length: 12, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRecordsAt(tt.reader, tt.offset, tt.length, 4)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readRecordsAt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, []byte(tt.want)) && !tt.wantErr {
+				t.Errorf("readRecordsAt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLinesLimit(t *testing.T) {
+	input := strings.Repeat("0123456789\n", 10) + "partial"
+
+	lines, n, err := readLines(strings.NewReader(input), 25)
+	if err != nil {
+		t.Fatalf("readLines() error = %v", err)
+	}
+	// The limit is checked before each line, so the line crossing it is kept
+	if len(lines) != 3 || n != 33 {
+		t.Errorf("readLines() = %d lines, %d bytes, want 3 lines, 33 bytes", len(lines), n)
+	}
+
+	lines, n, err = readLines(strings.NewReader(input), 1<<20)
+	if err != nil {
+		t.Fatalf("readLines() error = %v", err)
+	}
+	if len(lines) != 10 || n != 110 {
+		t.Errorf("readLines() = %d lines, %d bytes, want 10 lines, 110 bytes", len(lines), n)
+	}
+}
@@ -0,0 +1,219 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// threatEntry is one address or network of the threat-intel list
+type threatEntry struct {
+	Network *net.IPNet
+	Entry   string // as written in the file
+	Label   string // text after the address, if any
+}
+
+// threatList is a local list of malicious IPs and CIDRs
+// The file is reloaded when its modification time or size changes, so
+// it can be updated by a cron job without restarting the agent
+type threatList struct {
+	path    string
+	entries []threatEntry
+	index   *threatIndex
+	modTime time.Time
+	size    int64
+	missing bool // the file did not exist at the last check
+	mu      sync.Mutex
+}
+
+// newThreatList creates a threat-intel list backed by path
+// An empty path or a missing file matches nothing
+func newThreatList(path string) *threatList {
+	return &threatList{path: path}
+}
+
+// match returns the most specific list entry containing ip, nil if there
+// is none
+func (t *threatList) match(ip net.IP) *threatEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.index.lookup(ip)
+}
+
+// threatPrefix holds the entries of one prefix length
+type threatPrefix struct {
+	mask    net.IPMask
+	entries map[string]*threatEntry // network address bytes -> entry
+}
+
+// threatIndex finds the entry containing an address with one map lookup
+// per prefix length in use, lists are often tens of thousands of entries
+// long and are matched against every connection
+type threatIndex struct {
+	prefixes []threatPrefix // longest first
+}
+
+// newThreatIndex indexes entries by prefix length
+// The first entry of a network listed twice wins
+func newThreatIndex(entries []threatEntry) *threatIndex {
+	byMask := make(map[string]*threatPrefix)
+	for i := range entries {
+		network := entries[i].Network
+		prefix, ok := byMask[string(network.Mask)]
+		if !ok {
+			prefix = &threatPrefix{mask: network.Mask, entries: make(map[string]*threatEntry)}
+			byMask[string(network.Mask)] = prefix
+		}
+		key := string(network.IP.Mask(network.Mask))
+		if _, ok := prefix.entries[key]; !ok {
+			prefix.entries[key] = &entries[i]
+		}
+	}
+
+	index := &threatIndex{prefixes: make([]threatPrefix, 0, len(byMask))}
+	for _, prefix := range byMask {
+		index.prefixes = append(index.prefixes, *prefix)
+	}
+	sort.Slice(index.prefixes, func(i, j int) bool {
+		ones1, bits1 := index.prefixes[i].mask.Size()
+		ones2, bits2 := index.prefixes[j].mask.Size()
+		if bits1 != bits2 {
+			return bits1 < bits2
+		}
+		return ones1 > ones2
+	})
+	return index
+}
+
+// lookup returns the most specific entry containing ip, nil if there is none
+func (index *threatIndex) lookup(ip net.IP) *threatEntry {
+	if index == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	for _, prefix := range index.prefixes {
+		if len(prefix.mask) != len(ip) {
+			continue
+		}
+		if entry, ok := prefix.entries[string(ip.Mask(prefix.mask))]; ok {
+			return entry
+		}
+	}
+	return nil
+}
+
+// refresh reloads the file if it changed since the last load
+func (t *threatList) refresh() {
+	if t.path == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if !t.missing {
+				logger.Debug("Threat-intel list %s not found, destination matching disabled", t.path)
+			}
+			t.missing = true
+			t.entries = nil
+			t.index = nil
+			return
+		}
+		logger.Warn("Failed to stat threat-intel list: " + err.Error())
+		return
+	}
+	t.missing = false
+
+	if info.ModTime().Equal(t.modTime) && info.Size() == t.size && t.entries != nil {
+		return
+	}
+
+	file, err := os.Open(t.path)
+	if err != nil {
+		logger.Warn("Failed to read threat-intel list: " + err.Error())
+		return
+	}
+	defer file.Close()
+
+	entries, invalid, err := parseThreatList(file)
+	if err != nil {
+		logger.Warn("Failed to read threat-intel list: " + err.Error())
+		return
+	}
+
+	t.entries = entries
+	t.index = newThreatIndex(entries)
+	t.modTime = info.ModTime()
+	t.size = info.Size()
+
+	logger.Info("Loaded " + fmt.Sprint(len(entries)) + " threat-intel entries from " + t.path)
+	if invalid > 0 {
+		logger.Warn("Skipped " + fmt.Sprint(invalid) + " invalid threat-intel entries in " + t.path)
+	}
+}
+
+// parseThreatList parses one IP or CIDR per line, optionally followed by
+// a label; "#" and ";" start comments
+//
+//	203.0.113.7        known C2
+//	198.51.100.0/24    mining pool
+func parseThreatList(r io.Reader) ([]threatEntry, int, error) {
+	entries := make([]threatEntry, 0)
+	invalid := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		network := parseNetwork(fields[0])
+		if network == nil {
+			invalid++
+			continue
+		}
+		entries = append(entries, threatEntry{
+			Network: network,
+			Entry:   fields[0],
+			Label:   strings.Join(fields[1:], " "),
+		})
+	}
+
+	return entries, invalid, scanner.Err()
+}
+
+// parseNetwork parses a CIDR or a single address
+func parseNetwork(s string) *net.IPNet {
+	if _, network, err := net.ParseCIDR(s); err == nil {
+		return network
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestThreatIndexLookup(t *testing.T) {
+	list := `# test list
+203.0.113.7        known C2
+198.51.100.0/24    mining pool
+198.51.100.128/25  scanner
+198.51.100.0/24    duplicate
+10.0.0.0/8         ; comment only
+2001:db8::/32      v6 network
+2001:db8:1::5      v6 host
+not-an-address
+`
+	entries, invalid, err := parseThreatList(strings.NewReader(list))
+	if err != nil || invalid != 1 {
+		t.Fatalf("parseThreatList() invalid = %d, error = %v", invalid, err)
+	}
+	index := newThreatIndex(entries)
+
+	tests := []struct {
+		ip    string
+		entry string
+		label string
+	}{
+		{ip: "203.0.113.7", entry: "203.0.113.7", label: "known C2"},
+		{ip: "203.0.113.8"},
+		{ip: "198.51.100.1", entry: "198.51.100.0/24", label: "mining pool"},
+		{ip: "198.51.100.200", entry: "198.51.100.128/25", label: "scanner"},
+		{ip: "10.200.1.1", entry: "10.0.0.0/8"},
+		{ip: "::ffff:203.0.113.7", entry: "203.0.113.7", label: "known C2"},
+		{ip: "2001:db8:1::5", entry: "2001:db8:1::5", label: "v6 host"},
+		{ip: "2001:db8:2::1", entry: "2001:db8::/32", label: "v6 network"},
+		{ip: "2001:db9::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			entry := index.lookup(net.ParseIP(tt.ip))
+			if tt.entry == "" {
+				if entry != nil {
+					t.Errorf("lookup() = %s, want no match", entry.Entry)
+				}
+				return
+			}
+			if entry == nil || entry.Entry != tt.entry || entry.Label != tt.label {
+				t.Errorf("lookup() = %+v, want %s %q", entry, tt.entry, tt.label)
+			}
+		})
+	}
+
+	if (*threatIndex)(nil).lookup(net.ParseIP("203.0.113.7")) != nil {
+		t.Error("lookup() on an empty list matched")
+	}
+}
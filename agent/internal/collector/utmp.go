@@ -0,0 +1,200 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Linux struct utmp record types (utmp.h)
+const (
+	utmpEmpty        = 0
+	utmpRunLevel     = 1
+	utmpBootTime     = 2
+	utmpNewTime      = 3
+	utmpOldTime      = 4
+	utmpInitProcess  = 5
+	utmpLoginProcess = 6
+	utmpUserProcess  = 7
+	utmpDeadProcess  = 8
+	utmpAccounting   = 9
+)
+
+const (
+	// utmpRecordSize is sizeof(struct utmp) on Linux, the same for glibc
+	// and musl on 32-bit and 64-bit architectures
+	utmpRecordSize = 384
+	// lastlogRecordSize is sizeof(struct lastlog) on Linux
+	lastlogRecordSize = 292
+)
+
+// Default accounting file locations
+const (
+	utmpPath    = "/var/run/utmp"
+	wtmpPath    = "/var/log/wtmp"
+	btmpPath    = "/var/log/btmp"
+	lastlogPath = "/var/log/lastlog"
+)
+
+// rawUtmp mirrors the on-disk layout of struct utmp
+type rawUtmp struct {
+	Type        int16
+	_           [2]byte
+	PID         int32
+	Line        [32]byte
+	ID          [4]byte
+	User        [32]byte
+	Host        [256]byte
+	Termination int16
+	Exit        int16
+	Session     int32
+	Sec         int32
+	Usec        int32
+	AddrV6      [4]uint32
+	_           [20]byte
+}
+
+// UtmpRecord is a decoded utmp/wtmp/btmp record
+type UtmpRecord struct {
+	Type int
+	PID  int
+	Line string // tty, "~" for boot and run level records
+	ID   string
+	User string
+	Host string // remote host, or kernel version for boot records
+	Addr net.IP // remote address, nil if not recorded
+	Time time.Time
+}
+
+// parseUtmp decodes consecutive struct utmp records from r
+// A trailing partial record is ignored, the writer may be appending to it
+func parseUtmp(r io.Reader) ([]UtmpRecord, error) {
+	records := make([]UtmpRecord, 0)
+	buf := make([]byte, utmpRecordSize)
+
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+
+		var raw rawUtmp
+		if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw); err != nil {
+			return nil, fmt.Errorf("failed to decode utmp record: %w", err)
+		}
+
+		records = append(records, raw.decode())
+	}
+
+	return records, nil
+}
+
+// decode converts the raw record into a UtmpRecord
+func (raw rawUtmp) decode() UtmpRecord {
+	record := UtmpRecord{
+		Type: int(raw.Type),
+		PID:  int(raw.PID),
+		Line: cString(raw.Line[:]),
+		ID:   cString(raw.ID[:]),
+		User: cString(raw.User[:]),
+		Host: cString(raw.Host[:]),
+		// tv_sec is a 32-bit field, read it unsigned to survive 2038
+		Time: time.Unix(int64(uint32(raw.Sec)), int64(raw.Usec)*1000),
+	}
+
+	if raw.AddrV6 != [4]uint32{} {
+		addr := make(net.IP, 16)
+		for i, word := range raw.AddrV6 {
+			binary.LittleEndian.PutUint32(addr[i*4:], word)
+		}
+		if raw.AddrV6[1] == 0 && raw.AddrV6[2] == 0 && raw.AddrV6[3] == 0 {
+			addr = net.IP(addr[:4])
+		}
+		record.Addr = addr
+	}
+
+	return record
+}
+
+// RemoteIP returns the remote address of the record, from ut_addr_v6 or
+// from ut_host when it holds an address literal
+func (r UtmpRecord) RemoteIP() string {
+	if r.Addr != nil && !r.Addr.IsUnspecified() {
+		return r.Addr.String()
+	}
+	if ip := net.ParseIP(r.Host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// readUtmpFile decodes all records of a utmp-format file
+func readUtmpFile(path string) ([]UtmpRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseUtmp(file)
+}
+
+// LastlogEntry is the last login of one user from /var/log/lastlog
+type LastlogEntry struct {
+	User string    `json:"user"`
+	UID  int       `json:"uid"`
+	Line string    `json:"line"`
+	Host string    `json:"host"`
+	Time time.Time `json:"time"`
+}
+
+// readLastlog reads the lastlog entries of the given users
+// The file is indexed by UID and sparse (a single high UID such as
+// nfsnobody makes it huge), so only the records of known users are read
+func readLastlog(r io.ReaderAt, users []passwdEntry) ([]LastlogEntry, error) {
+	entries := make([]LastlogEntry, 0)
+	buf := make([]byte, lastlogRecordSize)
+
+	for _, user := range users {
+		if user.UID < 0 {
+			continue
+		}
+
+		_, err := r.ReadAt(buf, int64(user.UID)*lastlogRecordSize)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sec := binary.LittleEndian.Uint32(buf[0:4])
+		if sec == 0 {
+			continue
+		}
+
+		entries = append(entries, LastlogEntry{
+			User: user.Name,
+			UID:  user.UID,
+			Line: cString(buf[4:36]),
+			Host: cString(buf[36:292]),
+			Time: time.Unix(int64(sec), 0),
+		})
+	}
+
+	return entries, nil
+}
+
+// cString converts a NUL-padded C char array to a string
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// utmpFields are the fields written by utmpBytes
+type utmpFields struct {
+	typ  int16
+	pid  int32
+	line string
+	id   string
+	user string
+	host string
+	sec  uint32
+	usec int32
+	addr net.IP
+}
+
+// utmpBytes encodes a struct utmp with the Linux field offsets, independent
+// of rawUtmp so the test checks the layout too
+func utmpBytes(f utmpFields) []byte {
+	buf := make([]byte, utmpRecordSize)
+	binary.LittleEndian.PutUint16(buf[0:], uint16(f.typ))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(f.pid))
+	copy(buf[8:40], f.line)
+	copy(buf[40:44], f.id)
+	copy(buf[44:76], f.user)
+	copy(buf[76:332], f.host)
+	binary.LittleEndian.PutUint32(buf[340:], f.sec)
+	binary.LittleEndian.PutUint32(buf[344:], uint32(f.usec))
+	// ut_addr_v6 holds the address in network byte order
+	if ip4 := f.addr.To4(); ip4 != nil {
+		copy(buf[348:352], ip4)
+	} else if f.addr != nil {
+		copy(buf[348:364], f.addr.To16())
+	}
+	return buf
+}
+
+func TestParseUtmp(t *testing.T) {
+	if size := binary.Size(rawUtmp{}); size != utmpRecordSize {
+		t.Fatalf("rawUtmp is %d bytes, want %d", size, utmpRecordSize)
+	}
+
+	login := time.Date(2026, 10, 15, 8, 30, 0, 250000000, time.UTC)
+	boot := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	past2038 := time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var file bytes.Buffer
+	file.Write(utmpBytes(utmpFields{typ: utmpBootTime, line: "~", id: "~~", user: "reboot", host: "6.1.0-26-amd64",
+		sec: uint32(boot.Unix())}))
+	file.Write(utmpBytes(utmpFields{typ: utmpUserProcess, pid: 4242, line: "pts/0", id: "ts/0", user: "alice",
+		host: "203.0.113.9", sec: uint32(login.Unix()), usec: 250000, addr: net.ParseIP("203.0.113.9")}))
+	file.Write(utmpBytes(utmpFields{typ: utmpUserProcess, pid: 4243, line: "pts/1", id: "ts/1", user: "bob",
+		host: "gateway.example.com", sec: uint32(login.Unix()), addr: net.ParseIP("2001:db8::1")}))
+	file.Write(utmpBytes(utmpFields{typ: utmpDeadProcess, pid: 4242, line: "pts/0", id: "ts/0",
+		sec: uint32(past2038.Unix())}))
+	// A record still being appended
+	file.Write(utmpBytes(utmpFields{typ: utmpUserProcess, user: "carol"})[:100])
+
+	records, err := parseUtmp(&file)
+	if err != nil {
+		t.Fatalf("parseUtmp() error = %v", err)
+	}
+
+	tests := []struct {
+		want   UtmpRecord
+		remote string
+	}{
+		{
+			want:   UtmpRecord{Type: utmpBootTime, Line: "~", ID: "~~", User: "reboot", Host: "6.1.0-26-amd64", Time: boot},
+			remote: "",
+		},
+		{
+			want: UtmpRecord{Type: utmpUserProcess, PID: 4242, Line: "pts/0", ID: "ts/0", User: "alice",
+				Host: "203.0.113.9", Addr: net.IP{203, 0, 113, 9}, Time: login},
+			remote: "203.0.113.9",
+		},
+		{
+			want: UtmpRecord{Type: utmpUserProcess, PID: 4243, Line: "pts/1", ID: "ts/1", User: "bob",
+				Host: "gateway.example.com", Addr: net.ParseIP("2001:db8::1"), Time: login.Truncate(time.Second)},
+			remote: "2001:db8::1",
+		},
+		{
+			want:   UtmpRecord{Type: utmpDeadProcess, PID: 4242, Line: "pts/0", ID: "ts/0", Time: past2038},
+			remote: "",
+		},
+	}
+
+	if len(records) != len(tests) {
+		t.Fatalf("parseUtmp() = %d records, want %d", len(records), len(tests))
+	}
+	for i, tt := range tests {
+		got := records[i]
+		if !got.Time.Equal(tt.want.Time) {
+			t.Errorf("record %d time = %v, want %v", i, got.Time, tt.want.Time)
+		}
+		got.Time, tt.want.Time = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("record %d = %+v, want %+v", i, got, tt.want)
+		}
+		if remote := records[i].RemoteIP(); remote != tt.remote {
+			t.Errorf("record %d RemoteIP() = %q, want %q", i, remote, tt.remote)
+		}
+	}
+}
+
+// lastlogBytes encodes a struct lastlog
+func lastlogBytes(sec uint32, line, host string) []byte {
+	buf := make([]byte, lastlogRecordSize)
+	binary.LittleEndian.PutUint32(buf[0:], sec)
+	copy(buf[4:36], line)
+	copy(buf[36:292], host)
+	return buf
+}
+
+func TestReadLastlog(t *testing.T) {
+	login := time.Date(2026, 10, 15, 8, 30, 0, 0, time.UTC)
+
+	// Indexed by UID: root never logged in, UID 1 and 2 did, the record of
+	// UID 3 is cut short
+	var file bytes.Buffer
+	file.Write(lastlogBytes(0, "", ""))
+	file.Write(lastlogBytes(uint32(login.Unix()), "pts/0", "203.0.113.9"))
+	file.Write(lastlogBytes(uint32(login.Unix())+60, "tty1", ""))
+	file.Write(lastlogBytes(uint32(login.Unix()), "pts/3", "198.51.100.4")[:40])
+
+	users := []passwdEntry{
+		{Name: "root", UID: 0},
+		{Name: "alice", UID: 1},
+		{Name: "bob", UID: 2},
+		{Name: "carol", UID: 3},
+		{Name: "nfsnobody", UID: 65534},
+	}
+
+	entries, err := readLastlog(bytes.NewReader(file.Bytes()), users)
+	if err != nil {
+		t.Fatalf("readLastlog() error = %v", err)
+	}
+
+	want := []LastlogEntry{
+		{User: "alice", UID: 1, Line: "pts/0", Host: "203.0.113.9", Time: login},
+		{User: "bob", UID: 2, Line: "tty1", Host: "", Time: login.Add(time.Minute)},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("readLastlog() = %+v, want %+v", entries, want)
+	}
+	for i := range want {
+		got := entries[i]
+		if !got.Time.Equal(want[i].Time) {
+			t.Errorf("entry %d time = %v, want %v", i, got.Time, want[i].Time)
+		}
+		got.Time, want[i].Time = time.Time{}, time.Time{}
+		if got != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// watchMask selects the directory events that can change a monitored file
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB | syscall.IN_DONT_FOLLOW
+
+// fileWatcher reports changes of directory entries through inotify
+type fileWatcher struct {
+	fd      int
+	handler func(path string)
+	dirs    map[int]string // watch descriptor -> directory
+	watched map[string]bool
+	mu      sync.Mutex
+}
+
+// newFileWatcher starts an inotify watcher calling handler with the path
+// of every changed entry of the watched directories
+func newFileWatcher(handler func(path string)) (*fileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &fileWatcher{
+		fd:      fd,
+		handler: handler,
+		dirs:    make(map[int]string),
+		watched: make(map[string]bool),
+	}
+	go w.run()
+	return w, nil
+}
+
+// watch adds the directories not watched yet
+// Missing directories are skipped, the next call retries them
+func (w *fileWatcher) watch(dirs map[string]bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for dir := range dirs {
+		if w.watched[dir] {
+			continue
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+		if err != nil {
+			logger.Debug("Failed to watch %s: %v", dir, err)
+			continue
+		}
+		// The same directory reached under another path returns the
+		// existing descriptor, keep the path events were reported under
+		if _, ok := w.dirs[wd]; !ok {
+			w.dirs[wd] = dir
+		}
+		w.watched[dir] = true
+	}
+}
+
+// run reads inotify events until the descriptor fails
+func (w *fileWatcher) run() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			logger.Warn("File watcher stopped, changes are only detected by rescanning")
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int(int32(binary.NativeEndian.Uint32(buf[offset:])))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+
+			nameStart := offset + syscall.SizeofInotifyEvent
+			if nameStart+nameLen > n {
+				break
+			}
+			name := string(trimNull(buf[nameStart : nameStart+nameLen]))
+			offset = nameStart + nameLen
+
+			w.dispatch(wd, mask, name)
+		}
+	}
+}
+
+// dispatch handles one inotify event
+func (w *fileWatcher) dispatch(wd int, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		logger.Warn("File watcher queue overflowed, changes will be picked up by the next scan")
+		return
+	}
+
+	w.mu.Lock()
+	dir, ok := w.dirs[wd]
+	if mask&syscall.IN_IGNORED != 0 && ok {
+		// The directory was removed, it is watched again once recreated
+		delete(w.dirs, wd)
+		delete(w.watched, dir)
+	}
+	w.mu.Unlock()
+
+	if !ok || name == "" {
+		return
+	}
+	w.handler(filepath.Join(dir, name))
+}
+
+// trimNull cuts the NUL padding of an inotify name
+func trimNull(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package collector
+
+import "fmt"
+
+// fileWatcher is only implemented on Linux, through inotify
+type fileWatcher struct{}
+
+// newFileWatcher always fails outside Linux
+func newFileWatcher(handler func(path string)) (*fileWatcher, error) {
+	return nil, fmt.Errorf("file watching is not supported on this platform")
+}
+
+// watch does nothing outside Linux
+func (w *fileWatcher) watch(dirs map[string]bool) {}
@@ -0,0 +1,310 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+// LoginRecord is a login, logout or failed login from wtmp/btmp
+type LoginRecord struct {
+	Event      string    `json:"event"` // login, logout, failed
+	User       string    `json:"user"`
+	Line       string    `json:"line"` // tty
+	Host       string    `json:"host"`
+	IP         string    `json:"ip"`
+	PID        int       `json:"pid"`
+	LoginTime  time.Time `json:"login_time"`  // zero if the login was not seen
+	LogoutTime time.Time `json:"logout_time"` // zero for login and failed events
+	Duration   int64     `json:"duration"`    // seconds, logout events only
+	Reason     string    `json:"reason"`      // logout cause: logout, system boot
+}
+
+// SystemRecord is a boot or shutdown record from wtmp
+type SystemRecord struct {
+	Event  string    `json:"event"` // boot, shutdown
+	Time   time.Time `json:"time"`
+	Kernel string    `json:"kernel"`
+}
+
+// LoginAccounting is the result of a wtmp/btmp/lastlog collection
+type LoginAccounting struct {
+	Logins     []LoginRecord  `json:"logins"`      // wtmp logins and logouts
+	Failed     []LoginRecord  `json:"failed"`      // btmp
+	System     []SystemRecord `json:"system"`      // boots and shutdowns
+	LastLogins []LastlogEntry `json:"last_logins"` // lastlog entries updated since the previous check
+}
+
+// wtmpState is the persisted state of the login accounting collector
+type wtmpState struct {
+	Open        map[string]LoginRecord `json:"open"`         // logins without logout, by tty
+	LastlogSeen int64                  `json:"lastlog_seen"` // unix time of the previous lastlog check
+}
+
+// WtmpCollector reads the login accounting files (wtmp, btmp, lastlog)
+// natively instead of scraping `last` and `lastb` output
+type WtmpCollector struct {
+	BaseCollector
+	wtmpPath    string
+	btmpPath    string
+	lastlogPath string
+	tailer      *LogTailer
+	statePath   string
+	committed   wtmpState
+	working     wtmpState
+	mu          sync.Mutex
+}
+
+// NewWtmpCollector creates a new login accounting collector
+func NewWtmpCollector(stateDir string) *WtmpCollector {
+	c := &WtmpCollector{
+		BaseCollector: BaseCollector{name: "wtmp"},
+		wtmpPath:      wtmpPath,
+		btmpPath:      btmpPath,
+		lastlogPath:   lastlogPath,
+		tailer:        NewLogTailer(statePath(stateDir, "wtmp_tail.json")),
+		statePath:     statePath(stateDir, "wtmp.json"),
+	}
+
+	if err := state.Load(c.statePath, &c.committed); err != nil {
+		logger.Warn("Failed to load wtmp state, starting fresh: " + err.Error())
+	}
+	if c.committed.Open == nil {
+		c.committed.Open = make(map[string]LoginRecord)
+	}
+
+	return c
+}
+
+// Collect collects logins, logouts, failed logins and boot records
+func (c *WtmpCollector) Collect() (interface{}, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	logger.Info("Collecting login accounting records")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.working = wtmpState{
+		Open:        make(map[string]LoginRecord, len(c.committed.Open)),
+		LastlogSeen: c.committed.LastlogSeen,
+	}
+	for line, record := range c.committed.Open {
+		c.working.Open[line] = record
+	}
+
+	result := &LoginAccounting{
+		Logins:     make([]LoginRecord, 0),
+		Failed:     make([]LoginRecord, 0),
+		System:     make([]SystemRecord, 0),
+		LastLogins: make([]LastlogEntry, 0),
+	}
+
+	if records, err := c.readNew(c.wtmpPath); err != nil {
+		logger.Warn("Failed to read " + c.wtmpPath + ": " + err.Error())
+	} else {
+		c.processWtmp(records, result)
+	}
+
+	if records, err := c.readNew(c.btmpPath); err != nil {
+		logger.Debug("Failed to read %s: %s", c.btmpPath, err.Error())
+	} else {
+		for _, record := range records {
+			result.Failed = append(result.Failed, LoginRecord{
+				Event:     "failed",
+				User:      record.User,
+				Line:      record.Line,
+				Host:      record.Host,
+				IP:        record.RemoteIP(),
+				PID:       record.PID,
+				LoginTime: record.Time,
+			})
+		}
+	}
+
+	if entries, err := c.readLastlog(); err != nil {
+		logger.Debug("Failed to read %s: %s", c.lastlogPath, err.Error())
+	} else {
+		result.LastLogins = entries
+	}
+
+	logger.Info(fmt.Sprintf("Login accounting: %d login/logout, %d failed, %d system records",
+		len(result.Logins), len(result.Failed), len(result.System)))
+	return result, nil
+}
+
+// Commit persists the file positions and open logins of the last Collect
+func (c *WtmpCollector) Commit() error {
+	if err := c.tailer.Commit(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.working.Open == nil {
+		return nil
+	}
+	c.committed = c.working
+	return state.Save(c.statePath, c.committed)
+}
+
+// readNew decodes the records appended to a utmp-format file since the last commit
+func (c *WtmpCollector) readNew(path string) ([]UtmpRecord, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	data, err := c.tailer.ReadNewRecords(path, utmpRecordSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseUtmp(bytes.NewReader(data))
+}
+
+// processWtmp turns wtmp records into login/logout and system records
+// Logins are paired with the DEAD_PROCESS record of the same tty
+func (c *WtmpCollector) processWtmp(records []UtmpRecord, result *LoginAccounting) {
+	for _, record := range records {
+		switch {
+		case record.Type == utmpUserProcess:
+			login := LoginRecord{
+				Event:     "login",
+				User:      record.User,
+				Line:      record.Line,
+				Host:      record.Host,
+				IP:        record.RemoteIP(),
+				PID:       record.PID,
+				LoginTime: record.Time,
+			}
+			c.working.Open[record.Line] = login
+			result.Logins = append(result.Logins, login)
+
+		case record.Type == utmpDeadProcess:
+			logout := LoginRecord{
+				Event:      "logout",
+				Line:       record.Line,
+				PID:        record.PID,
+				LogoutTime: record.Time,
+				Reason:     "logout",
+			}
+			if login, ok := c.working.Open[record.Line]; ok {
+				c.closeLogin(&logout, login)
+			}
+			result.Logins = append(result.Logins, logout)
+
+		case record.Type == utmpBootTime:
+			// A boot ends every session that was open before it
+			for _, login := range c.working.Open {
+				logout := LoginRecord{
+					Event:      "logout",
+					Line:       login.Line,
+					PID:        login.PID,
+					LogoutTime: record.Time,
+					Reason:     "system boot",
+				}
+				c.closeLogin(&logout, login)
+				result.Logins = append(result.Logins, logout)
+			}
+
+			result.System = append(result.System, SystemRecord{
+				Event:  "boot",
+				Time:   record.Time,
+				Kernel: record.Host,
+			})
+
+		case record.Type == utmpRunLevel && record.User == "shutdown":
+			result.System = append(result.System, SystemRecord{
+				Event:  "shutdown",
+				Time:   record.Time,
+				Kernel: record.Host,
+			})
+		}
+	}
+}
+
+// closeLogin fills a logout from its login and forgets the open login
+func (c *WtmpCollector) closeLogin(logout *LoginRecord, login LoginRecord) {
+	logout.User = login.User
+	logout.Host = login.Host
+	logout.IP = login.IP
+	logout.LoginTime = login.LoginTime
+	if logout.LogoutTime.After(login.LoginTime) {
+		logout.Duration = int64(logout.LogoutTime.Sub(login.LoginTime).Seconds())
+	}
+	delete(c.working.Open, login.Line)
+}
+
+// readLastlog returns the lastlog entries updated since the previous check
+// The first check only records the time, like the tailers start at the end
+func (c *WtmpCollector) readLastlog() ([]LastlogEntry, error) {
+	now := time.Now().Unix()
+	since := c.working.LastlogSeen
+	c.working.LastlogSeen = now
+
+	if since == 0 {
+		return nil, nil
+	}
+
+	users, err := readPasswd()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(c.lastlogPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries, err := readLastlog(file, users)
+	if err != nil {
+		return nil, err
+	}
+
+	recent := make([]LastlogEntry, 0)
+	for _, entry := range entries {
+		if entry.Time.Unix() > since {
+			recent = append(recent, entry)
+		}
+	}
+
+	return recent, nil
+}
+
+// activeUtmpSessions returns the remote logins currently recorded in utmp
+// whose process is still alive
+func activeUtmpSessions() ([]UtmpRecord, error) {
+	records, err := readUtmpFile(utmpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]UtmpRecord, 0)
+	for _, record := range records {
+		if record.Type != utmpUserProcess || record.User == "" {
+			continue
+		}
+		if record.PID > 0 && !processExists(record.PID) {
+			continue
+		}
+		active = append(active, record)
+	}
+
+	return active, nil
+}
+
+// processExists reports whether a process with the given PID is running
+func processExists(pid int) bool {
+	_, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+	return err == nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds the agent configuration
@@ -10,6 +11,76 @@ type Config struct {
 	ServerURL      string `json:"server_url"`
 	Token          string `json:"token"`
 	ReportInterval int    `json:"report_interval"`
+
+	// SSH brute-force detection, zero uses the default and -1 disables a threshold
+	BruteForceWindow          int  `json:"bruteforce_window,omitempty"` // seconds
+	BruteForceMaxFailures     int  `json:"bruteforce_max_failures,omitempty"`
+	BruteForceMaxUsers        int  `json:"bruteforce_max_users,omitempty"`
+	BruteForceMaxUserFailures int  `json:"bruteforce_max_user_failures,omitempty"`
+	BruteForceSummarize       bool `json:"bruteforce_summarize,omitempty"` // drop raw failures covered by a detection
+
+	// Active blocking of brute-force sources, disabled when the backend is empty
+	FirewallBackend      string   `json:"firewall_backend,omitempty"` // nftables, ipset, command
+	FirewallTable        string   `json:"firewall_table,omitempty"`   // nftables table / ipset set name
+	FirewallBanTTL       int      `json:"firewall_ban_ttl,omitempty"` // seconds
+	FirewallAllowlist    []string `json:"firewall_allowlist,omitempty"`
+	FirewallBanCommand   string   `json:"firewall_ban_command,omitempty"` // command backend, {ip} and {ttl} are substituted
+	FirewallUnbanCommand string   `json:"firewall_unban_command,omitempty"`
+
+	// Filesystem collector filters, pseudo filesystems are skipped by default
+	FilesystemIncludeTypes  []string `json:"filesystem_include_types,omitempty"`
+	FilesystemExcludeTypes  []string `json:"filesystem_exclude_types,omitempty"`
+	FilesystemIncludeMounts []string `json:"filesystem_include_mounts,omitempty"` // globs
+	FilesystemExcludeMounts []string `json:"filesystem_exclude_mounts,omitempty"` // globs
+
+	// Disk I/O collector filters, only whole disks are reported by default
+	DiskPartitions   bool     `json:"disk_partitions,omitempty"`
+	DiskDeviceMapper bool     `json:"disk_device_mapper,omitempty"`
+	DiskLoop         bool     `json:"disk_loop,omitempty"`    // loop, ram and zram devices
+	DiskInclude      []string `json:"disk_include,omitempty"` // device name globs
+	DiskExclude      []string `json:"disk_exclude,omitempty"`
+
+	// Top processes collector, zero uses the default and -1 disables it
+	ProcessTopN       int      `json:"process_top_n,omitempty"`
+	ProcessCmdlineMax int      `json:"process_cmdline_max,omitempty"` // bytes
+	ProcessRedactArgs bool     `json:"process_redact_args,omitempty"` // only report the executable
+	ProcessRedactKeys []string `json:"process_redact_keys,omitempty"` // argument names whose values are masked
+
+	// TCP connection summary, the threat-intel file defaults to threat-intel.txt
+	// in the config directory
+	ConnectionTopN  int    `json:"connection_top_n,omitempty"`
+	ThreatIntelFile string `json:"threat_intel_file,omitempty"` // one IP or CIDR per line
+
+	// File integrity monitoring, globs with "~/" for every home directory
+	IntegrityPaths       []string `json:"integrity_paths,omitempty"`       // replaces the default paths
+	IntegrityExtraPaths  []string `json:"integrity_extra_paths,omitempty"` // added to the default paths
+	IntegrityExclude     []string `json:"integrity_exclude,omitempty"`
+	IntegrityMaxHashSize int64    `json:"integrity_max_hash_size,omitempty"` // bytes
+	IntegrityNoInotify   bool     `json:"integrity_no_inotify,omitempty"`    // rescan only
+
+	// Reports kept in the state directory while the server is unreachable,
+	// zero uses the default and a negative size disables the spool
+	SpoolMaxSize int64 `json:"spool_max_size,omitempty"` // bytes
+	SpoolMaxAge  int   `json:"spool_max_age,omitempty"`  // seconds
+
+	// Report payloads, compression is negotiated with the server by default
+	ReportCompression    string `json:"report_compression,omitempty"`      // auto, none, gzip
+	ReportMaxPayloadSize int64  `json:"report_max_payload_size,omitempty"` // bytes of JSON, -1 disables the cap
+
+	// TLS of the reporter client, certificate files are reloaded when they change
+	TLSCAFile     string   `json:"tls_ca_file,omitempty"`   // PEM CA bundle, replaces the system roots
+	TLSCertFile   string   `json:"tls_cert_file,omitempty"` // client certificate for mutual TLS
+	TLSKeyFile    string   `json:"tls_key_file,omitempty"`
+	TLSServerName string   `json:"tls_server_name,omitempty"` // overrides the name checked in the server certificate
+	TLSPins       []string `json:"tls_pins,omitempty"`        // base64 SHA-256 SPKI pins
+
+	// Proxy of the reports and public IP lookups, HTTPS_PROXY/NO_PROXY by
+	// default and "none" to connect directly
+	ProxyURL string   `json:"proxy_url,omitempty"` // http, https, socks5 or socks5h, with user:password if needed
+	NoProxy  []string `json:"no_proxy,omitempty"`  // added to NO_PROXY
+
+	// Per-host secret signing every report with HMAC-SHA256, empty disables signing
+	SigningSecret string `json:"signing_secret,omitempty"`
 }
 
 // DefaultConfig returns default configuration
@@ -36,5 +107,113 @@ func DefaultConfig() *Config {
 		}
 	}
 
+	applyCollectorEnv(config)
+
 	return config
 }
+
+// applyCollectorEnv applies the collector tuning environment variables
+// Unlike the connection settings these also override a saved config
+func applyCollectorEnv(config *Config) {
+	envInt("ZENOGUARD_BRUTEFORCE_WINDOW", &config.BruteForceWindow)
+	envInt("ZENOGUARD_BRUTEFORCE_MAX_FAILURES", &config.BruteForceMaxFailures)
+	envInt("ZENOGUARD_BRUTEFORCE_MAX_USERS", &config.BruteForceMaxUsers)
+	envInt("ZENOGUARD_BRUTEFORCE_MAX_USER_FAILURES", &config.BruteForceMaxUserFailures)
+	envBool("ZENOGUARD_BRUTEFORCE_SUMMARIZE", &config.BruteForceSummarize)
+
+	envString("ZENOGUARD_FIREWALL_BACKEND", &config.FirewallBackend)
+	envString("ZENOGUARD_FIREWALL_TABLE", &config.FirewallTable)
+	envInt("ZENOGUARD_FIREWALL_BAN_TTL", &config.FirewallBanTTL)
+	envList("ZENOGUARD_FIREWALL_ALLOWLIST", &config.FirewallAllowlist)
+	envString("ZENOGUARD_FIREWALL_BAN_COMMAND", &config.FirewallBanCommand)
+	envString("ZENOGUARD_FIREWALL_UNBAN_COMMAND", &config.FirewallUnbanCommand)
+
+	envList("ZENOGUARD_FILESYSTEM_INCLUDE_TYPES", &config.FilesystemIncludeTypes)
+	envList("ZENOGUARD_FILESYSTEM_EXCLUDE_TYPES", &config.FilesystemExcludeTypes)
+	envList("ZENOGUARD_FILESYSTEM_INCLUDE_MOUNTS", &config.FilesystemIncludeMounts)
+	envList("ZENOGUARD_FILESYSTEM_EXCLUDE_MOUNTS", &config.FilesystemExcludeMounts)
+
+	envBool("ZENOGUARD_DISK_PARTITIONS", &config.DiskPartitions)
+	envBool("ZENOGUARD_DISK_DEVICE_MAPPER", &config.DiskDeviceMapper)
+	envBool("ZENOGUARD_DISK_LOOP", &config.DiskLoop)
+	envList("ZENOGUARD_DISK_INCLUDE", &config.DiskInclude)
+	envList("ZENOGUARD_DISK_EXCLUDE", &config.DiskExclude)
+
+	envInt("ZENOGUARD_PROCESS_TOP_N", &config.ProcessTopN)
+	envInt("ZENOGUARD_PROCESS_CMDLINE_MAX", &config.ProcessCmdlineMax)
+	envBool("ZENOGUARD_PROCESS_REDACT_ARGS", &config.ProcessRedactArgs)
+	envList("ZENOGUARD_PROCESS_REDACT_KEYS", &config.ProcessRedactKeys)
+
+	envInt("ZENOGUARD_CONNECTION_TOP_N", &config.ConnectionTopN)
+	envString("ZENOGUARD_THREAT_INTEL_FILE", &config.ThreatIntelFile)
+
+	envList("ZENOGUARD_INTEGRITY_PATHS", &config.IntegrityPaths)
+	envList("ZENOGUARD_INTEGRITY_EXTRA_PATHS", &config.IntegrityExtraPaths)
+	envList("ZENOGUARD_INTEGRITY_EXCLUDE", &config.IntegrityExclude)
+	envInt64("ZENOGUARD_INTEGRITY_MAX_HASH_SIZE", &config.IntegrityMaxHashSize)
+	envBool("ZENOGUARD_INTEGRITY_NO_INOTIFY", &config.IntegrityNoInotify)
+
+	envInt64("ZENOGUARD_SPOOL_MAX_SIZE", &config.SpoolMaxSize)
+	envInt("ZENOGUARD_SPOOL_MAX_AGE", &config.SpoolMaxAge)
+
+	envString("ZENOGUARD_REPORT_COMPRESSION", &config.ReportCompression)
+	envInt64("ZENOGUARD_REPORT_MAX_PAYLOAD_SIZE", &config.ReportMaxPayloadSize)
+
+	envString("ZENOGUARD_TLS_CA_FILE", &config.TLSCAFile)
+	envString("ZENOGUARD_TLS_CERT_FILE", &config.TLSCertFile)
+	envString("ZENOGUARD_TLS_KEY_FILE", &config.TLSKeyFile)
+	envString("ZENOGUARD_TLS_SERVER_NAME", &config.TLSServerName)
+	envList("ZENOGUARD_TLS_PINS", &config.TLSPins)
+
+	envString("ZENOGUARD_PROXY_URL", &config.ProxyURL)
+	envList("ZENOGUARD_NO_PROXY", &config.NoProxy)
+
+	envString("ZENOGUARD_SIGNING_SECRET", &config.SigningSecret)
+}
+
+// envString sets *value from an environment variable if it is set
+func envString(name string, value *string) {
+	if s := os.Getenv(name); s != "" {
+		*value = s
+	}
+}
+
+// envList sets *value from a comma separated environment variable if it is set
+func envList(name string, value *[]string) {
+	if s := os.Getenv(name); s != "" {
+		list := make([]string, 0)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*value = list
+	}
+}
+
+// envInt sets *value from an integer environment variable if it is set
+func envInt(name string, value *int) {
+	if s := os.Getenv(name); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			*value = v
+		}
+	}
+}
+
+// envInt64 sets *value from a 64-bit integer environment variable if it is set
+func envInt64(name string, value *int64) {
+	if s := os.Getenv(name); s != "" {
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			*value = v
+		}
+	}
+}
+
+// envBool sets *value from a boolean environment variable if it is set
+func envBool(name string, value *bool) {
+	if s := os.Getenv(name); s != "" {
+		if v, err := strconv.ParseBool(s); err == nil {
+			*value = v
+		}
+	}
+}
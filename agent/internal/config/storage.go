@@ -76,6 +76,17 @@ func getConfigDir() string {
 	return os.TempDir() + "/zenoguard"
 }
 
+// GetStateDir returns the directory for persisted collector state
+// (log positions, snapshots and baselines)
+func GetStateDir() string {
+	return getConfigDir() + "/state"
+}
+
+// GetThreatIntelPath returns the default threat-intel list path
+func GetThreatIntelPath() string {
+	return getConfigDir() + "/threat-intel.txt"
+}
+
 // generateKeyFromMachine generates an encryption key from machine characteristics
 func generateKeyFromMachine() []byte {
 	// Get machine identifiers
@@ -182,6 +193,8 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
+	applyCollectorEnv(&config)
+
 	logger.Info("Configuration loaded successfully")
 	return &config, nil
 }
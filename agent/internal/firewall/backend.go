@@ -0,0 +1,330 @@
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+)
+
+// Backend adds and removes addresses from a firewall block list
+type Backend interface {
+	// Name returns the backend name used in logs and reported actions
+	Name() string
+	// Init creates the tables, sets and rules the backend needs
+	// It must be safe to call on every start
+	Init() error
+	// Ban blocks traffic from ip
+	// Backends that can expire entries on their own do so after ttl, so a
+	// ban does not outlive the agent if it crashes or loses its state
+	Ban(ip net.IP, ttl time.Duration) error
+	// Unban removes ip from the block list
+	Unban(ip net.IP) error
+}
+
+// CommandRunner runs an external command and returns its combined output
+// Backends take it as a parameter so they can be exercised with a fake
+type CommandRunner func(name string, args ...string) ([]byte, error)
+
+// ExecRunner runs commands with os/exec
+func ExecRunner(name string, args ...string) ([]byte, error) {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return output, fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err,
+			strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
+
+// NewBackend creates the backend selected in the config
+func NewBackend(config Config, run CommandRunner) (Backend, error) {
+	if run == nil {
+		run = ExecRunner
+	}
+
+	switch config.Backend {
+	case "nftables", "nft":
+		return NewNftablesBackend(config.Table, run), nil
+	case "ipset", "iptables":
+		return NewIpsetBackend(config.Table, run), nil
+	case "command":
+		if config.BanCommand == "" || config.UnbanCommand == "" {
+			return nil, fmt.Errorf("command backend needs both a ban and an unban command")
+		}
+		return NewCommandBackend(config.BanCommand, config.UnbanCommand, run), nil
+	default:
+		return nil, fmt.Errorf("unknown firewall backend: %s", config.Backend)
+	}
+}
+
+// NftablesBackend blocks addresses through two nftables sets (IPv4 and
+// IPv6) in a dedicated inet table with its own input chain
+type NftablesBackend struct {
+	table string
+	run   CommandRunner
+}
+
+// NewNftablesBackend creates an nftables backend using the given table
+func NewNftablesBackend(table string, run CommandRunner) *NftablesBackend {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &NftablesBackend{table: table, run: run}
+}
+
+// Name returns the backend name
+func (b *NftablesBackend) Name() string {
+	return "nftables"
+}
+
+// Init applies the table, sets and drop rules in a single nft transaction
+// It runs on every start, so a chain, set or rule deleted by hand is put
+// back; the chain is flushed first so its rules are never duplicated
+func (b *NftablesBackend) Init() error {
+	err := b.apply()
+	if err == nil {
+		return nil
+	}
+
+	// Sets created by older versions have no timeout flag and cannot be
+	// redefined in place: recreate the table, the blocker restores the bans
+	if _, deleteErr := b.run("nft", "delete", "table", "inet", b.table); deleteErr != nil {
+		return err
+	}
+	logger.Warn("Recreating nftables table " + b.table + ": " + err.Error())
+	return b.apply()
+}
+
+// ruleset returns the nft script defining the table
+// Every statement is an "add", which leaves existing objects untouched
+func (b *NftablesBackend) ruleset() string {
+	table := "inet " + b.table
+	return strings.Join([]string{
+		"add table " + table,
+		"add set " + table + " banned_v4 { type ipv4_addr; flags timeout; }",
+		"add set " + table + " banned_v6 { type ipv6_addr; flags timeout; }",
+		"add chain " + table + " input { type filter hook input priority -10; policy accept; }",
+		"flush chain " + table + " input",
+		"add rule " + table + " input ip saddr @banned_v4 drop",
+		"add rule " + table + " input ip6 saddr @banned_v6 drop",
+	}, "\n") + "\n"
+}
+
+// apply loads the ruleset with nft -f
+func (b *NftablesBackend) apply() error {
+	file, err := os.CreateTemp("", "zenoguard-nft-*.conf")
+	if err != nil {
+		return fmt.Errorf("failed to write nftables ruleset: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(b.ruleset()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write nftables ruleset: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write nftables ruleset: %w", err)
+	}
+
+	_, err = b.run("nft", "-f", file.Name())
+	return err
+}
+
+// Ban adds ip to the matching set, the kernel drops it after ttl
+func (b *NftablesBackend) Ban(ip net.IP, ttl time.Duration) error {
+	element := fmt.Sprintf("{ %s timeout %ds }", ip, ttlSeconds(ttl, 0))
+	_, err := b.run("nft", "add", "element", "inet", b.table, nftSet(ip), element)
+	return err
+}
+
+// Unban removes ip from the matching set
+// An element the kernel already expired counts as removed
+func (b *NftablesBackend) Unban(ip net.IP) error {
+	element := "{ " + ip.String() + " }"
+	_, err := b.run("nft", "delete", "element", "inet", b.table, nftSet(ip), element)
+	if err != nil {
+		if _, getErr := b.run("nft", "get", "element", "inet", b.table, nftSet(ip), element); getErr != nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// nftSet returns the set holding addresses of ip's family
+func nftSet(ip net.IP) string {
+	if ip.To4() != nil {
+		return "banned_v4"
+	}
+	return "banned_v6"
+}
+
+// IpsetBackend blocks addresses through ipset hash:ip sets matched by an
+// iptables/ip6tables DROP rule
+type IpsetBackend struct {
+	set string
+	run CommandRunner
+}
+
+// NewIpsetBackend creates an ipset backend, the IPv6 set gets a "6" suffix
+func NewIpsetBackend(set string, run CommandRunner) *IpsetBackend {
+	if set == "" {
+		set = DefaultTable
+	}
+	return &IpsetBackend{set: set, run: run}
+}
+
+// Name returns the backend name
+func (b *IpsetBackend) Name() string {
+	return "ipset"
+}
+
+// Init creates the sets and inserts the DROP rules if they are missing
+// Sets created without timeout support by older versions are replaced
+func (b *IpsetBackend) Init() error {
+	families := []struct {
+		set      string
+		family   string
+		iptables string
+	}{
+		{b.set, "inet", "iptables"},
+		{b.set + "6", "inet6", "ip6tables"},
+	}
+
+	for _, f := range families {
+		if _, err := b.run("ipset", "create", f.set, "hash:ip", "family", f.family, "timeout", "0", "-exist"); err != nil {
+			if err := b.migrate(f.set, f.family); err != nil {
+				return err
+			}
+		}
+
+		rule := []string{"INPUT", "-m", "set", "--match-set", f.set, "src", "-j", "DROP"}
+		if _, err := b.run(f.iptables, append([]string{"-C"}, rule...)...); err == nil {
+			continue
+		}
+		if _, err := b.run(f.iptables, append([]string{"-I"}, rule...)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrate swaps an existing set for one with timeout support
+// The set may be referenced by the DROP rule, so it cannot be destroyed;
+// its entries are lost and restored by the blocker
+func (b *IpsetBackend) migrate(set, family string) error {
+	tmp := set + "-new"
+	commands := [][]string{
+		{"create", tmp, "hash:ip", "family", family, "timeout", "0", "-exist"},
+		{"swap", tmp, set},
+		{"destroy", tmp},
+	}
+
+	for _, args := range commands {
+		if _, err := b.run("ipset", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ban adds ip to the matching set, the kernel drops it after ttl
+// Banning an address again resets its timeout
+func (b *IpsetBackend) Ban(ip net.IP, ttl time.Duration) error {
+	timeout := strconv.FormatInt(ttlSeconds(ttl, ipsetMaxTimeout), 10)
+	_, err := b.run("ipset", "add", b.setFor(ip), ip.String(), "timeout", timeout, "-exist")
+	return err
+}
+
+// Unban removes ip from the matching set
+func (b *IpsetBackend) Unban(ip net.IP) error {
+	_, err := b.run("ipset", "del", b.setFor(ip), ip.String(), "-exist")
+	return err
+}
+
+// setFor returns the set holding addresses of ip's family
+func (b *IpsetBackend) setFor(ip net.IP) string {
+	if ip.To4() != nil {
+		return b.set
+	}
+	return b.set + "6"
+}
+
+// ipsetMaxTimeout is the largest timeout ipset accepts, in seconds
+const ipsetMaxTimeout = 2147483
+
+// ttlSeconds converts a ban TTL to whole seconds for a kernel timeout,
+// rounding up so a ban never ends early, capped at max when max > 0
+func ttlSeconds(ttl time.Duration, max int64) int64 {
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	if max > 0 && seconds > max {
+		seconds = max
+	}
+	return seconds
+}
+
+// CommandBackend runs user supplied commands to ban and unban
+// "{ip}" and "{ttl}" (seconds) are substituted in each argument, the
+// command is not run through a shell
+type CommandBackend struct {
+	banCommand   []string
+	unbanCommand []string
+	run          CommandRunner
+}
+
+// NewCommandBackend creates a backend from ban and unban command lines
+func NewCommandBackend(banCommand, unbanCommand string, run CommandRunner) *CommandBackend {
+	return &CommandBackend{
+		banCommand:   strings.Fields(banCommand),
+		unbanCommand: strings.Fields(unbanCommand),
+		run:          run,
+	}
+}
+
+// Name returns the backend name
+func (b *CommandBackend) Name() string {
+	return "command"
+}
+
+// Init does nothing, the commands are expected to manage their own setup
+func (b *CommandBackend) Init() error {
+	return nil
+}
+
+// Ban runs the ban command for ip
+func (b *CommandBackend) Ban(ip net.IP, ttl time.Duration) error {
+	return b.runTemplate(b.banCommand, ip, ttl)
+}
+
+// Unban runs the unban command for ip
+func (b *CommandBackend) Unban(ip net.IP) error {
+	return b.runTemplate(b.unbanCommand, ip, 0)
+}
+
+// runTemplate substitutes the placeholders and runs the command
+func (b *CommandBackend) runTemplate(template []string, ip net.IP, ttl time.Duration) error {
+	if len(template) == 0 {
+		return fmt.Errorf("empty command")
+	}
+
+	replacer := strings.NewReplacer(
+		"{ip}", ip.String(),
+		"{ttl}", strconv.FormatInt(int64(ttl.Round(time.Second).Seconds()), 10),
+	)
+
+	args := make([]string, len(template))
+	for i, arg := range template {
+		args[i] = replacer.Replace(arg)
+	}
+
+	_, err := b.run(args[0], args[1:]...)
+	return err
+}
@@ -0,0 +1,267 @@
+package firewall
+
+import (
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRunner records the commands a backend runs instead of running them
+type fakeRunner struct {
+	calls   []string
+	scripts []string       // contents of the files passed to nft -f
+	fail    map[string]int // command line -> number of runs that fail
+}
+
+func (f *fakeRunner) run(name string, args ...string) ([]byte, error) {
+	line := strings.Join(append([]string{name}, args...), " ")
+	if name == "nft" && len(args) == 2 && args[0] == "-f" {
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			return nil, err
+		}
+		f.scripts = append(f.scripts, string(data))
+		line = "nft -f"
+	}
+
+	f.calls = append(f.calls, line)
+	if f.fail[line] > 0 {
+		f.fail[line]--
+		return nil, errors.New("command failed")
+	}
+	return nil, nil
+}
+
+var (
+	testIPv4 = net.ParseIP("192.0.2.1")
+	testIPv6 = net.ParseIP("2001:db8::1")
+)
+
+func TestNftablesBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    map[string]int
+		op      func(b Backend) error
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "init",
+			op:   Backend.Init,
+			want: []string{"nft -f"},
+		},
+		{
+			name: "init recreates a table from an older version",
+			fail: map[string]int{"nft -f": 1},
+			op:   Backend.Init,
+			want: []string{"nft -f", "nft delete table inet zenoguard", "nft -f"},
+		},
+		{
+			name:    "init failure without a table to delete",
+			fail:    map[string]int{"nft -f": 1, "nft delete table inet zenoguard": 1},
+			op:      Backend.Init,
+			want:    []string{"nft -f", "nft delete table inet zenoguard"},
+			wantErr: true,
+		},
+		{
+			name: "ban IPv4",
+			op:   func(b Backend) error { return b.Ban(testIPv4, time.Hour) },
+			want: []string{"nft add element inet zenoguard banned_v4 { 192.0.2.1 timeout 3600s }"},
+		},
+		{
+			name: "ban IPv6 rounds the timeout up",
+			op:   func(b Backend) error { return b.Ban(testIPv6, 1500*time.Millisecond) },
+			want: []string{"nft add element inet zenoguard banned_v6 { 2001:db8::1 timeout 2s }"},
+		},
+		{
+			name: "unban",
+			op:   func(b Backend) error { return b.Unban(testIPv4) },
+			want: []string{"nft delete element inet zenoguard banned_v4 { 192.0.2.1 }"},
+		},
+		{
+			name: "unban of an element the kernel expired",
+			fail: map[string]int{
+				"nft delete element inet zenoguard banned_v4 { 192.0.2.1 }": 1,
+				"nft get element inet zenoguard banned_v4 { 192.0.2.1 }":    1,
+			},
+			op: func(b Backend) error { return b.Unban(testIPv4) },
+			want: []string{
+				"nft delete element inet zenoguard banned_v4 { 192.0.2.1 }",
+				"nft get element inet zenoguard banned_v4 { 192.0.2.1 }",
+			},
+		},
+		{
+			name: "unban failure",
+			fail: map[string]int{"nft delete element inet zenoguard banned_v4 { 192.0.2.1 }": 1},
+			op:   func(b Backend) error { return b.Unban(testIPv4) },
+			want: []string{
+				"nft delete element inet zenoguard banned_v4 { 192.0.2.1 }",
+				"nft get element inet zenoguard banned_v4 { 192.0.2.1 }",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{fail: tt.fail}
+			err := tt.op(NewNftablesBackend("", runner.run))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(runner.calls, tt.want) {
+				t.Errorf("commands =\n%q\nwant\n%q", runner.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestNftablesRuleset(t *testing.T) {
+	runner := &fakeRunner{}
+	if err := NewNftablesBackend("guard", runner.run).Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"add table inet guard",
+		"add set inet guard banned_v4 { type ipv4_addr; flags timeout; }",
+		"add set inet guard banned_v6 { type ipv6_addr; flags timeout; }",
+		"add chain inet guard input { type filter hook input priority -10; policy accept; }",
+		"flush chain inet guard input",
+		"add rule inet guard input ip saddr @banned_v4 drop",
+		"add rule inet guard input ip6 saddr @banned_v6 drop",
+	}
+	if got := strings.Split(strings.TrimSpace(runner.scripts[0]), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ruleset =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestIpsetBackend(t *testing.T) {
+	const (
+		createV4 = "ipset create zenoguard hash:ip family inet timeout 0 -exist"
+		createV6 = "ipset create zenoguard6 hash:ip family inet6 timeout 0 -exist"
+		checkV4  = "iptables -C INPUT -m set --match-set zenoguard src -j DROP"
+		checkV6  = "ip6tables -C INPUT -m set --match-set zenoguard6 src -j DROP"
+	)
+
+	tests := []struct {
+		name    string
+		fail    map[string]int
+		op      func(b Backend) error
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "init with existing rules",
+			op:   Backend.Init,
+			want: []string{createV4, checkV4, createV6, checkV6},
+		},
+		{
+			name: "init inserts a missing rule",
+			fail: map[string]int{checkV4: 1},
+			op:   Backend.Init,
+			want: []string{
+				createV4, checkV4, "iptables -I INPUT -m set --match-set zenoguard src -j DROP",
+				createV6, checkV6,
+			},
+		},
+		{
+			name: "init migrates a set without timeout support",
+			fail: map[string]int{createV4: 1},
+			op:   Backend.Init,
+			want: []string{
+				createV4,
+				"ipset create zenoguard-new hash:ip family inet timeout 0 -exist",
+				"ipset swap zenoguard-new zenoguard",
+				"ipset destroy zenoguard-new",
+				checkV4, createV6, checkV6,
+			},
+		},
+		{
+			name:    "init failure when the migration fails",
+			fail:    map[string]int{createV4: 1, "ipset swap zenoguard-new zenoguard": 1},
+			op:      Backend.Init,
+			want:    []string{createV4, "ipset create zenoguard-new hash:ip family inet timeout 0 -exist", "ipset swap zenoguard-new zenoguard"},
+			wantErr: true,
+		},
+		{
+			name: "ban IPv4",
+			op:   func(b Backend) error { return b.Ban(testIPv4, time.Hour) },
+			want: []string{"ipset add zenoguard 192.0.2.1 timeout 3600 -exist"},
+		},
+		{
+			name: "ban IPv6 caps the timeout",
+			op:   func(b Backend) error { return b.Ban(testIPv6, 1000*time.Hour) },
+			want: []string{"ipset add zenoguard6 2001:db8::1 timeout 2147483 -exist"},
+		},
+		{
+			name: "unban",
+			op:   func(b Backend) error { return b.Unban(testIPv4) },
+			want: []string{"ipset del zenoguard 192.0.2.1 -exist"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{fail: tt.fail}
+			err := tt.op(NewIpsetBackend("", runner.run))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(runner.calls, tt.want) {
+				t.Errorf("commands =\n%q\nwant\n%q", runner.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandBackend(t *testing.T) {
+	tests := []struct {
+		name  string
+		unban bool
+		ttl   time.Duration
+		want  string
+	}{
+		{
+			name: "ban",
+			ttl:  90 * time.Minute,
+			want: "/usr/local/bin/block --addr=192.0.2.1 --for=5400s",
+		},
+		{
+			name:  "unban",
+			unban: true,
+			want:  "/usr/local/bin/unblock 192.0.2.1 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{}
+			backend := NewCommandBackend("/usr/local/bin/block --addr={ip} --for={ttl}s",
+				"/usr/local/bin/unblock {ip} {ttl}", runner.run)
+
+			var err error
+			if tt.unban {
+				err = backend.Unban(testIPv4)
+			} else {
+				err = backend.Ban(testIPv4, tt.ttl)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(runner.calls) != 1 || runner.calls[0] != tt.want {
+				t.Errorf("commands = %q, want %q", runner.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBackendCommandRequiresBoth(t *testing.T) {
+	if _, err := NewBackend(Config{Backend: "command", BanCommand: "block {ip}"}, nil); err == nil {
+		t.Error("NewBackend() without an unban command succeeded")
+	}
+}
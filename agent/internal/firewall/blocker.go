@@ -0,0 +1,355 @@
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/state"
+)
+
+const (
+	// DefaultTable is the nftables table / ipset set name
+	DefaultTable = "zenoguard"
+	// DefaultBanTTL is how long an address stays blocked
+	DefaultBanTTL = time.Hour
+	// maxPendingActions bounds the actions kept while the server is unreachable
+	maxPendingActions = 1000
+)
+
+// Config holds the active blocking settings
+type Config struct {
+	Backend      string        // nftables, ipset, command; empty disables blocking
+	Table        string        // nftables table or ipset set name
+	BanTTL       time.Duration // how long a ban lasts
+	Allowlist    []string      // CIDRs or addresses that are never blocked
+	BanCommand   string        // command backend, "{ip}" and "{ttl}" are substituted
+	UnbanCommand string
+}
+
+// Enabled reports whether a backend is configured
+func (c Config) Enabled() bool {
+	return c.Backend != ""
+}
+
+// Ban is an active block
+type Ban struct {
+	IP        string    `json:"ip"`
+	Reason    string    `json:"reason"`
+	BannedAt  time.Time `json:"banned_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Action is a ban, unban or refused ban, reported to the server
+type Action struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"` // ban, unban, allowlisted, ban_failed, unban_failed
+	IP        string    `json:"ip"`
+	Backend   string    `json:"backend"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expires_at"` // ban actions only
+	Error     string    `json:"error"`
+}
+
+// blockerState is the persisted state of the blocker
+type blockerState struct {
+	Bans    map[string]Ban `json:"bans"`
+	Pending []Action       `json:"pending"` // actions not yet delivered
+}
+
+// Blocker bans offending addresses through a Backend and unbans them once
+// their TTL expires
+// Bans and undelivered actions are persisted, so expiries survive restarts
+// and no action is lost while the server is unreachable
+type Blocker struct {
+	backend   Backend
+	ttl       time.Duration
+	allowlist []*net.IPNet
+	statePath string
+	state     blockerState
+	delivered int // pending actions handed out by the last Pending call
+	mu        sync.Mutex
+}
+
+// NewBlocker creates a blocker for the configured backend
+// State is kept in stateDir
+func NewBlocker(config Config, stateDir string, run CommandRunner) (*Blocker, error) {
+	backend, err := NewBackend(config, run)
+	if err != nil {
+		return nil, err
+	}
+
+	allowlist, err := ParseAllowlist(config.Allowlist)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := config.BanTTL
+	if ttl <= 0 {
+		ttl = DefaultBanTTL
+	}
+
+	path := ""
+	if stateDir != "" {
+		path = filepath.Join(stateDir, "firewall.json")
+	}
+
+	b := &Blocker{
+		backend:   backend,
+		ttl:       ttl,
+		allowlist: allowlist,
+		statePath: path,
+		state:     blockerState{Bans: make(map[string]Ban)},
+	}
+
+	if err := state.Load(path, &b.state); err != nil {
+		logger.Warn("Failed to load firewall state, starting fresh: " + err.Error())
+	}
+	if b.state.Bans == nil {
+		b.state.Bans = make(map[string]Ban)
+	}
+
+	return b, nil
+}
+
+// ParseAllowlist parses CIDRs and plain addresses
+// Loopback addresses are always allowed
+func ParseAllowlist(entries []string) ([]*net.IPNet, error) {
+	entries = append([]string{"127.0.0.0/8", "::1/128"}, entries...)
+
+	allowlist := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowlist entry: %s", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			allowlist = append(allowlist, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry: %s", entry)
+		}
+		allowlist = append(allowlist, network)
+	}
+
+	return allowlist, nil
+}
+
+// Init prepares the backend and restores the persisted bans
+// Bans still running are applied again (sets do not survive a reboot),
+// expired ones are lifted
+func (b *Blocker) Init() error {
+	logger.Info("Initializing firewall backend: " + b.backend.Name())
+	if err := b.backend.Init(); err != nil {
+		return fmt.Errorf("failed to initialize %s backend: %w", b.backend.Name(), err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for _, ban := range b.state.Bans {
+		if !ban.ExpiresAt.After(now) {
+			continue
+		}
+		if err := b.backend.Ban(net.ParseIP(ban.IP), ban.ExpiresAt.Sub(now)); err != nil {
+			logger.Warn("Failed to restore ban of " + ban.IP + ": " + err.Error())
+		}
+	}
+
+	b.expire(now)
+	return b.save()
+}
+
+// Ban blocks ip for the configured TTL
+// An address that is already banned keeps its ban, allowlisted addresses
+// are never blocked
+func (b *Blocker) Ban(address, reason string, now time.Time) {
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		logger.Warn("Not banning invalid address: " + address)
+		return
+	}
+	address = ip.String()
+
+	if existing, ok := b.state.Bans[address]; ok && existing.ExpiresAt.After(time.Now()) {
+		return
+	}
+
+	action := Action{
+		Time:    time.Now(),
+		IP:      address,
+		Backend: b.backend.Name(),
+		Reason:  reason,
+	}
+
+	if b.allowed(ip) {
+		logger.Info("Not banning allowlisted address " + address)
+		action.Action = "allowlisted"
+		b.record(action)
+		return
+	}
+
+	expiresAt := now.Add(b.ttl)
+	if !expiresAt.After(time.Now()) {
+		// Detection replayed from an old log, the ban would already be over
+		return
+	}
+
+	if err := b.backend.Ban(ip, expiresAt.Sub(time.Now())); err != nil {
+		logger.Error("Failed to ban " + address + ": " + err.Error())
+		action.Action = "ban_failed"
+		action.Error = err.Error()
+		b.record(action)
+		return
+	}
+
+	logger.Warn(fmt.Sprintf("Banned %s until %s (%s)", address, expiresAt.Format(time.RFC3339), reason))
+	action.Action = "ban"
+	action.ExpiresAt = expiresAt
+	b.record(action)
+
+	b.state.Bans[address] = Ban{
+		IP:        address,
+		Reason:    reason,
+		BannedAt:  action.Time,
+		ExpiresAt: expiresAt,
+	}
+	if err := b.save(); err != nil {
+		logger.Warn("Failed to save firewall state: " + err.Error())
+	}
+}
+
+// Expire lifts the bans whose TTL is over
+func (b *Blocker) Expire(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.expire(now) {
+		if err := b.save(); err != nil {
+			logger.Warn("Failed to save firewall state: " + err.Error())
+		}
+	}
+}
+
+// expire lifts expired bans and reports whether any was lifted
+// A ban that fails to lift is dropped anyway, retrying would fail the same way
+func (b *Blocker) expire(now time.Time) bool {
+	changed := false
+
+	for address, ban := range b.state.Bans {
+		if ban.ExpiresAt.After(now) {
+			continue
+		}
+
+		action := Action{
+			Time:    time.Now(),
+			Action:  "unban",
+			IP:      address,
+			Backend: b.backend.Name(),
+			Reason:  "ban expired",
+		}
+
+		if err := b.backend.Unban(net.ParseIP(address)); err != nil {
+			logger.Error("Failed to unban " + address + ": " + err.Error())
+			action.Action = "unban_failed"
+			action.Error = err.Error()
+		} else {
+			logger.Info("Unbanned " + address + " (ban expired)")
+		}
+
+		b.record(action)
+		delete(b.state.Bans, address)
+		changed = true
+	}
+
+	return changed
+}
+
+// allowed reports whether ip is in the allowlist
+func (b *Blocker) allowed(ip net.IP) bool {
+	for _, network := range b.allowlist {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// record queues an action for the next report
+func (b *Blocker) record(action Action) {
+	b.state.Pending = append(b.state.Pending, action)
+	if len(b.state.Pending) > maxPendingActions {
+		dropped := len(b.state.Pending) - maxPendingActions
+		b.state.Pending = b.state.Pending[dropped:]
+		b.delivered -= dropped
+		if b.delivered < 0 {
+			b.delivered = 0
+		}
+	}
+}
+
+// Pending returns the actions not yet delivered to the server
+func (b *Blocker) Pending() []Action {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.delivered = len(b.state.Pending)
+	return append(make([]Action, 0, b.delivered), b.state.Pending...)
+}
+
+// Commit forgets the actions returned by the last Pending call
+// It should be called once the report carrying them has been delivered
+func (b *Blocker) Commit() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.delivered == 0 {
+		return nil
+	}
+
+	b.state.Pending = append([]Action(nil), b.state.Pending[b.delivered:]...)
+	b.delivered = 0
+	return b.save()
+}
+
+// Bans returns the active bans
+func (b *Blocker) Bans() []Ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bans := make([]Ban, 0, len(b.state.Bans))
+	for _, ban := range b.state.Bans {
+		bans = append(bans, ban)
+	}
+	return bans
+}
+
+// save persists the bans and pending actions
+func (b *Blocker) save() error {
+	return state.Save(b.statePath, b.state)
+}
@@ -0,0 +1,212 @@
+package firewall
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestBlocker returns a blocker on the command backend, whose calls are
+// easy to match, with its state in stateDir
+func newTestBlocker(t *testing.T, stateDir string, runner *fakeRunner) *Blocker {
+	t.Helper()
+
+	config := Config{
+		Backend:      "command",
+		BanTTL:       time.Hour,
+		Allowlist:    []string{"10.0.0.0/8", "198.51.100.7"},
+		BanCommand:   "ban {ip}",
+		UnbanCommand: "unban {ip}",
+	}
+	blocker, err := NewBlocker(config, stateDir, runner.run)
+	if err != nil {
+		t.Fatalf("NewBlocker() error = %v", err)
+	}
+	return blocker
+}
+
+// actionList returns the action and address of each action
+func actionList(actions []Action) []string {
+	list := make([]string, len(actions))
+	for i, action := range actions {
+		list[i] = action.Action + " " + action.IP
+	}
+	return list
+}
+
+func TestBlockerBan(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		address string
+		at      time.Time
+		calls   []string
+		actions []string
+		bans    int
+	}{
+		{
+			name:    "ban",
+			address: "203.0.113.9",
+			at:      now,
+			calls:   []string{"ban 203.0.113.9"},
+			actions: []string{"ban 203.0.113.9"},
+			bans:    1,
+		},
+		{
+			name:    "allowlisted network",
+			address: "10.1.2.3",
+			at:      now,
+			actions: []string{"allowlisted 10.1.2.3"},
+		},
+		{
+			name:    "allowlisted address",
+			address: "198.51.100.7",
+			at:      now,
+			actions: []string{"allowlisted 198.51.100.7"},
+		},
+		{
+			name:    "loopback",
+			address: "::1",
+			at:      now,
+			actions: []string{"allowlisted ::1"},
+		},
+		{
+			name:    "detection older than the ban TTL",
+			address: "203.0.113.9",
+			at:      now.Add(-2 * time.Hour),
+		},
+		{
+			name:    "invalid address",
+			address: "not-an-ip",
+			at:      now,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{}
+			blocker := newTestBlocker(t, t.TempDir(), runner)
+
+			blocker.Ban(tt.address, "ssh brute force", tt.at)
+			if !reflect.DeepEqual(runner.calls, tt.calls) {
+				t.Errorf("commands = %q, want %q", runner.calls, tt.calls)
+			}
+			if got := actionList(blocker.Pending()); !reflect.DeepEqual(got, append([]string{}, tt.actions...)) {
+				t.Errorf("actions = %q, want %q", got, tt.actions)
+			}
+			if got := len(blocker.Bans()); got != tt.bans {
+				t.Errorf("got %d bans, want %d", got, tt.bans)
+			}
+		})
+	}
+}
+
+func TestBlockerBanTwice(t *testing.T) {
+	runner := &fakeRunner{}
+	blocker := newTestBlocker(t, t.TempDir(), runner)
+
+	blocker.Ban("203.0.113.9", "ssh brute force", time.Now())
+	blocker.Ban("203.0.113.9", "ssh brute force", time.Now())
+	if len(runner.calls) != 1 || len(blocker.Pending()) != 1 {
+		t.Errorf("second ban of an active ban ran %q", runner.calls)
+	}
+}
+
+func TestBlockerExpire(t *testing.T) {
+	runner := &fakeRunner{}
+	blocker := newTestBlocker(t, t.TempDir(), runner)
+
+	now := time.Now()
+	blocker.Ban("203.0.113.9", "ssh brute force", now)
+
+	blocker.Expire(now.Add(30 * time.Minute))
+	if len(blocker.Bans()) != 1 {
+		t.Fatal("ban lifted before its TTL")
+	}
+
+	blocker.Expire(now.Add(time.Hour + time.Second))
+	if len(blocker.Bans()) != 0 {
+		t.Error("ban kept after its TTL")
+	}
+	if want := []string{"ban 203.0.113.9", "unban 203.0.113.9"}; !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("commands = %q, want %q", runner.calls, want)
+	}
+	if got, want := actionList(blocker.Pending()), []string{"ban 203.0.113.9", "unban 203.0.113.9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %q, want %q", got, want)
+	}
+}
+
+func TestBlockerExpireUnbanFailure(t *testing.T) {
+	runner := &fakeRunner{fail: map[string]int{"unban 203.0.113.9": 1}}
+	blocker := newTestBlocker(t, t.TempDir(), runner)
+
+	now := time.Now()
+	blocker.Ban("203.0.113.9", "ssh brute force", now)
+	blocker.Expire(now.Add(2 * time.Hour))
+
+	if len(blocker.Bans()) != 0 {
+		t.Error("ban kept after a failed unban")
+	}
+	if got, want := actionList(blocker.Pending()), []string{"ban 203.0.113.9", "unban_failed 203.0.113.9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %q, want %q", got, want)
+	}
+}
+
+func TestBlockerPendingCommit(t *testing.T) {
+	dir := t.TempDir()
+	blocker := newTestBlocker(t, dir, &fakeRunner{})
+
+	blocker.Ban("203.0.113.9", "ssh brute force", time.Now())
+
+	// Commit without a Pending call has nothing to forget
+	if err := blocker.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(blocker.Pending()) != 1 {
+		t.Fatal("Commit() before Pending() dropped an action")
+	}
+
+	// Actions recorded after Pending are kept for the next report
+	blocker.Ban("203.0.113.10", "ssh brute force", time.Now())
+	if err := blocker.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actionList(blocker.Pending()), []string{"ban 203.0.113.10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions after Commit() = %q, want %q", got, want)
+	}
+
+	// Undelivered actions and bans survive a restart, Init applies the bans again
+	runner := &fakeRunner{}
+	restarted := newTestBlocker(t, dir, runner)
+	if err := restarted.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actionList(restarted.Pending()), []string{"ban 203.0.113.10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions after restart = %q, want %q", got, want)
+	}
+	if len(runner.calls) != 2 || len(restarted.Bans()) != 2 {
+		t.Errorf("restored bans = %d, commands = %q", len(restarted.Bans()), runner.calls)
+	}
+}
+
+func TestBlockerInitExpiresStaleBans(t *testing.T) {
+	dir := t.TempDir()
+	blocker := newTestBlocker(t, dir, &fakeRunner{})
+	blocker.state.Bans["203.0.113.9"] = Ban{IP: "203.0.113.9", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := blocker.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &fakeRunner{}
+	restarted := newTestBlocker(t, dir, runner)
+	if err := restarted.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"unban 203.0.113.9"}; !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("commands = %q, want %q", runner.calls, want)
+	}
+	if len(restarted.Bans()) != 0 {
+		t.Error("expired ban restored")
+	}
+}
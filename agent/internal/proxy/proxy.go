@@ -0,0 +1,269 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Direct disables proxies, including the ones from the environment
+const Direct = "none"
+
+// Config selects the proxy of outgoing HTTP requests
+type Config struct {
+	URL     string   // http, https, socks5 or socks5h URL with optional user:password, empty uses HTTPS_PROXY/HTTP_PROXY
+	NoProxy []string // hosts, .domains, IPs and CIDRs reached directly, in addition to NO_PROXY
+}
+
+// Error is a failure of the proxy rather than of the server behind it
+type Error struct {
+	Proxy      string // proxy URL without the password
+	StatusCode int    // status of a rejected CONNECT, 0 for other failures
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("proxy %s: %v", e.Proxy, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// AuthFailed reports whether the proxy rejected the credentials
+func (e *Error) AuthFailed() bool {
+	return e.StatusCode == http.StatusProxyAuthRequired ||
+		strings.Contains(e.Err.Error(), "authentication failed")
+}
+
+// Func returns the proxy selector of a config, as used by http.Transport.Proxy
+func (c Config) Func() (func(*http.Request) (*url.URL, error), error) {
+	noProxy := append(splitList(getEnv("NO_PROXY", "no_proxy")), c.NoProxy...)
+
+	switch c.URL {
+	case Direct:
+		return nil, nil
+	case "":
+		// http.ProxyFromEnvironment does not know socks5h before Go 1.22
+		// and takes such a value for an http proxy, map it as Parse does
+		httpsProxy, err := environmentSOCKS5h("HTTPS_PROXY", "https_proxy")
+		if err != nil {
+			return nil, err
+		}
+		httpProxy, err := environmentSOCKS5h("HTTP_PROXY", "http_proxy")
+		if err != nil {
+			return nil, err
+		}
+		return func(req *http.Request) (*url.URL, error) {
+			if bypass(noProxy, req.URL) {
+				return nil, nil
+			}
+			switch {
+			case req.URL.Scheme == "https" && httpsProxy != nil:
+				return httpsProxy, nil
+			case req.URL.Scheme == "http" && httpProxy != nil:
+				return httpProxy, nil
+			}
+			return http.ProxyFromEnvironment(req)
+		}, nil
+	}
+
+	proxyURL, err := Parse(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if bypass(noProxy, req.URL) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// Apply configures a transport to use the proxy of a config
+// A CONNECT the proxy answers with an error status fails with an *Error
+func (c Config) Apply(transport *http.Transport) error {
+	proxyFunc, err := c.Func()
+	if err != nil {
+		return err
+	}
+
+	transport.Proxy = proxyFunc
+	transport.OnProxyConnectResponse = func(ctx context.Context, proxyURL *url.URL, req *http.Request, resp *http.Response) error {
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		return &Error{
+			Proxy:      proxyURL.Redacted(),
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("CONNECT %s: %s", req.Host, resp.Status),
+		}
+	}
+	return nil
+}
+
+// Parse validates a proxy URL
+// socks5h is accepted as an alias of socks5: net/http only knows socks5
+// before Go 1.22, and its SOCKS5 dialer always sends the host name to the
+// proxy, so names are resolved remotely either way
+func Parse(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	case "socks5h":
+		proxyURL.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, want http, https, socks5 or socks5h", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy URL %s has no host", proxyURL.Redacted())
+	}
+	return proxyURL, nil
+}
+
+// environmentSOCKS5h parses the first non-empty environment variable when
+// it holds a socks5h URL, nil otherwise
+func environmentSOCKS5h(names ...string) (*url.URL, error) {
+	value := getEnv(names...)
+	if !strings.HasPrefix(strings.ToLower(value), "socks5h://") {
+		return nil, nil
+	}
+	proxyURL, err := Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", names[0], err)
+	}
+	return proxyURL, nil
+}
+
+// Wrap turns an error of a request sent through proxyURL into an *Error
+// when the proxy failed: it could not be reached, refused the tunnel or
+// rejected the credentials
+// Errors of the server behind the proxy are returned unchanged
+func Wrap(err error, proxyURL *url.URL) error {
+	if err == nil || proxyURL == nil {
+		return err
+	}
+
+	var proxyErr *Error
+	if errors.As(err, &proxyErr) {
+		return err
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks ")) {
+		return &Error{Proxy: proxyURL.Redacted(), Err: err}
+	}
+	return err
+}
+
+// CheckResponse returns an *Error when a request sent through proxyURL was
+// answered 407 Proxy Authentication Required; requests to plain http URLs
+// are not tunneled, so the proxy answers them itself instead of a CONNECT
+func CheckResponse(resp *http.Response, proxyURL *url.URL) error {
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		return nil
+	}
+
+	name := "(unknown)"
+	if proxyURL != nil {
+		name = proxyURL.Redacted()
+	}
+	target := ""
+	if resp.Request != nil {
+		target = resp.Request.Method + " " + resp.Request.URL.Redacted() + ": "
+	}
+	return &Error{
+		Proxy:      name,
+		StatusCode: resp.StatusCode,
+		Err:        fmt.Errorf("%s%s", target, resp.Status),
+	}
+}
+
+// Is reports whether err is a proxy failure
+func Is(err error) bool {
+	var proxyErr *Error
+	return errors.As(err, &proxyErr)
+}
+
+// bypass reports whether a request URL matches a NO_PROXY entry
+func bypass(noProxy []string, target *url.URL) bool {
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+	if port == "" {
+		switch target.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		// An entry may name a port, "example.com:8443"
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+
+		if ip := net.ParseIP(entry); ip != nil {
+			if ip.Equal(net.ParseIP(host)) {
+				return true
+			}
+			continue
+		}
+
+		// "example.com" and ".example.com" both cover the subdomains
+		domain := strings.TrimPrefix(entry, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated list
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnv returns the first non-empty environment variable
+func getEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
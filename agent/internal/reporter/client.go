@@ -2,24 +2,49 @@ package reporter
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/proxy"
+	"zenoguard-agent/internal/signing"
 )
 
 // ReportData represents data to be reported
 type ReportData struct {
-	Hostname       string              `json:"hostname"`
-	SSHLogins      []SSHLoginReport    `json:"ssh_logins"`
-	SystemLoad     SystemLoadReport    `json:"system_load"`
-	NetworkTraffic NetworkTrafficReport `json:"network_traffic"`
-	PublicIP       string              `json:"public_ip"`
+	Hostname         string                  `json:"hostname"`
+	SSHLogins        []SSHLoginReport        `json:"ssh_logins"`
+	SSHSessions      []SSHSessionReport      `json:"ssh_sessions,omitempty"`
+	BruteForceEvents []BruteForceEventReport `json:"bruteforce_events,omitempty"`
+	FirewallActions  []FirewallActionReport  `json:"firewall_actions,omitempty"`
+	SystemLoad       SystemLoadReport        `json:"system_load"`
+	CPU              *CPUReport              `json:"cpu,omitempty"`
+	Memory           *MemoryReport           `json:"memory,omitempty"`
+	Filesystems      []FilesystemReport      `json:"filesystems,omitempty"`
+	DiskIO           *DiskIOReport           `json:"disk_io,omitempty"`
+	Pressure         *PressureReport         `json:"pressure,omitempty"`
+	Processes        *ProcessesReport        `json:"processes,omitempty"`
+	Listeners        []ListenerReport        `json:"listeners,omitempty"`
+	ListenerEvents   []ListenerEventReport   `json:"listener_events,omitempty"`
+	Connections      *ConnectionsReport      `json:"connections,omitempty"`
+	ThreatEvents     []ThreatEventReport     `json:"threat_events,omitempty"`
+	FileEvents       []FileEventReport       `json:"file_events,omitempty"`
+	AuthorizedKeys   []AuthorizedKeyReport   `json:"authorized_keys,omitempty"`
+	KeyEvents        []KeyEventReport        `json:"key_events,omitempty"`
+	AccountEvents    []AccountEventReport    `json:"account_events,omitempty"`
+	NetworkTraffic   NetworkTrafficReport    `json:"network_traffic"`
+	PublicIP         string                  `json:"public_ip"`
+
+	LoginAccounting *LoginAccountingReport `json:"login_accounting,omitempty"`
+	PrivilegeEvents []PrivilegeEventReport `json:"privilege_events,omitempty"`
+
+	Truncated *TruncationReport `json:"truncated,omitempty"` // set when sections were cut to fit the payload cap
 }
 
 // SSHLoginReport represents SSH login info for reporting
@@ -33,6 +58,105 @@ type SSHLoginReport struct {
 	Protocol        string `json:"protocol"`
 	SessionDuration int64  `json:"session_duration"` // seconds
 	IsActive        bool   `json:"is_active"`        // currently logged in
+	PID             int    `json:"pid,omitempty"`    // sshd process that logged the entry
+	KeyType         string `json:"key_type,omitempty"`
+	KeyFingerprint  string `json:"key_fingerprint,omitempty"` // ties a publickey login to an authorized key
+}
+
+// SSHSessionReport represents an SSH session for reporting
+type SSHSessionReport struct {
+	PID              int    `json:"pid"`
+	User             string `json:"user"`
+	IP               string `json:"ip"`
+	Port             int    `json:"port"`
+	Method           string `json:"method"`
+	Start            string `json:"start"`
+	End              string `json:"end,omitempty"` // empty while active
+	Duration         int64  `json:"duration"`      // seconds
+	DisconnectReason string `json:"disconnect_reason,omitempty"`
+	IsActive         bool   `json:"is_active"`
+}
+
+// BruteForceEventReport represents an SSH brute-force detection for reporting
+type BruteForceEventReport struct {
+	Event         string   `json:"event"`  // bruteforce_detected
+	Kind          string   `json:"kind"`   // ip, user
+	Reason        string   `json:"reason"` // failures, usernames
+	IP            string   `json:"ip,omitempty"`
+	User          string   `json:"user,omitempty"`
+	Failures      int      `json:"failures"`
+	DistinctUsers int      `json:"distinct_users"`
+	DistinctIPs   int      `json:"distinct_ips"`
+	FirstSeen     string   `json:"first_seen"`
+	LastSeen      string   `json:"last_seen"`
+	Usernames     []string `json:"usernames"`
+	IPs           []string `json:"ips,omitempty"` // user events only
+	Window        int64    `json:"window"`        // seconds
+}
+
+// FirewallActionReport represents a ban or unban for reporting
+type FirewallActionReport struct {
+	Time      string `json:"time"`
+	Action    string `json:"action"` // ban, unban, allowlisted, ban_failed, unban_failed
+	IP        string `json:"ip"`
+	Backend   string `json:"backend"`
+	Reason    string `json:"reason"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// LoginAccountingReport represents wtmp/btmp/lastlog records for reporting
+type LoginAccountingReport struct {
+	Logins     []LoginRecordReport  `json:"logins"`
+	Failed     []LoginRecordReport  `json:"failed"`
+	System     []SystemRecordReport `json:"system"`
+	LastLogins []LastLoginReport    `json:"last_logins"`
+}
+
+// LoginRecordReport represents a login, logout or failed login for reporting
+type LoginRecordReport struct {
+	Event      string `json:"event"` // login, logout, failed
+	User       string `json:"user"`
+	Line       string `json:"line"`
+	Host       string `json:"host"`
+	IP         string `json:"ip"`
+	PID        int    `json:"pid"`
+	LoginTime  string `json:"login_time,omitempty"`
+	LogoutTime string `json:"logout_time,omitempty"`
+	Duration   int64  `json:"duration"` // seconds
+	Reason     string `json:"reason,omitempty"`
+}
+
+// SystemRecordReport represents a boot or shutdown for reporting
+type SystemRecordReport struct {
+	Event  string `json:"event"` // boot, shutdown
+	Time   string `json:"time"`
+	Kernel string `json:"kernel"`
+}
+
+// LastLoginReport represents a lastlog entry for reporting
+type LastLoginReport struct {
+	User string `json:"user"`
+	UID  int    `json:"uid"`
+	Line string `json:"line"`
+	Host string `json:"host"`
+	Time string `json:"time"`
+}
+
+// PrivilegeEventReport represents a sudo or su event for reporting
+type PrivilegeEventReport struct {
+	Time       string `json:"time"`
+	Tool       string `json:"tool"`  // sudo, su
+	Event      string `json:"event"` // command, session_open, auth_failure, not_in_sudoers, denied
+	User       string `json:"user"`
+	TargetUser string `json:"target_user"`
+	TTY        string `json:"tty,omitempty"`
+	PWD        string `json:"pwd,omitempty"`
+	Command    string `json:"command,omitempty"`
+	Attempts   int    `json:"attempts,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Success    bool   `json:"success"`
+	PID        int    `json:"pid,omitempty"`
 }
 
 // SystemLoadReport represents system load for reporting
@@ -42,6 +166,268 @@ type SystemLoadReport struct {
 	Load15 float64 `json:"load15"`
 }
 
+// CPUReport represents CPU utilization for reporting
+type CPUReport struct {
+	Interval        float64          `json:"interval"` // seconds
+	Total           CPUUsageReport   `json:"total"`
+	Cores           []CPUUsageReport `json:"cores"`
+	ContextSwitches uint64           `json:"context_switches"`
+	ContextRate     float64          `json:"context_rate"` // per second
+	Forks           uint64           `json:"forks"`
+	ForkRate        float64          `json:"fork_rate"` // per second
+	ProcsRunning    int              `json:"procs_running"`
+	ProcsBlocked    int              `json:"procs_blocked"`
+}
+
+// CPUUsageReport represents per-mode CPU percentages for reporting
+type CPUUsageReport struct {
+	CPU     string  `json:"cpu"`
+	User    float64 `json:"user"`
+	Nice    float64 `json:"nice"`
+	System  float64 `json:"system"`
+	Idle    float64 `json:"idle"`
+	IOWait  float64 `json:"iowait"`
+	IRQ     float64 `json:"irq"`
+	SoftIRQ float64 `json:"softirq"`
+	Steal   float64 `json:"steal"`
+	Busy    float64 `json:"busy"`
+}
+
+// MemoryReport represents memory and swap usage for reporting
+type MemoryReport struct {
+	Total           uint64  `json:"total"` // bytes
+	Available       uint64  `json:"available"`
+	Used            uint64  `json:"used"`
+	Free            uint64  `json:"free"`
+	Buffers         uint64  `json:"buffers"`
+	Cached          uint64  `json:"cached"`
+	Slab            uint64  `json:"slab"`
+	SReclaimable    uint64  `json:"sreclaimable"`
+	Shmem           uint64  `json:"shmem"`
+	UsedPercent     float64 `json:"used_percent"`
+	SwapTotal       uint64  `json:"swap_total"`
+	SwapFree        uint64  `json:"swap_free"`
+	SwapUsed        uint64  `json:"swap_used"`
+	SwapUsedPercent float64 `json:"swap_used_percent"`
+	Interval        float64 `json:"interval"`      // seconds, 0 when no rates are available yet
+	SwapInRate      float64 `json:"swap_in_rate"`  // pages per second
+	SwapOutRate     float64 `json:"swap_out_rate"` // pages per second
+	PageFaultRate   float64 `json:"page_fault_rate"`
+	MajorFaultRate  float64 `json:"major_fault_rate"`
+}
+
+// FilesystemReport represents filesystem usage for reporting
+type FilesystemReport struct {
+	Device            string  `json:"device"`
+	MountPoint        string  `json:"mount_point"`
+	FSType            string  `json:"fs_type"`
+	Total             uint64  `json:"total"` // bytes
+	Used              uint64  `json:"used"`
+	Free              uint64  `json:"free"`
+	Available         uint64  `json:"available"`
+	UsedPercent       float64 `json:"used_percent"`
+	Inodes            uint64  `json:"inodes"`
+	InodesUsed        uint64  `json:"inodes_used"`
+	InodesFree        uint64  `json:"inodes_free"`
+	InodesUsedPercent float64 `json:"inodes_used_percent"`
+	ReadOnly          bool    `json:"read_only"`
+	RemountedRO       bool    `json:"remounted_ro"`
+}
+
+// DiskIOReport represents block device I/O statistics for reporting
+type DiskIOReport struct {
+	Interval float64            `json:"interval"` // seconds
+	Devices  []DiskDeviceReport `json:"devices"`
+}
+
+// DiskDeviceReport represents the I/O activity of one device for reporting
+type DiskDeviceReport struct {
+	Device         string  `json:"device"`
+	Name           string  `json:"name,omitempty"`
+	ReadBytesRate  float64 `json:"read_bytes_rate"`  // bytes per second
+	WriteBytesRate float64 `json:"write_bytes_rate"` // bytes per second
+	ReadIOPS       float64 `json:"read_iops"`
+	WriteIOPS      float64 `json:"write_iops"`
+	ReadAwait      float64 `json:"read_await"` // ms
+	WriteAwait     float64 `json:"write_await"`
+	Await          float64 `json:"await"`
+	Utilization    float64 `json:"utilization"` // percent
+	InProgress     uint64  `json:"in_progress"`
+}
+
+// PressureReport represents pressure stall information for reporting
+type PressureReport struct {
+	Interval float64                 `json:"interval"` // seconds covered by delta and percent, 0 on the first report
+	CPU      *PressureResourceReport `json:"cpu,omitempty"`
+	Memory   *PressureResourceReport `json:"memory,omitempty"`
+	IO       *PressureResourceReport `json:"io,omitempty"`
+}
+
+// PressureResourceReport represents the pressure of one resource for reporting
+type PressureResourceReport struct {
+	Some PressureLineReport  `json:"some"`
+	Full *PressureLineReport `json:"full,omitempty"`
+}
+
+// PressureLineReport represents one PSI line for reporting
+type PressureLineReport struct {
+	Avg10   float64 `json:"avg10"`
+	Avg60   float64 `json:"avg60"`
+	Avg300  float64 `json:"avg300"`
+	Total   uint64  `json:"total"`   // microseconds
+	Delta   uint64  `json:"delta"`   // microseconds stalled during the interval
+	Percent float64 `json:"percent"` // stalled share of the interval
+}
+
+// ProcessesReport represents the top processes for reporting
+type ProcessesReport struct {
+	Interval  float64         `json:"interval"` // seconds covered by cpu_percent
+	Total     int             `json:"total"`
+	TopCPU    []ProcessReport `json:"top_cpu"`
+	TopMemory []ProcessReport `json:"top_memory"`
+	TopFDs    []ProcessReport `json:"top_fds"`
+}
+
+// ProcessReport represents a process for reporting
+type ProcessReport struct {
+	PID        int     `json:"pid"`
+	PPID       int     `json:"ppid"`
+	Name       string  `json:"name"`
+	User       string  `json:"user"`
+	UID        int     `json:"uid"`
+	State      string  `json:"state"`
+	Cmdline    string  `json:"cmdline"`
+	CPUPercent float64 `json:"cpu_percent"`
+	RSS        uint64  `json:"rss"` // bytes
+	FDs        int     `json:"fds"` // -1 if unreadable
+	Threads    int     `json:"threads"`
+	StartTime  string  `json:"start_time"` // RFC3339
+}
+
+// ListenerReport represents a listening socket for reporting
+type ListenerReport struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+	PID      int    `json:"pid,omitempty"`
+	Process  string `json:"process,omitempty"`
+	Exe      string `json:"exe,omitempty"`
+	User     string `json:"user"`
+	UID      int    `json:"uid"`
+}
+
+// ListenerEventReport represents a listener change for reporting
+type ListenerEventReport struct {
+	Time     string         `json:"time"`  // RFC3339
+	Event    string         `json:"event"` // listener_added, listener_removed
+	Listener ListenerReport `json:"listener"`
+}
+
+// ConnectionsReport represents the TCP connection summary for reporting
+type ConnectionsReport struct {
+	Total       int                       `json:"total"`
+	States      map[string]int            `json:"states"`
+	Established int                       `json:"established"`
+	Outbound    int                       `json:"outbound"`
+	Inbound     int                       `json:"inbound"`
+	TopRemotes  []RemoteCountReport       `json:"top_remotes"`
+	TopPorts    []PortCountReport         `json:"top_ports"`
+	Processes   []ConnectionProcessReport `json:"processes"`
+}
+
+// RemoteCountReport represents connections with one remote address for reporting
+type RemoteCountReport struct {
+	Address string `json:"address"`
+	Count   int    `json:"count"`
+}
+
+// PortCountReport represents outbound connections to one port for reporting
+type PortCountReport struct {
+	Port  int `json:"port"`
+	Count int `json:"count"`
+}
+
+// ConnectionProcessReport represents a process with connections for reporting
+type ConnectionProcessReport struct {
+	PID     int    `json:"pid"`
+	Process string `json:"process"`
+	Exe     string `json:"exe"`
+	Count   int    `json:"count"`
+}
+
+// ThreatEventReport represents a threat-intel match for reporting
+type ThreatEventReport struct {
+	Time       string `json:"time"` // RFC3339
+	Event      string `json:"event"`
+	Severity   string `json:"severity"`
+	Direction  string `json:"direction"`
+	State      string `json:"state"`
+	LocalIP    string `json:"local_ip"`
+	LocalPort  int    `json:"local_port"`
+	RemoteIP   string `json:"remote_ip"`
+	RemotePort int    `json:"remote_port"`
+	Match      string `json:"match"`
+	Label      string `json:"label,omitempty"`
+	PID        int    `json:"pid,omitempty"`
+	Process    string `json:"process,omitempty"`
+	Exe        string `json:"exe,omitempty"`
+}
+
+// FileEventReport represents a file integrity change for reporting
+type FileEventReport struct {
+	Time    string          `json:"time"`  // RFC3339
+	Event   string          `json:"event"` // created, modified, deleted
+	Path    string          `json:"path"`
+	Source  string          `json:"source"` // inotify, scan
+	Changes []string        `json:"changes"`
+	Before  *FileMetaReport `json:"before,omitempty"`
+	After   *FileMetaReport `json:"after,omitempty"`
+}
+
+// FileMetaReport represents the state of a monitored file for reporting
+type FileMetaReport struct {
+	Type    string `json:"type"`
+	Size    int64  `json:"size"`
+	Mode    string `json:"mode"`
+	UID     int    `json:"uid"`
+	GID     int    `json:"gid"`
+	Owner   string `json:"owner,omitempty"`
+	ModTime string `json:"mtime"` // RFC3339
+	SHA256  string `json:"sha256,omitempty"`
+	Target  string `json:"target,omitempty"`
+}
+
+// AuthorizedKeyReport represents an authorized SSH key for reporting
+type AuthorizedKeyReport struct {
+	User        string   `json:"user"`
+	File        string   `json:"file"`
+	Line        int      `json:"line"`
+	Type        string   `json:"type"`
+	Fingerprint string   `json:"fingerprint"` // SHA256:...
+	Comment     string   `json:"comment,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// KeyEventReport represents an authorized key change for reporting
+type KeyEventReport struct {
+	Time  string              `json:"time"`  // RFC3339
+	Event string              `json:"event"` // key_added, key_removed
+	Key   AuthorizedKeyReport `json:"key"`
+}
+
+// AccountEventReport represents a local user or group change for reporting
+// Password changes carry the shadow lastchg date, never the hash
+type AccountEventReport struct {
+	Time       string `json:"time"` // RFC3339
+	Event      string `json:"event"`
+	Severity   string `json:"severity"`
+	User       string `json:"user,omitempty"`
+	Group      string `json:"group,omitempty"`
+	Before     string `json:"before,omitempty"`
+	After      string `json:"after,omitempty"`
+	LastChange string `json:"last_change,omitempty"`
+}
+
 // NetworkTrafficReport represents network traffic for reporting
 type NetworkTrafficReport struct {
 	Interface    string                 `json:"interface"`
@@ -62,8 +448,25 @@ type TrafficSampleReport struct {
 
 // ReportResponse represents server response
 type ReportResponse struct {
-	Success        bool `json:"success"`
-	ReportInterval int  `json:"report_interval"`
+	Success        bool     `json:"success"`
+	ReportInterval int      `json:"report_interval"`
+	Compression    []string `json:"compression,omitempty"` // request body encodings the server accepts
+}
+
+// ClientConfig configures how reports are sent
+type ClientConfig struct {
+	Compression    string // auto, none or gzip, empty means auto
+	MaxPayloadSize int64  // bytes of report JSON, 0 uses the default and a negative value disables the cap
+
+	CAFile     string   // PEM bundle of trusted CAs, replaces the system roots
+	CertFile   string   // client certificate for mutual TLS
+	KeyFile    string   // private key of the client certificate
+	ServerName string   // name expected in the server certificate, defaults to the URL host
+	Pins       []string // base64 SHA-256 SPKI pins, the server chain must match one
+
+	Proxy proxy.Config // proxy of the report requests, HTTPS_PROXY/NO_PROXY by default
+
+	SigningSecret string // per-host secret signing every request with HMAC-SHA256, empty disables signing
 }
 
 // Client represents an HTTPS client for reporting
@@ -71,69 +474,278 @@ type Client struct {
 	serverURL string
 	token     string
 	httpClient *http.Client
+	config     ClientConfig
+	tlsStamp   string // certificate files the HTTP client was built from
+	tlsFailed  string // certificate files that failed to load, not retried until they change
+	tlsErr     error  // set while no usable TLS or proxy configuration could be loaded
+
+	signer *signing.Signer // nil unless a signing secret is configured
+
+	compression    string
+	maxPayloadSize int
+	encoding       string // negotiated request body encoding, empty for plain JSON
+	advertised     string // encodings of the last server response, comma separated
+	refused        bool   // the server answered 415 while advertising the encodings above
+	mu             sync.Mutex
 }
 
 // NewClient creates a new HTTPS client
-func NewClient(serverURL, token string) *Client {
+func NewClient(serverURL, token string, config ClientConfig) *Client {
 	// Normalize serverURL: remove trailing /api/ if present
 	serverURL = strings.TrimSuffix(serverURL, "/")
 	if strings.HasSuffix(serverURL, "/api") {
 		serverURL = strings.TrimSuffix(serverURL, "/api")
 	}
 
+	c := &Client{
+		serverURL: serverURL,
+		token:     token,
+		config:    config,
+	}
+
 	// Create HTTP client with timeout and TLS config
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				// Allow self-signed certificates for flexibility
-				InsecureSkipVerify: false,
-				MinVersion:         tls.VersionTLS12,
-			},
-			MaxIdleConns:        10,
-			IdleConnTimeout:     30 * time.Second,
-			DisableCompression:  false,
-		},
+	c.reloadTLS()
+
+	if config.SigningSecret != "" {
+		c.signer = signing.NewSigner([]byte(config.SigningSecret))
+	}
+
+	switch config.Compression {
+	case "", CompressionAuto:
+		c.compression = CompressionAuto
+	case CompressionNone:
+		c.compression = CompressionNone
+	case CompressionGzip:
+		c.compression = CompressionGzip
+		c.encoding = CompressionGzip
+	default:
+		logger.Warn("Unsupported report compression " + config.Compression + ", using auto")
+		c.compression = CompressionAuto
+	}
+
+	switch {
+	case config.MaxPayloadSize < 0:
+		c.maxPayloadSize = 0
+	case config.MaxPayloadSize == 0:
+		c.maxPayloadSize = DefaultMaxPayloadSize
+	case config.MaxPayloadSize < MinMaxPayloadSize:
+		c.maxPayloadSize = MinMaxPayloadSize
+	default:
+		c.maxPayloadSize = int(config.MaxPayloadSize)
 	}
 
-	return &Client{
-		serverURL:  serverURL,
-		token:      token,
-		httpClient: client,
+	return c
+}
+
+// StatusError is a response of the server other than 200 OK
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned error: %d - %s", e.StatusCode, e.Body)
+}
+
+// isPermanent reports whether the server rejected a report for good, so
+// sending it again cannot succeed
+// Only statuses that judge the report itself count: a 403 from a WAF or a
+// 404 from a wrong base URL or proxy route says nothing about the report,
+// which is kept and retried. 413 only reaches here once the payload cap
+// cannot be lowered any further
+// Proxy failures never are, the server has not seen the report
+func isPermanent(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || proxy.Is(err) {
+		return false
+	}
+	switch statusErr.StatusCode {
+	case http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return true
 	}
+	return false
+}
+
+// ClockSkewError is a request rejected because the local clock is too far
+// from the server clock for the signature to be accepted
+type ClockSkewError struct {
+	ServerTime time.Time
+}
+
+func (e *ClockSkewError) Error() string {
+	return fmt.Sprintf("signature rejected, clock differs from server time %s", e.ServerTime.Format(time.RFC3339))
 }
 
 // Report sends data to the server
-func (c *Client) Report(data *ReportData) (*ReportResponse, error) {
-	logger.Info("Reporting to server: " + c.serverURL)
+// key identifies the report, so the server can drop a report it already
+// received when a response got lost and the report is sent again
+func (c *Client) Report(data *ReportData, key string) (*ReportResponse, error) {
 	logger.Info(fmt.Sprintf("Sending %d SSH log entries", len(data.SSHLogins)))
 
 	// Marshal data to JSON
-	jsonData, err := json.Marshal(data)
+	jsonData, err := c.Encode(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal report data: %w", err)
+		return nil, err
+	}
+
+	return c.Send(jsonData, key)
+}
+
+// Encode marshals a report within the payload cap
+func (c *Client) Encode(data *ReportData) ([]byte, error) {
+	c.mu.Lock()
+	maxSize := c.maxPayloadSize
+	c.mu.Unlock()
+
+	return encodeReport(data, maxSize)
+}
+
+// lowerPayloadCap halves the payload cap below the size of a body the server
+// rejected as too large, the server limit holds for the next reports too
+// It returns false once the cap cannot go lower
+func (c *Client) lowerPayloadCap(rejected int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if rejected <= MinMaxPayloadSize {
+		return false
+	}
+
+	maxSize := rejected / 2
+	if maxSize < MinMaxPayloadSize {
+		maxSize = MinMaxPayloadSize
+	}
+	logger.Warn(fmt.Sprintf("Lowering the report payload cap to %d bytes", maxSize))
+	c.maxPayloadSize = maxSize
+	return true
+}
+
+// Send posts an already marshaled report to the server
+// The body is compressed with the encoding negotiated with the server; if
+// the server no longer accepts it, the report is sent again uncompressed
+func (c *Client) Send(jsonData []byte, key string) (*ReportResponse, error) {
+	logger.Info("Reporting to server: " + c.serverURL)
+
+	c.mu.Lock()
+	encoding := c.encoding
+	c.mu.Unlock()
+
+	response, err := c.send(jsonData, key, encoding)
+
+	// Sign with the server time from now on and try again
+	var skewErr *ClockSkewError
+	if errors.As(err, &skewErr) && c.signer != nil {
+		offset := c.signer.SyncClock(skewErr.ServerTime)
+		logger.Warn(fmt.Sprintf("Local clock is %v off the server, signing requests with the server time", -offset))
+		response, err = c.send(jsonData, key, encoding)
+	}
+
+	var statusErr *StatusError
+	if encoding != "" && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnsupportedMediaType {
+		logger.Warn("Server rejected " + encoding + " compressed report, sending uncompressed")
+		c.mu.Lock()
+		c.refused = true
+		c.mu.Unlock()
+		c.setEncoding("")
+		response, err = c.send(jsonData, key, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.compression == CompressionAuto {
+		c.negotiate(response.Compression)
+	}
+	return response, nil
+}
+
+// negotiate picks the encoding of the next reports from the encodings the
+// server advertised
+// After a 415 the server does not honour what it advertises, so reports
+// stay uncompressed until it advertises something else
+func (c *Client) negotiate(accepted []string) {
+	advertised := strings.Join(accepted, ",")
+
+	c.mu.Lock()
+	if advertised != c.advertised {
+		c.advertised = advertised
+		c.refused = false
+	}
+	refused := c.refused
+	c.mu.Unlock()
+
+	if refused {
+		c.setEncoding("")
+		return
+	}
+	c.setEncoding(negotiateEncoding(accepted))
+}
+
+// setEncoding changes the request body encoding
+func (c *Client) setEncoding(encoding string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if encoding != c.encoding {
+		logger.Info(fmt.Sprintf("Report compression: %q -> %q", c.encoding, encoding))
+		c.encoding = encoding
+	}
+}
+
+// send posts a report body with a Content-Encoding, empty for none
+func (c *Client) send(jsonData []byte, key, encoding string) (*ReportResponse, error) {
+	payload := jsonData
+	if encoding != "" && len(jsonData) >= compressionMinSize {
+		compressed, err := compressBody(encoding, jsonData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress report: %w", err)
+		}
+		logger.Debug("Compressed report with %s: %d -> %d bytes", encoding, len(jsonData), len(compressed))
+		payload = compressed
+	} else {
+		encoding = ""
 	}
 
 	// Create request
 	url := fmt.Sprintf("%s/api/agent/report", c.serverURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("User-Agent", "ZenoGuard-Agent/1.0")
+	req.Header.Set("Idempotency-Key", key)
+	if c.signer != nil {
+		if err := c.signer.Sign(req, payload); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
 
 	// Send request
 	logger.Debug("Sending request to: " + url)
-	resp, err := c.httpClient.Do(req)
+	httpClient, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
+		if err := proxy.Wrap(err, requestProxy(httpClient, req)); proxy.Is(err) {
+			return nil, fmt.Errorf("proxy failure, server not reached: %w", err)
+		}
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := proxy.CheckResponse(resp, requestProxy(httpClient, req)); err != nil {
+		return nil, fmt.Errorf("proxy failure, server not reached: %w", err)
+	}
+
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -144,11 +756,17 @@ func (c *Client) Report(data *ReportData) (*ReportResponse, error) {
 	logger.Debug(fmt.Sprintf("Response status: %d", resp.StatusCode))
 
 	if resp.StatusCode == http.StatusUnauthorized {
+		if code, serverTime, ok := signing.ParseErrorResponse(resp.Header, body); ok && c.signer != nil {
+			if code == signing.ErrorCodeClockSkew {
+				return nil, &ClockSkewError{ServerTime: serverTime}
+			}
+			return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
 		return nil, fmt.Errorf("unauthorized: invalid token")
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned error: %d - %s", resp.StatusCode, string(body))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Parse response
@@ -174,13 +792,29 @@ func (c *Client) TestConnection() error {
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.token)
+	if c.signer != nil {
+		if err := c.signer.Sign(req, []byte("{}")); err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
 
-	resp, err := c.httpClient.Do(req)
+	httpClient, err := c.client()
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
+		if err := proxy.Wrap(err, requestProxy(httpClient, req)); proxy.Is(err) {
+			return fmt.Errorf("proxy failure, server not reached: %w", err)
+		}
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := proxy.CheckResponse(resp, requestProxy(httpClient, req)); err != nil {
+		return fmt.Errorf("proxy failure, server not reached: %w", err)
+	}
+
 	// Unauthorized is expected for test request with empty data
 	// Just check if we can reach the server
 	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusBadRequest {
@@ -193,6 +827,9 @@ func (c *Client) TestConnection() error {
 
 // Close closes the HTTP client
 func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.httpClient != nil {
 		c.httpClient.CloseIdleConnections()
 	}
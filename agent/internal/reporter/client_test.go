@@ -0,0 +1,51 @@
+package reporter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"zenoguard-agent/internal/proxy"
+)
+
+func TestIsPermanent(t *testing.T) {
+	status := func(code int) error {
+		return &StatusError{StatusCode: code, Body: http.StatusText(code)}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "bad request", err: status(http.StatusBadRequest), want: true},
+		{name: "conflict", err: status(http.StatusConflict), want: true},
+		{name: "too large at the cap floor", err: status(http.StatusRequestEntityTooLarge), want: true},
+		{name: "unprocessable", err: status(http.StatusUnprocessableEntity), want: true},
+		{name: "wrapped", err: fmt.Errorf("max retries exceeded: %w", status(http.StatusBadRequest)), want: true},
+
+		{name: "unauthorized", err: status(http.StatusUnauthorized), want: false},
+		{name: "forbidden by a WAF", err: status(http.StatusForbidden), want: false},
+		{name: "wrong base URL", err: status(http.StatusNotFound), want: false},
+		{name: "method not allowed", err: status(http.StatusMethodNotAllowed), want: false},
+		{name: "proxy auth", err: status(http.StatusProxyAuthRequired), want: false},
+		{name: "timeout", err: status(http.StatusRequestTimeout), want: false},
+		{name: "gone", err: status(http.StatusGone), want: false},
+		{name: "unsupported media type", err: status(http.StatusUnsupportedMediaType), want: false},
+		{name: "rate limited", err: status(http.StatusTooManyRequests), want: false},
+		{name: "server error", err: status(http.StatusInternalServerError), want: false},
+		{name: "bad gateway", err: status(http.StatusBadGateway), want: false},
+		{name: "proxy failure", err: &proxy.Error{Proxy: "http://proxy.internal:3128", Err: status(http.StatusBadRequest)}, want: false},
+		{name: "network error", err: errors.New("connection refused"), want: false},
+		{name: "nil", err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPermanent(tt.err); got != tt.want {
+				t.Errorf("isPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
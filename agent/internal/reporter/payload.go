@@ -0,0 +1,239 @@
+package reporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+
+	"zenoguard-agent/internal/logger"
+)
+
+const (
+	// DefaultMaxPayloadSize is the default cap of a report's JSON body
+	DefaultMaxPayloadSize = 8 * 1024 * 1024
+	// MinMaxPayloadSize is the smallest accepted cap, enough for a report
+	// with every section truncated
+	MinMaxPayloadSize = 64 * 1024
+
+	// compressionMinSize is the body size below which compression is skipped
+	compressionMinSize = 1024
+)
+
+// Request body compression settings
+// zstd is not built in, a server offering only zstd gets plain JSON
+const (
+	CompressionAuto = "auto" // use what the server advertises
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// supportedEncodings are the Content-Encodings the client can produce, in
+// order of preference
+var supportedEncodings = []string{CompressionGzip}
+
+// TruncationReport marks a report cut down to the payload cap
+type TruncationReport struct {
+	OriginalSize int                      `json:"original_size"` // bytes of the complete report
+	MaxSize      int                      `json:"max_size"`
+	Sections     []TruncatedSectionReport `json:"sections"`
+}
+
+// TruncatedSectionReport is a section reduced by the payload cap
+type TruncatedSectionReport struct {
+	Section  string `json:"section"`
+	Original int    `json:"original"` // entries before truncation
+	Kept     int    `json:"kept"`
+}
+
+// payloadSection is a part of the report that may be truncated
+// count returns the number of entries and keep cuts the section down to its
+// last n entries, so the most recent events survive
+type payloadSection struct {
+	name  string
+	count func(data *ReportData) int
+	keep  func(data *ReportData, n int)
+}
+
+// payloadSections are truncated in this order, lowest priority first:
+// metrics and inventories go before login records and security events
+var payloadSections = []payloadSection{
+	{"network_traffic.samples",
+		func(d *ReportData) int { return len(d.NetworkTraffic.Samples) },
+		func(d *ReportData, n int) {
+			d.NetworkTraffic.Samples = d.NetworkTraffic.Samples[len(d.NetworkTraffic.Samples)-n:]
+			d.NetworkTraffic.SampleCount = n
+		}},
+	{"processes",
+		func(d *ReportData) int { return boolCount(d.Processes != nil) },
+		func(d *ReportData, n int) { d.Processes = nil }},
+	{"connections",
+		func(d *ReportData) int { return boolCount(d.Connections != nil) },
+		func(d *ReportData, n int) { d.Connections = nil }},
+	{"listeners",
+		func(d *ReportData) int { return len(d.Listeners) },
+		func(d *ReportData, n int) { d.Listeners = d.Listeners[len(d.Listeners)-n:] }},
+	{"authorized_keys",
+		func(d *ReportData) int { return len(d.AuthorizedKeys) },
+		func(d *ReportData, n int) { d.AuthorizedKeys = d.AuthorizedKeys[len(d.AuthorizedKeys)-n:] }},
+	{"filesystems",
+		func(d *ReportData) int { return len(d.Filesystems) },
+		func(d *ReportData, n int) { d.Filesystems = d.Filesystems[len(d.Filesystems)-n:] }},
+	{"disk_io",
+		func(d *ReportData) int { return boolCount(d.DiskIO != nil) },
+		func(d *ReportData, n int) { d.DiskIO = nil }},
+	{"pressure",
+		func(d *ReportData) int { return boolCount(d.Pressure != nil) },
+		func(d *ReportData, n int) { d.Pressure = nil }},
+	{"login_accounting",
+		func(d *ReportData) int { return boolCount(d.LoginAccounting != nil) },
+		func(d *ReportData, n int) { d.LoginAccounting = nil }},
+	{"ssh_sessions",
+		func(d *ReportData) int { return len(d.SSHSessions) },
+		func(d *ReportData, n int) { d.SSHSessions = d.SSHSessions[len(d.SSHSessions)-n:] }},
+	{"ssh_logins",
+		func(d *ReportData) int { return len(d.SSHLogins) },
+		func(d *ReportData, n int) { d.SSHLogins = d.SSHLogins[len(d.SSHLogins)-n:] }},
+	{"listener_events",
+		func(d *ReportData) int { return len(d.ListenerEvents) },
+		func(d *ReportData, n int) { d.ListenerEvents = d.ListenerEvents[len(d.ListenerEvents)-n:] }},
+	{"file_events",
+		func(d *ReportData) int { return len(d.FileEvents) },
+		func(d *ReportData, n int) { d.FileEvents = d.FileEvents[len(d.FileEvents)-n:] }},
+	{"key_events",
+		func(d *ReportData) int { return len(d.KeyEvents) },
+		func(d *ReportData, n int) { d.KeyEvents = d.KeyEvents[len(d.KeyEvents)-n:] }},
+	{"account_events",
+		func(d *ReportData) int { return len(d.AccountEvents) },
+		func(d *ReportData, n int) { d.AccountEvents = d.AccountEvents[len(d.AccountEvents)-n:] }},
+	{"privilege_events",
+		func(d *ReportData) int { return len(d.PrivilegeEvents) },
+		func(d *ReportData, n int) { d.PrivilegeEvents = d.PrivilegeEvents[len(d.PrivilegeEvents)-n:] }},
+	{"firewall_actions",
+		func(d *ReportData) int { return len(d.FirewallActions) },
+		func(d *ReportData, n int) { d.FirewallActions = d.FirewallActions[len(d.FirewallActions)-n:] }},
+	{"threat_events",
+		func(d *ReportData) int { return len(d.ThreatEvents) },
+		func(d *ReportData, n int) { d.ThreatEvents = d.ThreatEvents[len(d.ThreatEvents)-n:] }},
+	{"bruteforce_events",
+		func(d *ReportData) int { return len(d.BruteForceEvents) },
+		func(d *ReportData, n int) { d.BruteForceEvents = d.BruteForceEvents[len(d.BruteForceEvents)-n:] }},
+}
+
+// encodeReport marshals a report, truncating the lowest priority sections
+// until the JSON fits maxSize
+// data is left untouched, truncation works on a copy
+// A report that was truncated before, such as a spooled one sent again
+// under a lower cap, keeps its original size and section counts
+func encodeReport(data *ReportData, maxSize int) ([]byte, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal report data: %w", err)
+	}
+	if maxSize <= 0 || len(body) <= maxSize {
+		return body, nil
+	}
+
+	truncated := *data
+	truncated.Truncated = &TruncationReport{
+		OriginalSize: len(body),
+		MaxSize:      maxSize,
+		Sections:     make([]TruncatedSectionReport, 0),
+	}
+	if data.Truncated != nil {
+		truncated.Truncated.OriginalSize = data.Truncated.OriginalSize
+		truncated.Truncated.Sections = append(truncated.Truncated.Sections, data.Truncated.Sections...)
+	}
+
+	for _, section := range payloadSections {
+		original := section.count(&truncated)
+		if original == 0 {
+			continue
+		}
+
+		index := truncatedSectionIndex(truncated.Truncated.Sections, section.name)
+		if index < 0 {
+			index = len(truncated.Truncated.Sections)
+			truncated.Truncated.Sections = append(truncated.Truncated.Sections,
+				TruncatedSectionReport{Section: section.name, Original: original, Kept: original})
+		}
+
+		// Halve the section until the report fits or the section is gone
+		for kept := original; kept > 0 && len(body) > maxSize; {
+			kept /= 2
+			section.keep(&truncated, kept)
+			truncated.Truncated.Sections[index].Kept = kept
+
+			body, err = json.Marshal(&truncated)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal report data: %w", err)
+			}
+		}
+
+		if len(body) <= maxSize {
+			logger.Warn(fmt.Sprintf("Report of %d bytes truncated to %d bytes (cap %d), %d sections cut",
+				truncated.Truncated.OriginalSize, len(body), maxSize, len(truncated.Truncated.Sections)))
+			return body, nil
+		}
+	}
+
+	return nil, fmt.Errorf("report of %d bytes exceeds the %d byte cap even after truncation", len(body), maxSize)
+}
+
+// truncatedSectionIndex returns the index of a section in sections, -1 if
+// it was not truncated
+func truncatedSectionIndex(sections []TruncatedSectionReport, name string) int {
+	for i, section := range sections {
+		if section.Section == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// compressBody encodes a request body with a Content-Encoding
+func compressBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(body); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// negotiateEncoding picks the first encoding the server accepts that the
+// client supports, empty if there is none
+func negotiateEncoding(accepted []string) string {
+	for _, encoding := range supportedEncodings {
+		if containsEncoding(accepted, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// containsEncoding reports whether list holds encoding
+func containsEncoding(list []string, encoding string) bool {
+	for _, item := range list {
+		if item == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// boolCount returns 1 for true, the entry count of a single-value section
+func boolCount(present bool) int {
+	if present {
+		return 1
+	}
+	return 0
+}
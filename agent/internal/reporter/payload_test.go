@@ -0,0 +1,170 @@
+package reporter
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newLargeReport returns a report with 4 small traffic samples, 8 listeners
+// of about 10 KB each and 2 SSH logins
+func newLargeReport() *ReportData {
+	data := &ReportData{
+		Hostname:  "web-1",
+		SSHLogins: []SSHLoginReport{{User: "alice", IP: "203.0.113.9"}, {User: "bob", IP: "203.0.113.10"}},
+	}
+	for i := 0; i < 4; i++ {
+		data.NetworkTraffic.Samples = append(data.NetworkTraffic.Samples, TrafficSampleReport{InBytes: uint64(i)})
+	}
+	data.NetworkTraffic.SampleCount = len(data.NetworkTraffic.Samples)
+	for i := 0; i < 8; i++ {
+		data.Listeners = append(data.Listeners, ListenerReport{
+			Protocol: "tcp",
+			Port:     8000 + i,
+			Exe:      strings.Repeat("x", 10*1024),
+		})
+	}
+	return data
+}
+
+// decodeReport decodes an encoded report
+func decodeReport(t *testing.T, body []byte) *ReportData {
+	t.Helper()
+
+	var data ReportData
+	if err := json.Unmarshal(body, &data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	return &data
+}
+
+func TestEncodeReportTruncation(t *testing.T) {
+	full, err := json.Marshal(newLargeReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		maxSize   int
+		sections  []TruncatedSectionReport
+		listeners int
+	}{
+		{
+			name:      "within cap",
+			maxSize:   len(full),
+			listeners: 8,
+		},
+		{
+			name:    "samples then half the listeners",
+			maxSize: 50 * 1024,
+			sections: []TruncatedSectionReport{
+				{Section: "network_traffic.samples", Original: 4, Kept: 0},
+				{Section: "listeners", Original: 8, Kept: 4},
+			},
+			listeners: 4,
+		},
+		{
+			name:    "listeners cut twice",
+			maxSize: 25 * 1024,
+			sections: []TruncatedSectionReport{
+				{Section: "network_traffic.samples", Original: 4, Kept: 0},
+				{Section: "listeners", Original: 8, Kept: 2},
+			},
+			listeners: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := newLargeReport()
+			body, err := encodeReport(data, tt.maxSize)
+			if err != nil {
+				t.Fatalf("encodeReport() error = %v", err)
+			}
+			if len(body) > tt.maxSize {
+				t.Errorf("body of %d bytes over the %d byte cap", len(body), tt.maxSize)
+			}
+
+			if !reflect.DeepEqual(data, newLargeReport()) {
+				t.Error("encodeReport() changed the caller's report")
+			}
+
+			got := decodeReport(t, body)
+			if tt.sections == nil {
+				if got.Truncated != nil {
+					t.Errorf("Truncated = %+v, want nil", got.Truncated)
+				}
+			} else {
+				if got.Truncated == nil {
+					t.Fatal("Truncated = nil")
+				}
+				if got.Truncated.OriginalSize != len(full) || got.Truncated.MaxSize != tt.maxSize {
+					t.Errorf("Truncated sizes = %d/%d, want %d/%d",
+						got.Truncated.OriginalSize, got.Truncated.MaxSize, len(full), tt.maxSize)
+				}
+				if !reflect.DeepEqual(got.Truncated.Sections, tt.sections) {
+					t.Errorf("Sections = %+v, want %+v", got.Truncated.Sections, tt.sections)
+				}
+			}
+
+			if len(got.Listeners) != tt.listeners {
+				t.Errorf("listeners = %d, want %d", len(got.Listeners), tt.listeners)
+			}
+			if got.NetworkTraffic.SampleCount != len(got.NetworkTraffic.Samples) {
+				t.Errorf("SampleCount = %d, want %d", got.NetworkTraffic.SampleCount, len(got.NetworkTraffic.Samples))
+			}
+			if len(got.SSHLogins) != 2 {
+				t.Errorf("ssh logins = %d, want 2", len(got.SSHLogins))
+			}
+		})
+	}
+}
+
+func TestEncodeReportTruncatedAgain(t *testing.T) {
+	full, err := json.Marshal(newLargeReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A spooled report is stored truncated, decoded and cut again when
+	// the server rejects it as too large
+	first, err := encodeReport(newLargeReport(), 50*1024)
+	if err != nil {
+		t.Fatalf("encodeReport() error = %v", err)
+	}
+	second, err := encodeReport(decodeReport(t, first), 25*1024)
+	if err != nil {
+		t.Fatalf("encodeReport() error = %v", err)
+	}
+
+	got := decodeReport(t, second).Truncated
+	want := &TruncationReport{
+		OriginalSize: len(full),
+		MaxSize:      25 * 1024,
+		Sections: []TruncatedSectionReport{
+			{Section: "network_traffic.samples", Original: 4, Kept: 0},
+			{Section: "listeners", Original: 8, Kept: 2},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Truncated = %+v, want %+v", got, want)
+	}
+}
+
+func TestEncodeReportTooLarge(t *testing.T) {
+	data := newLargeReport()
+	data.Hostname = strings.Repeat("h", 4096)
+
+	_, err := encodeReport(data, 1024)
+	if err == nil {
+		t.Fatal("encodeReport() error = nil, want cap exceeded")
+	}
+	if want := "exceeds the 1024 byte cap"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+	if !reflect.DeepEqual(data.Listeners, newLargeReport().Listeners) {
+		t.Error("encodeReport() changed the caller's report")
+	}
+}
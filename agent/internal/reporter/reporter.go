@@ -1,11 +1,18 @@
 package reporter
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"zenoguard-agent/internal/collector"
+	"zenoguard-agent/internal/firewall"
 	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/proxy"
 )
 
 const (
@@ -17,6 +24,8 @@ const (
 	MaxRetryDelay = 60 * time.Second
 	// BackoffMultiplier is the multiplier for exponential backoff
 	BackoffMultiplier = 2
+	// FlushTimeout bounds the delivery of the spool on shutdown
+	FlushTimeout = 10 * time.Second
 )
 
 // Reporter handles data collection and reporting
@@ -24,6 +33,9 @@ type Reporter struct {
 	client         *Client
 	config         *Config
 	collectors     []collector.Collector
+	blocker        *firewall.Blocker // nil unless active blocking is enabled
+	spool          *spool            // nil if spooling is disabled
+	reportMu       sync.Mutex
 	reportInterval time.Duration
 	stopChan       chan struct{}
 	intervalUpdate chan time.Duration
@@ -33,26 +45,65 @@ type Reporter struct {
 type Config struct {
 	ServerURL      string
 	Token          string
-	ReportInterval int // seconds
+	ReportInterval int    // seconds
+	StateDir       string // directory for persisted collector state
+
+	BruteForce  collector.BruteForceConfig // SSH brute-force detection thresholds
+	Filesystems collector.FilesystemConfig // mounts reported by the filesystem collector
+	Disks       collector.DiskConfig       // block devices reported by the disk collector
+	Processes   collector.ProcessConfig    // top processes count and command line redaction
+	Connections collector.ConnectionConfig // TCP connection summary and threat-intel list
+	Integrity   collector.IntegrityConfig  // files watched by file integrity monitoring
+	Firewall    firewall.Config            // active blocking of brute-force sources
+	Spool       SpoolConfig                // undelivered reports kept under StateDir
+	Client      ClientConfig               // compression and payload cap of reports
 }
 
 // NewReporter creates a new reporter
 func NewReporter(config *Config) *Reporter {
 	// Create HTTP client
-	client := NewClient(config.ServerURL, config.Token)
+	client := NewClient(config.ServerURL, config.Token, config.Client)
 
 	// Initialize collectors
 	collectors := []collector.Collector{
-		collector.NewSSHCollector(),
+		collector.NewSSHCollector(config.StateDir, config.BruteForce),
+		collector.NewWtmpCollector(config.StateDir),
+		collector.NewPrivilegeCollector(config.StateDir),
 		collector.NewSystemCollector(),
+		collector.NewCPUCollector(),
+		collector.NewMemoryCollector(),
+		collector.NewFilesystemCollector(config.StateDir, config.Filesystems),
+		collector.NewDiskCollector(config.Disks),
+		collector.NewPressureCollector(),
+		collector.NewProcessCollector(config.Processes),
+		collector.NewListenerCollector(config.StateDir),
+		collector.NewConnectionCollector(config.Connections),
+		collector.NewIntegrityCollector(config.StateDir, config.Integrity),
+		collector.NewAuthorizedKeysCollector(config.StateDir),
+		collector.NewAccountCollector(config.StateDir),
 		collector.NewNetworkCollector(),
-		collector.NewHostInfoCollector(),
+		collector.NewHostInfoCollector(config.Client.Proxy),
+	}
+
+	// Active blocking is optional, a broken setup is logged and reported
+	// through failed ban actions rather than stopping the agent
+	var blocker *firewall.Blocker
+	if config.Firewall.Enabled() {
+		var err error
+		blocker, err = firewall.NewBlocker(config.Firewall, config.StateDir, nil)
+		if err != nil {
+			logger.Error("Active blocking disabled: " + err.Error())
+		} else if err := blocker.Init(); err != nil {
+			logger.Error("Firewall setup failed: " + err.Error())
+		}
 	}
 
 	return &Reporter{
 		client:         client,
 		config:         config,
 		collectors:     collectors,
+		blocker:        blocker,
+		spool:          newSpool(spoolDir(config.StateDir), config.Spool),
 		stopChan:       make(chan struct{}),
 		intervalUpdate: make(chan time.Duration, 1),
 	}
@@ -113,6 +164,14 @@ func (r *Reporter) Stop() {
 	close(r.stopChan)
 }
 
+// spoolDir returns the spool directory inside stateDir
+func spoolDir(stateDir string) string {
+	if stateDir == "" {
+		return ""
+	}
+	return filepath.Join(stateDir, "spool")
+}
+
 // collectNetworkSample collects a network traffic sample
 func (r *Reporter) collectNetworkSample() {
 	for _, col := range r.collectors {
@@ -125,37 +184,68 @@ func (r *Reporter) collectNetworkSample() {
 }
 
 // report performs a single report with retry logic
+// Reports spooled during an outage are sent first; a report that still
+// cannot be delivered after the retries is spooled in turn
 func (r *Reporter) report() error {
+	r.reportMu.Lock()
+	defer r.reportMu.Unlock()
+
+	// After Stop the final report is left to Flush
+	select {
+	case <-r.stopChan:
+		return nil
+	default:
+	}
+
+	// Collect data
+	data, body, err := r.collectReport()
+	if err != nil {
+		return err
+	}
+	key := newReportKey()
+
+	if err := r.replaySpool(time.Time{}); err != nil {
+		logger.Warn("Spooled reports not delivered: " + err.Error())
+		return r.spoolReport(body, key, err)
+	}
+
 	var lastErr error
 	delay := InitialRetryDelay
 
 	for attempt := 0; attempt < MaxRetries; attempt++ {
 		if attempt > 0 {
 			logger.Info(fmt.Sprintf("Retry attempt %d/%d after %v", attempt+1, MaxRetries, delay))
-			time.Sleep(delay)
-		}
-
-		// Collect data
-		data, err := r.collectData()
-		if err != nil {
-			lastErr = err
-			logger.Error("Failed to collect data: " + err.Error())
-			continue
+			select {
+			case <-time.After(delay):
+			case <-r.stopChan:
+				return r.spoolReport(body, key, lastErr)
+			}
 		}
 
-		// Send report
-		response, err := r.client.Report(data)
+		// Send report, the key stays the same across retries
+		response, err := r.sendReport(data, body, key)
 		if err != nil {
 			lastErr = err
-
-			// Check if token is invalid
-			if fmt.Sprintf("%v", err) == "unauthorized: invalid token" {
-				logger.Error("Invalid token - will retry for 1 minute then exit")
-				time.Sleep(1 * time.Minute)
-				logger.Fatal("Invalid token, exiting")
+			r.checkToken(err)
+
+			// A proxy failure says nothing about the server, and retrying
+			// with credentials the proxy rejected cannot succeed
+			var proxyErr *proxy.Error
+			if errors.As(err, &proxyErr) {
+				logger.Error("Proxy failure: " + err.Error())
+				if proxyErr.AuthFailed() {
+					return r.spoolReport(body, key, err)
+				}
+			} else {
+				logger.Error("Failed to send report: " + err.Error())
+			}
+			// Retrying a rejected report cannot succeed, like a spooled one
+			// it is dropped so the collectors move on
+			if isPermanent(err) {
+				logger.Error("Dropping report " + key + " rejected by the server")
+				r.delivered()
+				return nil
 			}
-
-			logger.Error("Failed to send report: " + err.Error())
 
 			// Exponential backoff
 			delay = time.Duration(float64(delay) * BackoffMultiplier)
@@ -165,37 +255,190 @@ func (r *Reporter) report() error {
 			continue
 		}
 
-		// Update report interval if server returned a new value
-		if response != nil && response.ReportInterval > 0 {
-			newInterval := response.ReportInterval
-			if newInterval != r.config.ReportInterval {
-				logger.Info(fmt.Sprintf("Server requested new interval: %d -> %d seconds",
-					r.config.ReportInterval, newInterval))
-				r.config.ReportInterval = newInterval
-				// Send update to ticker (non-blocking)
-				select {
-				case r.intervalUpdate <- time.Duration(newInterval) * time.Second:
-					logger.Info("Interval update signal sent")
-				default:
-					logger.Warn("Interval update channel full, skipping")
-				}
-			}
+		r.applyResponse(response)
+		r.delivered()
+
+		// Success
+		return nil
+	}
+
+	return r.spoolReport(body, key, fmt.Errorf("max retries exceeded: %w", lastErr))
+}
+
+// Flush writes the data collected since the last report to the spool and
+// makes a last attempt to deliver the spool, called on shutdown after Stop
+func (r *Reporter) Flush() {
+	r.reportMu.Lock()
+	defer r.reportMu.Unlock()
+
+	logger.Info("Flushing reports before shutdown")
+
+	data, body, err := r.collectReport()
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	key := newReportKey()
+
+	if r.spool == nil {
+		if _, err := r.sendReport(data, body, key); err != nil {
+			logger.Error("Failed to send final report: " + err.Error())
+			return
 		}
+		r.delivered()
+		return
+	}
+
+	if err := r.spool.add(key, body); err != nil {
+		logger.Error("Failed to spool final report: " + err.Error())
+		return
+	}
+	r.delivered()
+
+	if err := r.replaySpool(time.Now().Add(FlushTimeout)); err != nil {
+		logger.Warn(fmt.Sprintf("%d reports left in the spool: %v", r.spool.pending(), err))
+	}
+}
+
+// collectReport collects data from all collectors and encodes the report
+func (r *Reporter) collectReport() (*ReportData, []byte, error) {
+	data, err := r.collectData()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to collect data: %w", err)
+	}
+	logger.Info(fmt.Sprintf("Report contains %d SSH log entries", len(data.SSHLogins)))
+
+	body, err := r.client.Encode(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	return data, body, nil
+}
+
+// sendReport sends an encoded report
+// While the server rejects it as too large, the payload cap is lowered and
+// the report is truncated again; data is decoded from body when nil
+func (r *Reporter) sendReport(data *ReportData, body []byte, key string) (*ReportResponse, error) {
+	for {
+		response, err := r.client.Send(body, key)
 
-		// Clear network samples after successful report
-		for _, col := range r.collectors {
-			if nc, ok := col.(*collector.NetworkCollector); ok {
-				nc.ClearSamples()
-				logger.Info("Cleared network traffic samples after successful report")
-				break
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusRequestEntityTooLarge ||
+			!r.client.lowerPayloadCap(len(body)) {
+			return response, err
+		}
+
+		if data == nil {
+			data = &ReportData{}
+			if decodeErr := json.Unmarshal(body, data); decodeErr != nil {
+				return nil, err
 			}
 		}
+		smaller, encodeErr := r.client.Encode(data)
+		if encodeErr != nil {
+			return nil, err
+		}
+		logger.Warn(fmt.Sprintf("Server rejected a report of %d bytes as too large, sending it cut to %d bytes",
+			len(body), len(smaller)))
+		body = smaller
+	}
+}
 
-		// Success
+// replaySpool sends the spooled reports in order, until deadline if set
+func (r *Reporter) replaySpool(deadline time.Time) error {
+	if r.spool == nil {
 		return nil
 	}
 
-	return fmt.Errorf("max retries exceeded: %w", lastErr)
+	sent, err := r.spool.replay(func(report *spooledReport) error {
+		response, err := r.sendReport(nil, report.Data, report.Key)
+		if err != nil {
+			r.checkToken(err)
+			return err
+		}
+		r.applyResponse(response)
+		return nil
+	}, deadline)
+
+	if sent > 0 {
+		logger.Info(fmt.Sprintf("Delivered %d spooled reports", sent))
+	}
+	return err
+}
+
+// spoolReport keeps a report that could not be sent for a later replay
+// Once on disk the data counts as delivered, so the collectors move on
+func (r *Reporter) spoolReport(body []byte, key string, sendErr error) error {
+	if r.spool == nil {
+		return sendErr
+	}
+
+	if err := r.spool.add(key, body); err != nil {
+		logger.Error("Failed to spool report: " + err.Error())
+		return sendErr
+	}
+	r.delivered()
+
+	logger.Warn(fmt.Sprintf("Report %s spooled for later delivery, %d reports pending: %v",
+		key, r.spool.pending(), sendErr))
+	return nil
+}
+
+// checkToken exits when the server rejected the token
+func (r *Reporter) checkToken(err error) {
+	if fmt.Sprintf("%v", err) == "unauthorized: invalid token" {
+		logger.Error("Invalid token - will retry for 1 minute then exit")
+		time.Sleep(1 * time.Minute)
+		logger.Fatal("Invalid token, exiting")
+	}
+}
+
+// applyResponse updates the report interval if the server returned a new value
+func (r *Reporter) applyResponse(response *ReportResponse) {
+	if response == nil || response.ReportInterval <= 0 {
+		return
+	}
+
+	newInterval := response.ReportInterval
+	if newInterval != r.config.ReportInterval {
+		logger.Info(fmt.Sprintf("Server requested new interval: %d -> %d seconds",
+			r.config.ReportInterval, newInterval))
+		r.config.ReportInterval = newInterval
+		// Send update to ticker (non-blocking)
+		select {
+		case r.intervalUpdate <- time.Duration(newInterval) * time.Second:
+			logger.Info("Interval update signal sent")
+		default:
+			logger.Warn("Interval update channel full, skipping")
+		}
+	}
+}
+
+// delivered resets the collectors once their data has been sent or spooled
+func (r *Reporter) delivered() {
+	// Clear network samples after successful report
+	for _, col := range r.collectors {
+		if nc, ok := col.(*collector.NetworkCollector); ok {
+			nc.ClearSamples()
+			logger.Info("Cleared network traffic samples after successful report")
+			break
+		}
+	}
+
+	// Persist read positions now that the data has been delivered
+	for _, col := range r.collectors {
+		if cc, ok := col.(collector.Committer); ok {
+			if err := cc.Commit(); err != nil {
+				logger.Warn("Failed to save " + col.Name() + " collector state: " + err.Error())
+			}
+		}
+	}
+
+	if r.blocker != nil {
+		if err := r.blocker.Commit(); err != nil {
+			logger.Warn("Failed to save firewall state: " + err.Error())
+		}
+	}
 }
 
 // collectData collects data from all collectors
@@ -220,9 +463,14 @@ func (r *Reporter) collectData() (*ReportData, error) {
 
 		// Type switch to assign to appropriate field
 		switch v := result.(type) {
-		case []collector.SSHLogin:
-			logger.Info("SSH collector returned " + fmt.Sprint(len(v)) + " logins")
-			data.SSHLogins = convertSSHLogins(v)
+		case *collector.SSHData:
+			logger.Info("SSH collector returned " + fmt.Sprint(len(v.Logins)) + " logins")
+			data.SSHLogins = convertSSHLogins(v.Logins)
+			data.SSHSessions = convertSSHSessions(v.Sessions)
+			data.BruteForceEvents = convertBruteForceEvents(v.BruteForce)
+			if r.blocker != nil {
+				banOffenders(r.blocker, v.BruteForce)
+			}
 			logger.Info("Converted to " + fmt.Sprint(len(data.SSHLogins)) + " report entries")
 		case collector.SystemLoad:
 			data.SystemLoad = SystemLoadReport{
@@ -236,6 +484,30 @@ func (r *Reporter) collectData() (*ReportData, error) {
 				Load5:  v.Load5,
 				Load15: v.Load15,
 			}
+		case *collector.CPUStats:
+			data.CPU = convertCPUStats(v)
+		case *collector.MemoryStats:
+			report := MemoryReport(*v)
+			data.Memory = &report
+		case []collector.FilesystemUsage:
+			data.Filesystems = convertFilesystems(v)
+		case *collector.DiskStats:
+			data.DiskIO = convertDiskStats(v)
+		case *collector.PressureStats:
+			data.Pressure = convertPressure(v)
+		case *collector.ProcessSnapshot:
+			data.Processes = convertProcessSnapshot(v)
+		case *collector.ListenerData:
+			data.Listeners, data.ListenerEvents = convertListeners(v)
+		case *collector.ConnectionSummary:
+			data.Connections = convertConnectionSummary(v)
+			data.ThreatEvents = convertThreatMatches(v.Threats)
+		case *collector.IntegrityData:
+			data.FileEvents = convertFileEvents(v.Events)
+		case *collector.AuthorizedKeysData:
+			data.AuthorizedKeys, data.KeyEvents = convertAuthorizedKeys(v)
+		case *collector.AccountData:
+			data.AccountEvents = convertAccountEvents(v.Events)
 		case *collector.NetworkTraffic:
 			// Convert samples to report format
 			samples := make([]TrafficSampleReport, len(v.Samples))
@@ -256,6 +528,10 @@ func (r *Reporter) collectData() (*ReportData, error) {
 				TotalOutBytes: v.TotalOutBytes,
 				SampleCount:   v.SampleCount,
 			}
+		case *collector.LoginAccounting:
+			data.LoginAccounting = convertLoginAccounting(v)
+		case []collector.PrivilegeEvent:
+			data.PrivilegeEvents = convertPrivilegeEvents(v)
 		case collector.HostInfo:
 			data.Hostname = v.Hostname
 			data.PublicIP = v.PublicIP
@@ -267,6 +543,12 @@ func (r *Reporter) collectData() (*ReportData, error) {
 		}
 	}
 
+	// Lift expired bans and report every firewall action not yet delivered
+	if r.blocker != nil {
+		r.blocker.Expire(time.Now())
+		data.FirewallActions = convertFirewallActions(r.blocker.Pending())
+	}
+
 	logger.Info("Data collection completed")
 	return data, nil
 }
@@ -285,6 +567,397 @@ func convertSSHLogins(logins []collector.SSHLogin) []SSHLoginReport {
 			Protocol:        login.Protocol,
 			SessionDuration: login.SessionDuration,
 			IsActive:        login.IsActive,
+			PID:             login.PID,
+			KeyType:         login.KeyType,
+			KeyFingerprint:  login.KeyFingerprint,
+		}
+	}
+	return report
+}
+
+// convertSSHSessions converts SSH sessions from collector format to report format
+func convertSSHSessions(sessions []collector.SSHSession) []SSHSessionReport {
+	report := make([]SSHSessionReport, len(sessions))
+	for i, session := range sessions {
+		report[i] = SSHSessionReport{
+			PID:              session.PID,
+			User:             session.User,
+			IP:               session.IP,
+			Port:             session.Port,
+			Method:           session.Method,
+			Start:            session.Start.Format(time.RFC3339),
+			Duration:         session.Duration,
+			DisconnectReason: session.DisconnectReason,
+			IsActive:         session.IsActive,
+		}
+		if !session.End.IsZero() {
+			report[i].End = session.End.Format(time.RFC3339)
+		}
+	}
+	return report
+}
+
+// convertBruteForceEvents converts brute-force detections to report format
+func convertBruteForceEvents(events []collector.BruteForceEvent) []BruteForceEventReport {
+	report := make([]BruteForceEventReport, len(events))
+	for i, event := range events {
+		report[i] = BruteForceEventReport{
+			Event:         event.Event,
+			Kind:          event.Kind,
+			Reason:        event.Reason,
+			IP:            event.IP,
+			User:          event.User,
+			Failures:      event.Failures,
+			DistinctUsers: event.DistinctUsers,
+			DistinctIPs:   event.DistinctIPs,
+			FirstSeen:     event.FirstSeen.Format(time.RFC3339),
+			LastSeen:      event.LastSeen.Format(time.RFC3339),
+			Usernames:     event.Usernames,
+			IPs:           event.IPs,
+			Window:        event.Window,
+		}
+	}
+	return report
+}
+
+// banOffenders bans the source addresses of brute-force detections
+// User detections are spread over many addresses and are not acted upon
+func banOffenders(blocker *firewall.Blocker, events []collector.BruteForceEvent) {
+	for _, event := range events {
+		if event.Kind != "ip" || event.IP == "" {
+			continue
+		}
+		reason := fmt.Sprintf("ssh brute force: %d failures, %d usernames", event.Failures, event.DistinctUsers)
+		blocker.Ban(event.IP, reason, event.LastSeen)
+	}
+}
+
+// convertFirewallActions converts ban/unban actions to report format
+func convertFirewallActions(actions []firewall.Action) []FirewallActionReport {
+	report := make([]FirewallActionReport, len(actions))
+	for i, action := range actions {
+		report[i] = FirewallActionReport{
+			Time:    action.Time.Format(time.RFC3339),
+			Action:  action.Action,
+			IP:      action.IP,
+			Backend: action.Backend,
+			Reason:  action.Reason,
+			Error:   action.Error,
+		}
+		if !action.ExpiresAt.IsZero() {
+			report[i].ExpiresAt = action.ExpiresAt.Format(time.RFC3339)
+		}
+	}
+	return report
+}
+
+// convertCPUStats converts CPU utilization to report format
+func convertCPUStats(stats *collector.CPUStats) *CPUReport {
+	report := &CPUReport{
+		Interval:        stats.Interval,
+		Total:           CPUUsageReport(stats.Total),
+		Cores:           make([]CPUUsageReport, len(stats.Cores)),
+		ContextSwitches: stats.ContextSwitches,
+		ContextRate:     stats.ContextRate,
+		Forks:           stats.Forks,
+		ForkRate:        stats.ForkRate,
+		ProcsRunning:    stats.ProcsRunning,
+		ProcsBlocked:    stats.ProcsBlocked,
+	}
+	for i, core := range stats.Cores {
+		report.Cores[i] = CPUUsageReport(core)
+	}
+	return report
+}
+
+// convertFilesystems converts filesystem usage to report format
+func convertFilesystems(usages []collector.FilesystemUsage) []FilesystemReport {
+	report := make([]FilesystemReport, len(usages))
+	for i, usage := range usages {
+		report[i] = FilesystemReport(usage)
+	}
+	return report
+}
+
+// convertDiskStats converts block device I/O statistics to report format
+func convertDiskStats(stats *collector.DiskStats) *DiskIOReport {
+	report := &DiskIOReport{
+		Interval: stats.Interval,
+		Devices:  make([]DiskDeviceReport, len(stats.Devices)),
+	}
+	for i, device := range stats.Devices {
+		report.Devices[i] = DiskDeviceReport(device)
+	}
+	return report
+}
+
+// convertPressure converts pressure stall information to report format
+func convertPressure(stats *collector.PressureStats) *PressureReport {
+	return &PressureReport{
+		Interval: stats.Interval,
+		CPU:      convertPressureResource(stats.CPU),
+		Memory:   convertPressureResource(stats.Memory),
+		IO:       convertPressureResource(stats.IO),
+	}
+}
+
+// convertPressureResource converts the pressure of one resource, nil stays nil
+func convertPressureResource(resource *collector.PressureResource) *PressureResourceReport {
+	if resource == nil {
+		return nil
+	}
+
+	report := &PressureResourceReport{Some: PressureLineReport(resource.Some)}
+	if resource.Full != nil {
+		full := PressureLineReport(*resource.Full)
+		report.Full = &full
+	}
+	return report
+}
+
+// convertProcessSnapshot converts the top processes to report format
+func convertProcessSnapshot(snapshot *collector.ProcessSnapshot) *ProcessesReport {
+	return &ProcessesReport{
+		Interval:  snapshot.Interval,
+		Total:     snapshot.Total,
+		TopCPU:    convertProcesses(snapshot.TopCPU),
+		TopMemory: convertProcesses(snapshot.TopMemory),
+		TopFDs:    convertProcesses(snapshot.TopFDs),
+	}
+}
+
+// convertProcesses converts processes to report format
+func convertProcesses(processes []collector.ProcessInfo) []ProcessReport {
+	result := make([]ProcessReport, len(processes))
+	for i, process := range processes {
+		result[i] = ProcessReport{
+			PID:        process.PID,
+			PPID:       process.PPID,
+			Name:       process.Name,
+			User:       process.User,
+			UID:        process.UID,
+			State:      process.State,
+			Cmdline:    process.Cmdline,
+			CPUPercent: process.CPUPercent,
+			RSS:        process.RSS,
+			FDs:        process.FDs,
+			Threads:    process.Threads,
+			StartTime:  process.StartTime.Format(time.RFC3339),
+		}
+	}
+	return result
+}
+
+// convertListeners converts listening ports and their changes to report format
+func convertListeners(listenerData *collector.ListenerData) ([]ListenerReport, []ListenerEventReport) {
+	listeners := make([]ListenerReport, len(listenerData.Listeners))
+	for i, listener := range listenerData.Listeners {
+		listeners[i] = ListenerReport(listener)
+	}
+
+	events := make([]ListenerEventReport, len(listenerData.Events))
+	for i, event := range listenerData.Events {
+		events[i] = ListenerEventReport{
+			Time:     event.Time.Format(time.RFC3339),
+			Event:    event.Event,
+			Listener: ListenerReport(event.Listener),
+		}
+	}
+
+	return listeners, events
+}
+
+// convertConnectionSummary converts the TCP connection summary to report format
+func convertConnectionSummary(summary *collector.ConnectionSummary) *ConnectionsReport {
+	report := &ConnectionsReport{
+		Total:       summary.Total,
+		States:      summary.States,
+		Established: summary.Established,
+		Outbound:    summary.Outbound,
+		Inbound:     summary.Inbound,
+		TopRemotes:  make([]RemoteCountReport, len(summary.TopRemotes)),
+		TopPorts:    make([]PortCountReport, len(summary.TopPorts)),
+		Processes:   make([]ConnectionProcessReport, len(summary.Processes)),
+	}
+	for i, remote := range summary.TopRemotes {
+		report.TopRemotes[i] = RemoteCountReport(remote)
+	}
+	for i, port := range summary.TopPorts {
+		report.TopPorts[i] = PortCountReport(port)
+	}
+	for i, process := range summary.Processes {
+		report.Processes[i] = ConnectionProcessReport(process)
+	}
+	return report
+}
+
+// convertThreatMatches converts threat-intel matches to report format
+func convertThreatMatches(threats []collector.ThreatMatch) []ThreatEventReport {
+	result := make([]ThreatEventReport, len(threats))
+	for i, threat := range threats {
+		result[i] = ThreatEventReport{
+			Time:       threat.Time.Format(time.RFC3339),
+			Event:      threat.Event,
+			Severity:   threat.Severity,
+			Direction:  threat.Direction,
+			State:      threat.State,
+			LocalIP:    threat.LocalIP,
+			LocalPort:  threat.LocalPort,
+			RemoteIP:   threat.RemoteIP,
+			RemotePort: threat.RemotePort,
+			Match:      threat.Match,
+			Label:      threat.Label,
+			PID:        threat.PID,
+			Process:    threat.Process,
+			Exe:        threat.Exe,
+		}
+	}
+	return result
+}
+
+// convertFileEvents converts file integrity events to report format
+func convertFileEvents(events []collector.FileEvent) []FileEventReport {
+	result := make([]FileEventReport, len(events))
+	for i, event := range events {
+		result[i] = FileEventReport{
+			Time:    event.Time.Format(time.RFC3339),
+			Event:   event.Event,
+			Path:    event.Path,
+			Source:  event.Source,
+			Changes: event.Changes,
+			Before:  convertFileMeta(event.Before),
+			After:   convertFileMeta(event.After),
+		}
+	}
+	return result
+}
+
+// convertFileMeta converts file metadata to report format, nil stays nil
+func convertFileMeta(meta *collector.FileMeta) *FileMetaReport {
+	if meta == nil {
+		return nil
+	}
+	return &FileMetaReport{
+		Type:    meta.Type,
+		Size:    meta.Size,
+		Mode:    meta.Mode,
+		UID:     meta.UID,
+		GID:     meta.GID,
+		Owner:   meta.Owner,
+		ModTime: meta.ModTime.Format(time.RFC3339),
+		SHA256:  meta.SHA256,
+		Target:  meta.Target,
+	}
+}
+
+// convertAuthorizedKeys converts authorized SSH keys and their changes to report format
+func convertAuthorizedKeys(keysData *collector.AuthorizedKeysData) ([]AuthorizedKeyReport, []KeyEventReport) {
+	keys := make([]AuthorizedKeyReport, len(keysData.Keys))
+	for i, key := range keysData.Keys {
+		keys[i] = AuthorizedKeyReport(key)
+	}
+
+	events := make([]KeyEventReport, len(keysData.Events))
+	for i, event := range keysData.Events {
+		events[i] = KeyEventReport{
+			Time:  event.Time.Format(time.RFC3339),
+			Event: event.Event,
+			Key:   AuthorizedKeyReport(event.Key),
+		}
+	}
+
+	return keys, events
+}
+
+// convertAccountEvents converts local account changes to report format
+func convertAccountEvents(accountEvents []collector.AccountEvent) []AccountEventReport {
+	events := make([]AccountEventReport, len(accountEvents))
+	for i, event := range accountEvents {
+		events[i] = AccountEventReport{
+			Time:       event.Time.Format(time.RFC3339),
+			Event:      event.Event,
+			Severity:   event.Severity,
+			User:       event.User,
+			Group:      event.Group,
+			Before:     event.Before,
+			After:      event.After,
+			LastChange: event.LastChange,
+		}
+	}
+	return events
+}
+
+// convertLoginAccounting converts login accounting records to report format
+func convertLoginAccounting(accounting *collector.LoginAccounting) *LoginAccountingReport {
+	report := &LoginAccountingReport{
+		Logins:     convertLoginRecords(accounting.Logins),
+		Failed:     convertLoginRecords(accounting.Failed),
+		System:     make([]SystemRecordReport, len(accounting.System)),
+		LastLogins: make([]LastLoginReport, len(accounting.LastLogins)),
+	}
+
+	for i, record := range accounting.System {
+		report.System[i] = SystemRecordReport{
+			Event:  record.Event,
+			Time:   record.Time.Format(time.RFC3339),
+			Kernel: record.Kernel,
+		}
+	}
+
+	for i, entry := range accounting.LastLogins {
+		report.LastLogins[i] = LastLoginReport{
+			User: entry.User,
+			UID:  entry.UID,
+			Line: entry.Line,
+			Host: entry.Host,
+			Time: entry.Time.Format(time.RFC3339),
+		}
+	}
+
+	return report
+}
+
+// convertLoginRecords converts wtmp/btmp records to report format
+func convertLoginRecords(records []collector.LoginRecord) []LoginRecordReport {
+	report := make([]LoginRecordReport, len(records))
+	for i, record := range records {
+		report[i] = LoginRecordReport{
+			Event:    record.Event,
+			User:     record.User,
+			Line:     record.Line,
+			Host:     record.Host,
+			IP:       record.IP,
+			PID:      record.PID,
+			Duration: record.Duration,
+			Reason:   record.Reason,
+		}
+		if !record.LoginTime.IsZero() {
+			report[i].LoginTime = record.LoginTime.Format(time.RFC3339)
+		}
+		if !record.LogoutTime.IsZero() {
+			report[i].LogoutTime = record.LogoutTime.Format(time.RFC3339)
+		}
+	}
+	return report
+}
+
+// convertPrivilegeEvents converts sudo/su events to report format
+func convertPrivilegeEvents(events []collector.PrivilegeEvent) []PrivilegeEventReport {
+	report := make([]PrivilegeEventReport, len(events))
+	for i, event := range events {
+		report[i] = PrivilegeEventReport{
+			Time:       event.Time.Format(time.RFC3339),
+			Tool:       event.Tool,
+			Event:      event.Event,
+			User:       event.User,
+			TargetUser: event.TargetUser,
+			TTY:        event.TTY,
+			PWD:        event.PWD,
+			Command:    event.Command,
+			Attempts:   event.Attempts,
+			Reason:     event.Reason,
+			Success:    event.Success,
+			PID:        event.PID,
 		}
 	}
 	return report
@@ -0,0 +1,284 @@
+package reporter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+)
+
+const (
+	// DefaultSpoolMaxSize is the default disk space used by spooled reports
+	DefaultSpoolMaxSize = 64 * 1024 * 1024
+	// DefaultSpoolMaxAge is the default age after which spooled reports are dropped
+	DefaultSpoolMaxAge = 7 * 24 * time.Hour
+
+	spoolPerm    = 0600
+	spoolDirPerm = 0700
+	spoolSuffix  = ".json"
+)
+
+// SpoolConfig bounds the reports kept on disk while the server is unreachable
+type SpoolConfig struct {
+	MaxSize int64         // bytes, 0 uses the default and a negative value disables the spool
+	MaxAge  time.Duration // 0 uses the default
+}
+
+// spooledReport is a report that could not be delivered
+type spooledReport struct {
+	Key     string          `json:"key"` // idempotency key, unchanged on replay
+	Created time.Time       `json:"created"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// spoolEntry is a spooled report file
+type spoolEntry struct {
+	path    string
+	created time.Time
+	size    int64
+}
+
+// spool stores undelivered reports under the state directory, one file per
+// report named after its creation time so they replay in order
+type spool struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+	last    int64 // creation time of the newest entry, keeps names unique
+	mu      sync.Mutex
+}
+
+// newSpool creates a spool in dir, nil if spooling is disabled
+func newSpool(dir string, config SpoolConfig) *spool {
+	if dir == "" || config.MaxSize < 0 {
+		return nil
+	}
+	if config.MaxSize == 0 {
+		config.MaxSize = DefaultSpoolMaxSize
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultSpoolMaxAge
+	}
+
+	return &spool{dir: dir, maxSize: config.MaxSize, maxAge: config.MaxAge}
+}
+
+// add writes a report to the spool and drops the oldest reports beyond
+// the size and age limits
+func (s *spool) add(key string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	created := now.UnixNano()
+	if created <= s.last {
+		created = s.last + 1
+	}
+	s.last = created
+
+	record, err := json.Marshal(spooledReport{Key: key, Created: now, Data: body})
+	if err != nil {
+		return fmt.Errorf("failed to marshal spooled report: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, spoolDirPerm); err != nil {
+		return fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	// Same write-and-rename as the state files, so a crash never leaves
+	// a truncated report to replay
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d-%s%s", created, key, spoolSuffix))
+	tmpPath := path + ".tmp"
+	if err := writeFileSync(tmpPath, record); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write spooled report %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename spooled report %s: %w", path, err)
+	}
+
+	s.prune(now)
+	return nil
+}
+
+// replay sends the spooled reports oldest first and removes the delivered
+// ones; it stops at the first report that fails, so the order is kept
+// Reports the server rejects for good are dropped rather than blocking
+// the reports behind them
+func (s *spool) replay(send func(report *spooledReport) error, deadline time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+
+	sent := 0
+	for _, entry := range s.entries() {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return sent, fmt.Errorf("spool replay deadline reached")
+		}
+
+		report, err := readSpooledReport(entry.path)
+		if err != nil {
+			logger.Warn("Dropping unreadable spooled report " + entry.path + ": " + err.Error())
+			os.Remove(entry.path)
+			continue
+		}
+
+		if err := send(report); err != nil {
+			if !isPermanent(err) {
+				return sent, err
+			}
+			logger.Warn("Dropping spooled report " + report.Key + " rejected by the server: " + err.Error())
+		} else {
+			sent++
+		}
+
+		if err := os.Remove(entry.path); err != nil {
+			return sent, fmt.Errorf("failed to remove spooled report %s: %w", entry.path, err)
+		}
+	}
+
+	return sent, nil
+}
+
+// pending returns the number of spooled reports
+func (s *spool) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries())
+}
+
+// prune drops reports older than the age limit, then the oldest reports
+// until the spool fits the size limit
+// Must be called with s.mu held
+func (s *spool) prune(now time.Time) {
+	entries := s.entries()
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+
+	expired, evicted := 0, 0
+	for _, entry := range entries {
+		switch {
+		case now.Sub(entry.created) > s.maxAge:
+			expired++
+		case total > s.maxSize:
+			evicted++
+		default:
+			continue
+		}
+		if err := os.Remove(entry.path); err != nil {
+			logger.Warn("Failed to remove spooled report " + entry.path + ": " + err.Error())
+			continue
+		}
+		total -= entry.size
+	}
+
+	if expired > 0 || evicted > 0 {
+		logger.Warn(fmt.Sprintf("Dropped %d spooled reports older than %v and %d over the %d byte limit",
+			expired, s.maxAge, evicted, s.maxSize))
+	}
+}
+
+// entries lists the spooled report files, oldest first
+// Must be called with s.mu held
+func (s *spool) entries() []spoolEntry {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("Failed to read spool directory: " + err.Error())
+		}
+		return nil
+	}
+
+	entries := make([]spoolEntry, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, spoolSuffix) {
+			continue
+		}
+		stamp, _, ok := strings.Cut(name, "-")
+		if !ok {
+			continue
+		}
+		nanos, err := strconv.ParseInt(stamp, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, spoolEntry{
+			path:    filepath.Join(s.dir, name),
+			created: time.Unix(0, nanos),
+			size:    info.Size(),
+		})
+		if nanos > s.last {
+			s.last = nanos
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].created.Before(entries[j].created)
+	})
+	return entries
+}
+
+// readSpooledReport reads a spooled report file
+func readSpooledReport(path string) (*spooledReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report spooledReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	if report.Key == "" || len(report.Data) == 0 {
+		return nil, fmt.Errorf("incomplete spooled report")
+	}
+	return &report, nil
+}
+
+// writeFileSync writes a file and flushes it to disk
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, spoolPerm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// newReportKey returns a random idempotency key for a report
+func newReportKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Not unique across restarts, but still unique within this run
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
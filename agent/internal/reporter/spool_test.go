@@ -0,0 +1,243 @@
+package reporter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// spoolFile is a file placed in the spool directory before a test
+type spoolFile struct {
+	name    string
+	content string
+}
+
+// spooledFile returns a valid spooled report file created at created
+func spooledFile(created time.Time, key string) spoolFile {
+	return spoolFile{
+		name:    fmt.Sprintf("%020d-%s%s", created.UnixNano(), key, spoolSuffix),
+		content: fmt.Sprintf(`{"key":%q,"created":%q,"data":{"key":%q}}`, key, created.Format(time.RFC3339Nano), key),
+	}
+}
+
+// spoolKeys returns the keys of the spooled report files left in dir
+func spoolKeys(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	keys := make([]string, 0)
+	for _, file := range files {
+		name := file.Name()
+		if _, rest, ok := strings.Cut(name, "-"); ok && strings.HasSuffix(name, spoolSuffix) {
+			keys = append(keys, strings.TrimSuffix(rest, spoolSuffix))
+		} else {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestSpoolReplay(t *testing.T) {
+	now := time.Now()
+	temporary := errors.New("connection refused")
+	rejected := &StatusError{StatusCode: http.StatusBadRequest, Body: "invalid report"}
+
+	tests := []struct {
+		name    string
+		files   []spoolFile
+		fail    map[string]error
+		sent    []string // keys passed to send, in order
+		count   int
+		wantErr bool
+		left    []string
+	}{
+		{
+			name: "oldest first",
+			files: []spoolFile{
+				spooledFile(now.Add(-time.Minute), "b"),
+				spooledFile(now.Add(-time.Hour), "a"),
+				spooledFile(now.Add(-time.Second), "c"),
+			},
+			sent:  []string{"a", "b", "c"},
+			count: 3,
+			left:  []string{},
+		},
+		{
+			name: "stop at temporary failure",
+			files: []spoolFile{
+				spooledFile(now.Add(-3*time.Minute), "a"),
+				spooledFile(now.Add(-2*time.Minute), "b"),
+				spooledFile(now.Add(-time.Minute), "c"),
+			},
+			fail:    map[string]error{"b": temporary},
+			sent:    []string{"a", "b"},
+			count:   1,
+			wantErr: true,
+			left:    []string{"b", "c"},
+		},
+		{
+			name: "drop permanent rejection",
+			files: []spoolFile{
+				spooledFile(now.Add(-3*time.Minute), "a"),
+				spooledFile(now.Add(-2*time.Minute), "b"),
+				spooledFile(now.Add(-time.Minute), "c"),
+			},
+			fail:  map[string]error{"b": rejected},
+			sent:  []string{"a", "b", "c"},
+			count: 2,
+			left:  []string{},
+		},
+		{
+			name: "keep reports behind a forbidden response",
+			files: []spoolFile{
+				spooledFile(now.Add(-2*time.Minute), "a"),
+				spooledFile(now.Add(-time.Minute), "b"),
+			},
+			fail:    map[string]error{"a": &StatusError{StatusCode: http.StatusForbidden, Body: "blocked"}},
+			sent:    []string{"a"},
+			count:   0,
+			wantErr: true,
+			left:    []string{"a", "b"},
+		},
+		{
+			name: "skip unreadable and temporary files",
+			files: []spoolFile{
+				spooledFile(now.Add(-3*time.Minute), "a"),
+				{name: fmt.Sprintf("%020d-b%s", now.Add(-2*time.Minute).UnixNano(), spoolSuffix), content: `{"key":`},
+				{name: fmt.Sprintf("%020d-c%s.tmp", now.Add(-time.Minute).UnixNano(), spoolSuffix), content: `{"key":"c"}`},
+				{name: "notes" + spoolSuffix, content: `{}`},
+			},
+			sent:  []string{"a"},
+			count: 1,
+			left:  []string{fmt.Sprintf("%020d-c%s.tmp", now.Add(-time.Minute).UnixNano(), spoolSuffix), "notes" + spoolSuffix},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, file := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, file.name), []byte(file.content), spoolPerm); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s := newSpool(dir, SpoolConfig{})
+			sent := make([]string, 0)
+			count, err := s.replay(func(report *spooledReport) error {
+				sent = append(sent, report.Key)
+				return tt.fail[report.Key]
+			}, time.Time{})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("replay() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if count != tt.count {
+				t.Errorf("replay() = %d, want %d", count, tt.count)
+			}
+			if !reflect.DeepEqual(sent, tt.sent) {
+				t.Errorf("sent = %v, want %v", sent, tt.sent)
+			}
+			if left := spoolKeys(t, dir); !reflect.DeepEqual(left, tt.left) {
+				t.Errorf("left = %v, want %v", left, tt.left)
+			}
+		})
+	}
+}
+
+func TestSpoolReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	// The names of a new run must sort after the reports left by the
+	// previous one, even when the clock reads the same time
+	first := newSpool(dir, SpoolConfig{})
+	for _, key := range []string{"a", "b", "c"} {
+		if err := first.add(key, []byte(`{}`)); err != nil {
+			t.Fatalf("add(%s) error = %v", key, err)
+		}
+	}
+
+	restarted := newSpool(dir, SpoolConfig{})
+	if err := restarted.add("d", []byte(`{}`)); err != nil {
+		t.Fatalf("add(d) error = %v", err)
+	}
+
+	sent := make([]string, 0)
+	if _, err := restarted.replay(func(report *spooledReport) error {
+		sent = append(sent, report.Key)
+		return nil
+	}, time.Time{}); err != nil {
+		t.Fatalf("replay() error = %v", err)
+	}
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent = %v, want %v", sent, want)
+	}
+}
+
+func TestSpoolPrune(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		ages    map[string]time.Duration // key -> age of the report
+		maxSize int64
+		left    []string
+	}{
+		{
+			name:    "within limits",
+			ages:    map[string]time.Duration{"a": 2 * time.Hour, "b": time.Hour},
+			maxSize: 1 << 20,
+			left:    []string{"a", "b"},
+		},
+		{
+			name:    "expired",
+			ages:    map[string]time.Duration{"a": 8 * 24 * time.Hour, "b": time.Hour},
+			maxSize: 1 << 20,
+			left:    []string{"b"},
+		},
+		{
+			name:    "oldest evicted over size",
+			ages:    map[string]time.Duration{"a": 3 * time.Hour, "b": 2 * time.Hour, "c": time.Hour},
+			maxSize: 5 * int64(len(spooledFile(now, "a").content)) / 2,
+			left:    []string{"b", "c"},
+		},
+		{
+			name:    "expired before evicted",
+			ages:    map[string]time.Duration{"a": 8 * 24 * time.Hour, "b": 2 * time.Hour, "c": time.Hour},
+			maxSize: 5 * int64(len(spooledFile(now, "a").content)) / 2,
+			left:    []string{"b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for key, age := range tt.ages {
+				file := spooledFile(now.Add(-age), key)
+				if err := os.WriteFile(filepath.Join(dir, file.name), []byte(file.content), spoolPerm); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s := newSpool(dir, SpoolConfig{MaxSize: tt.maxSize, MaxAge: 7 * 24 * time.Hour})
+			s.mu.Lock()
+			s.prune(now)
+			s.mu.Unlock()
+
+			if left := spoolKeys(t, dir); !reflect.DeepEqual(left, tt.left) {
+				t.Errorf("left = %v, want %v", left, tt.left)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package reporter
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"zenoguard-agent/internal/logger"
+	"zenoguard-agent/internal/proxy"
+)
+
+// ErrPinMismatch is returned when no certificate of the server chain matches
+// a configured SPKI pin
+var ErrPinMismatch = errors.New("server certificate does not match any configured pin")
+
+// newHTTPClient creates the HTTP client used for reports
+func newHTTPClient(tlsConfig *tls.Config, proxyConfig proxy.Config) (*http.Client, error) {
+	transport := &http.Transport{
+		TLSClientConfig:    tlsConfig,
+		MaxIdleConns:       10,
+		IdleConnTimeout:    30 * time.Second,
+		DisableCompression: false,
+	}
+	if err := proxyConfig.Apply(transport); err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+	}, nil
+}
+
+// loadTLSConfig builds the TLS settings of the reporter client: a private
+// CA bundle replaces the system roots, a client certificate enables mutual
+// TLS and pins restrict the accepted server keys
+func loadTLSConfig(config ClientConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: config.ServerName,
+	}
+
+	if config.CAFile != "" {
+		data, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be configured together")
+		}
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+			if time.Now().After(leaf.NotAfter) {
+				logger.Warn("Client certificate " + config.CertFile + " expired on " + leaf.NotAfter.Format(time.RFC3339))
+			}
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(config.Pins) > 0 {
+		pins, err := parsePins(config.Pins)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyConnection = verifyPins(pins)
+	}
+
+	return tlsConfig, nil
+}
+
+// parsePins decodes SPKI pins, the base64 SHA-256 of a certificate's
+// public key as printed by
+// openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+// A "sha256/" or "sha256//" prefix is accepted
+func parsePins(list []string) (map[string]bool, error) {
+	pins := make(map[string]bool, len(list))
+	for _, pin := range list {
+		pin = trimPinPrefix(strings.TrimSpace(pin))
+
+		raw, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid SPKI pin %q: want base64 of a SHA-256 digest", pin)
+		}
+		pins[pin] = true
+	}
+	return pins, nil
+}
+
+// trimPinPrefix strips a "sha256//" or "sha256/" prefix, whichever leaves a
+// pin of the right length: the base64 pin itself may start with "/"
+func trimPinPrefix(pin string) string {
+	pinLen := base64.StdEncoding.EncodedLen(sha256.Size)
+	for _, prefix := range []string{"sha256//", "sha256/"} {
+		if rest, ok := strings.CutPrefix(pin, prefix); ok && len(rest) == pinLen {
+			return rest
+		}
+	}
+	return pin
+}
+
+// spkiPin returns the SPKI pin of a certificate
+func spkiPin(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// verifyPins accepts a connection whose verified chain holds a pinned key,
+// the server certificate or one of its CAs
+func verifyPins(pins map[string]bool) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		for _, chain := range state.VerifiedChains {
+			for _, cert := range chain {
+				if pins[spkiPin(cert)] {
+					return nil
+				}
+			}
+		}
+
+		seen := make([]string, 0, len(state.PeerCertificates))
+		for _, cert := range state.PeerCertificates {
+			seen = append(seen, fmt.Sprintf("%s=sha256/%s", cert.Subject.CommonName, spkiPin(cert)))
+		}
+		logger.Error(fmt.Sprintf("Certificate pin mismatch for %s, server presented %s",
+			state.ServerName, strings.Join(seen, ", ")))
+		return ErrPinMismatch
+	}
+}
+
+// tlsFilesStamp identifies the current version of the certificate files,
+// it changes when one of them is replaced
+func tlsFilesStamp(config ClientConfig) string {
+	var stamp strings.Builder
+	for _, path := range []string{config.CAFile, config.CertFile, config.KeyFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&stamp, "%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+		} else {
+			fmt.Fprintf(&stamp, "%s:missing;", path)
+		}
+	}
+	return stamp.String()
+}
+
+// reloadTLS rebuilds the HTTP client when a certificate file changed, so
+// renewed certificates are picked up without a restart
+// A broken update keeps the previous certificates until it is fixed, and
+// is only reported once rather than on every request
+func (c *Client) reloadTLS() {
+	stamp := tlsFilesStamp(c.config)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stamp == c.tlsStamp && c.httpClient != nil {
+		return
+	}
+	if stamp == c.tlsFailed && (c.httpClient != nil || c.tlsErr != nil) {
+		return
+	}
+
+	tlsConfig, err := loadTLSConfig(c.config)
+	var httpClient *http.Client
+	if err == nil {
+		httpClient, err = newHTTPClient(tlsConfig, c.config.Proxy)
+	}
+	if err != nil {
+		c.tlsFailed = stamp
+		if c.httpClient != nil {
+			logger.Error("Failed to reload certificates, keeping the previous ones: " + err.Error())
+			return
+		}
+		c.tlsErr = fmt.Errorf("client configuration: %w", err)
+		logger.Error(c.tlsErr.Error())
+		return
+	}
+
+	if c.httpClient != nil {
+		c.httpClient.CloseIdleConnections()
+		logger.Info("Reloaded TLS certificates")
+	}
+	c.httpClient = httpClient
+	c.tlsStamp = stamp
+	c.tlsFailed = ""
+	c.tlsErr = nil
+}
+
+// requestProxy returns the proxy a request is sent through, nil if none
+func requestProxy(httpClient *http.Client, req *http.Request) *url.URL {
+	transport, ok := httpClient.Transport.(*http.Transport)
+	if !ok || transport.Proxy == nil {
+		return nil
+	}
+	proxyURL, _ := transport.Proxy(req)
+	return proxyURL
+}
+
+// client returns the HTTP client, reloading certificates first
+func (c *Client) client() (*http.Client, error) {
+	c.reloadTLS()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tlsErr != nil {
+		return nil, c.tlsErr
+	}
+	return c.httpClient, nil
+}
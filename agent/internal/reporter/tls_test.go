@@ -0,0 +1,239 @@
+package reporter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCert is a self-signed server certificate for 127.0.0.1
+type testCert struct {
+	tls  tls.Certificate
+	pem  []byte
+	leaf *x509.Certificate
+}
+
+// newTestCert creates a self-signed certificate that is its own CA
+func newTestCert(t *testing.T, name string) testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testCert{
+		tls:  tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf},
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		leaf: leaf,
+	}
+}
+
+// newTestTLSServer starts a TLS server presenting the certificate held by
+// current, read on every handshake so tests can rotate it
+func newTestTLSServer(t *testing.T, current *atomic.Pointer[testCert]) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	// httptest fills in its own certificate, which GetCertificate would not
+	// override for clients connecting by IP address
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{Certificates: []tls.Certificate{current.Load().tls}}, nil
+		},
+	}
+	// Every request needs a new handshake to see the current certificate
+	server.Config.SetKeepAlivesEnabled(false)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeCAFile replaces the CA bundle at path, with a new modification time
+// so the change is noticed even when the size stays the same
+func writeCAFile(t *testing.T, path string, data []byte, modified time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getStatus sends a request through the reporter's HTTP client
+func getStatus(c *Client, url string) error {
+	httpClient, err := c.client()
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func TestClientPins(t *testing.T) {
+	serverCert := newTestCert(t, "server")
+	otherCert := newTestCert(t, "other")
+
+	var current atomic.Pointer[testCert]
+	current.Store(&serverCert)
+	server := newTestTLSServer(t, &current)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeCAFile(t, caFile, serverCert.pem, time.Now())
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr error
+	}{
+		{name: "no pins", pins: nil},
+		{name: "matching pin", pins: []string{spkiPin(serverCert.leaf)}},
+		{name: "matching pin with prefix", pins: []string{"sha256//" + spkiPin(serverCert.leaf)}},
+		{name: "one of several pins", pins: []string{spkiPin(otherCert.leaf), spkiPin(serverCert.leaf)}},
+		{name: "wrong pin", pins: []string{spkiPin(otherCert.leaf)}, wantErr: ErrPinMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(server.URL, "token", ClientConfig{CAFile: caFile, Pins: tt.pins})
+
+			err := getStatus(c, server.URL)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("request error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientReloadTLS(t *testing.T) {
+	oldCert := newTestCert(t, "old")
+	newCert := newTestCert(t, "new")
+
+	var current atomic.Pointer[testCert]
+	current.Store(&oldCert)
+	server := newTestTLSServer(t, &current)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	modified := time.Now().Add(-time.Hour)
+	writeCAFile(t, caFile, oldCert.pem, modified)
+
+	c := NewClient(server.URL, "token", ClientConfig{CAFile: caFile})
+	if err := getStatus(c, server.URL); err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+
+	// The server switches to a certificate of another CA before the
+	// client's bundle is updated
+	current.Store(&newCert)
+	if err := getStatus(c, server.URL); err == nil {
+		t.Fatal("request to a server of an unknown CA succeeded")
+	}
+
+	modified = modified.Add(time.Minute)
+	writeCAFile(t, caFile, newCert.pem, modified)
+	if err := getStatus(c, server.URL); err != nil {
+		t.Fatalf("request error after the CA bundle changed = %v", err)
+	}
+
+	// A broken bundle keeps the previous certificates and is only tried
+	// once until the file changes again
+	modified = modified.Add(time.Minute)
+	writeCAFile(t, caFile, []byte("not a certificate"), modified)
+	if err := getStatus(c, server.URL); err != nil {
+		t.Fatalf("request error with a broken CA bundle = %v", err)
+	}
+	c.mu.Lock()
+	failed, stamp := c.tlsFailed, tlsFilesStamp(c.config)
+	c.mu.Unlock()
+	if failed != stamp {
+		t.Errorf("tlsFailed = %q, want %q", failed, stamp)
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	digest := func(first byte) string {
+		raw := make([]byte, sha256.Size)
+		raw[0] = first
+		return base64.StdEncoding.EncodeToString(raw)
+	}
+	plain := digest(0x00)   // "AAAA..."
+	slashed := digest(0xfc) // "/AAA...", a pin starting with the separator
+
+	tests := []struct {
+		name    string
+		pin     string
+		want    string
+		wantErr bool
+	}{
+		{name: "bare", pin: plain, want: plain},
+		{name: "single slash prefix", pin: "sha256/" + plain, want: plain},
+		{name: "double slash prefix", pin: "sha256//" + plain, want: plain},
+		{name: "surrounding spaces", pin: "  sha256//" + plain + " ", want: plain},
+		{name: "bare pin starting with a slash", pin: slashed, want: slashed},
+		{name: "single slash prefix and pin starting with a slash", pin: "sha256/" + slashed, want: slashed},
+		{name: "double slash prefix and pin starting with a slash", pin: "sha256//" + slashed, want: slashed},
+		{name: "other prefix", pin: "sha1/" + plain, wantErr: true},
+		{name: "triple slash prefix", pin: "sha256///" + plain, wantErr: true},
+		{name: "short digest", pin: "sha256//" + base64.StdEncoding.EncodeToString(make([]byte, 20)), wantErr: true},
+		{name: "not base64", pin: "sha256//" + strings.Repeat("!", 44), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pins, err := parsePins([]string{tt.pin})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePins(%q) error = %v, wantErr %v", tt.pin, err, tt.wantErr)
+			}
+			if err == nil && (len(pins) != 1 || !pins[tt.want]) {
+				t.Errorf("parsePins(%q) = %v, want %s", tt.pin, pins, tt.want)
+			}
+		})
+	}
+}
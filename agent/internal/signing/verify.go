@@ -0,0 +1,204 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSkew is the default accepted difference between the request
+// timestamp and the server clock
+const DefaultMaxSkew = 5 * time.Minute
+
+// Error codes of a failed verification response
+const (
+	ErrorCodeClockSkew        = "clock_skew"
+	ErrorCodeInvalidSignature = "invalid_signature"
+)
+
+// Verification errors
+var (
+	ErrMissingHeaders   = errors.New("missing signature headers")
+	ErrInvalidTimestamp = errors.New("invalid signature timestamp")
+	ErrBodyHash         = errors.New("body does not match the content hash")
+	ErrSignature        = errors.New("signature mismatch")
+	ErrReplay           = errors.New("nonce already used")
+)
+
+// SkewError is returned when the request timestamp is too far from the
+// server clock, the client can resynchronize with ServerTime
+type SkewError struct {
+	ServerTime time.Time
+	Skew       time.Duration // request time minus server time
+}
+
+func (e *SkewError) Error() string {
+	return fmt.Sprintf("request timestamp is %v off the server clock", e.Skew)
+}
+
+// ErrorResponse is the JSON body written by WriteError
+type ErrorResponse struct {
+	Error      string `json:"error"`
+	Code       string `json:"code"`                  // clock_skew, invalid_signature
+	ServerTime int64  `json:"server_time,omitempty"` // unix seconds, with clock_skew
+}
+
+// NonceStore remembers the nonces of accepted requests
+type NonceStore interface {
+	// Use records a nonce until expires and reports false if it was
+	// already recorded
+	Use(nonce string, expires time.Time) bool
+}
+
+// nonceSweepInterval is how often MemoryNonceStore drops expired nonces
+const nonceSweepInterval = time.Minute
+
+// MemoryNonceStore is a NonceStore for a single server process
+// Expired nonces are dropped at most once per nonceSweepInterval, so a
+// request costs a map lookup rather than a walk over every stored nonce
+type MemoryNonceStore struct {
+	nonces    map[string]time.Time
+	nextSweep time.Time
+	mu        sync.Mutex
+}
+
+// NewMemoryNonceStore creates an in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+// Use records a nonce
+// A nonce whose expiry has passed is accepted again even before the sweep
+// removed it
+func (s *MemoryNonceStore) Use(nonce string, expires time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		s.sweep(now)
+		s.nextSweep = now.Add(nonceSweepInterval)
+	}
+
+	if until, ok := s.nonces[nonce]; ok && !now.After(until) {
+		return false
+	}
+	s.nonces[nonce] = expires
+	return true
+}
+
+// sweep drops expired nonces
+// Must be called with s.mu held
+func (s *MemoryNonceStore) sweep(now time.Time) {
+	for seen, until := range s.nonces {
+		if now.After(until) {
+			delete(s.nonces, seen)
+		}
+	}
+}
+
+// Verifier checks signed requests
+// A request is accepted once: its timestamp must be within MaxSkew of the
+// server clock and its nonce is remembered for as long as the timestamp
+// would be accepted
+type Verifier struct {
+	MaxSkew time.Duration // 0 uses DefaultMaxSkew
+	Nonces  NonceStore    // nil disables replay protection
+	Now     func() time.Time
+}
+
+// NewVerifier creates a verifier with an in-memory nonce store
+func NewVerifier() *Verifier {
+	return &Verifier{MaxSkew: DefaultMaxSkew, Nonces: NewMemoryNonceStore()}
+}
+
+// Verify checks the signature of a request against the secret of the host
+// that sent it; body is the request body as received, before decompression
+func (v *Verifier) Verify(req *http.Request, body []byte, secret []byte) error {
+	timestamp := req.Header.Get(HeaderTimestamp)
+	nonce := req.Header.Get(HeaderNonce)
+	bodyHash := req.Header.Get(HeaderBodyHash)
+	signature := req.Header.Get(HeaderSignature)
+	if timestamp == "" || nonce == "" || bodyHash == "" || signature == "" {
+		return ErrMissingHeaders
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	maxSkew := v.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxSkew
+	}
+
+	requestTime := time.Unix(seconds, 0)
+	if skew := requestTime.Sub(now); skew > maxSkew || skew < -maxSkew {
+		return &SkewError{ServerTime: now, Skew: skew}
+	}
+
+	if !hmac.Equal([]byte(strings.ToLower(bodyHash)), []byte(BodyHash(body))) {
+		return ErrBodyHash
+	}
+
+	expected := signatureVersion + Signature(secret, StringToSign(req.Method, requestPath(req), timestamp, nonce, bodyHash))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrSignature
+	}
+
+	// Checked last, so requests with a bad signature cannot burn nonces
+	if v.Nonces != nil && !v.Nonces.Use(nonce, requestTime.Add(maxSkew)) {
+		return ErrReplay
+	}
+
+	return nil
+}
+
+// WriteError answers a request that failed verification with 401
+// Clock skew errors carry the server time so the client can resynchronize
+func WriteError(w http.ResponseWriter, err error) {
+	response := ErrorResponse{Error: err.Error(), Code: ErrorCodeInvalidSignature}
+
+	var skewErr *SkewError
+	if errors.As(err, &skewErr) {
+		response.Code = ErrorCodeClockSkew
+		response.ServerTime = skewErr.ServerTime.Unix()
+		w.Header().Set(HeaderServerTime, strconv.FormatInt(response.ServerTime, 10))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ParseErrorResponse reads the error code of a 401 response, and the
+// server time of a clock skew error
+// ok is false if the response is not a signature verification error
+func ParseErrorResponse(header http.Header, body []byte) (code string, serverTime time.Time, ok bool) {
+	var response ErrorResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", time.Time{}, false
+	}
+
+	switch response.Code {
+	case ErrorCodeClockSkew:
+		if seconds, err := strconv.ParseInt(header.Get(HeaderServerTime), 10, 64); err == nil {
+			return response.Code, time.Unix(seconds, 0), true
+		}
+		return response.Code, time.Unix(response.ServerTime, 0), response.ServerTime > 0
+	case ErrorCodeInvalidSignature:
+		return response.Code, time.Time{}, true
+	}
+	return "", time.Time{}, false
+}
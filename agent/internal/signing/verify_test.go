@@ -0,0 +1,214 @@
+package signing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("host-secret")
+
+// signedRequest returns a POST request to target signed by signer
+func signedRequest(t *testing.T, signer *Signer, target string, body []byte) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	if err := signer.Sign(req, body); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"hostname":"web-01"}`)
+
+	tests := []struct {
+		name   string
+		modify func(req *http.Request) (*http.Request, []byte)
+		secret []byte
+		want   error
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:   "wrong secret",
+			secret: []byte("other-secret"),
+			want:   ErrSignature,
+		},
+		{
+			name: "body changed",
+			modify: func(req *http.Request) (*http.Request, []byte) {
+				return req, []byte(`{"hostname":"web-02"}`)
+			},
+			want: ErrBodyHash,
+		},
+		{
+			name: "body and hash changed",
+			modify: func(req *http.Request) (*http.Request, []byte) {
+				changed := []byte(`{"hostname":"web-02"}`)
+				req.Header.Set(HeaderBodyHash, BodyHash(changed))
+				return req, changed
+			},
+			want: ErrSignature,
+		},
+		{
+			name: "path changed",
+			modify: func(req *http.Request) (*http.Request, []byte) {
+				req.URL.Path = "/api/agent/register"
+				return req, body
+			},
+			want: ErrSignature,
+		},
+		{
+			name: "query changed",
+			modify: func(req *http.Request) (*http.Request, []byte) {
+				req.URL.RawQuery = "host=web-02"
+				return req, body
+			},
+			want: ErrSignature,
+		},
+		{
+			name: "method changed",
+			modify: func(req *http.Request) (*http.Request, []byte) {
+				req.Method = http.MethodPut
+				return req, body
+			},
+			want: ErrSignature,
+		},
+		{
+			name: "timestamp changed",
+			modify: func(req *http.Request) (*http.Request, []byte) {
+				req.Header.Set(HeaderTimestamp, "1")
+				return req, body
+			},
+			want: &SkewError{},
+		},
+		{
+			name: "missing signature",
+			modify: func(req *http.Request) (*http.Request, []byte) {
+				req.Header.Del(HeaderSignature)
+				return req, body
+			},
+			want: ErrMissingHeaders,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := signedRequest(t, NewSigner(testSecret), "/api/agent/report?host=web-01", body)
+			received := body
+			if tt.modify != nil {
+				req, received = tt.modify(req)
+			}
+			secret := testSecret
+			if tt.secret != nil {
+				secret = tt.secret
+			}
+
+			err := NewVerifier().Verify(req, received, secret)
+			switch want := tt.want.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("Verify() error = %v, want nil", err)
+				}
+			case *SkewError:
+				var skewErr *SkewError
+				if !errors.As(err, &skewErr) {
+					t.Errorf("Verify() error = %v, want a clock skew error", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Errorf("Verify() error = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	body := []byte("{}")
+	verifier := NewVerifier()
+	req := signedRequest(t, NewSigner(testSecret), "/api/agent/report", body)
+
+	if err := verifier.Verify(req, body, testSecret); err != nil {
+		t.Fatalf("first Verify() error = %v", err)
+	}
+	if err := verifier.Verify(req, body, testSecret); !errors.Is(err, ErrReplay) {
+		t.Errorf("replayed Verify() error = %v, want %v", err, ErrReplay)
+	}
+
+	// A request with a bad signature must not burn the nonce of a later one
+	fresh := signedRequest(t, NewSigner(testSecret), "/api/agent/report", body)
+	forged := fresh.Clone(fresh.Context())
+	forged.Header.Set(HeaderSignature, signatureVersion+"00")
+	if err := verifier.Verify(forged, body, testSecret); !errors.Is(err, ErrSignature) {
+		t.Fatalf("forged Verify() error = %v, want %v", err, ErrSignature)
+	}
+	if err := verifier.Verify(fresh, body, testSecret); err != nil {
+		t.Errorf("Verify() after a forged request with the same nonce error = %v", err)
+	}
+}
+
+func TestVerifyClockSkew(t *testing.T) {
+	body := []byte("{}")
+	serverTime := time.Now().Add(10 * time.Minute)
+	verifier := NewVerifier()
+	verifier.Now = func() time.Time { return serverTime }
+
+	signer := NewSigner(testSecret)
+	err := verifier.Verify(signedRequest(t, signer, "/api/agent/report", body), body, testSecret)
+
+	var skewErr *SkewError
+	if !errors.As(err, &skewErr) {
+		t.Fatalf("Verify() error = %v, want a clock skew error", err)
+	}
+
+	// The client resynchronizes from the error response and retries
+	recorder := httptest.NewRecorder()
+	WriteError(recorder, err)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("WriteError() status = %d, want 401", recorder.Code)
+	}
+
+	code, reported, ok := ParseErrorResponse(recorder.Header(), recorder.Body.Bytes())
+	if !ok || code != ErrorCodeClockSkew || reported.Unix() != serverTime.Unix() {
+		t.Fatalf("ParseErrorResponse() = %q, %v, %v", code, reported, ok)
+	}
+
+	signer.SyncClock(reported)
+	if err := verifier.Verify(signedRequest(t, signer, "/api/agent/report", body), body, testSecret); err != nil {
+		t.Errorf("Verify() after SyncClock error = %v", err)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore()
+	now := time.Now()
+
+	if !store.Use("a", now.Add(time.Minute)) {
+		t.Fatal("first Use() rejected")
+	}
+	if store.Use("a", now.Add(time.Minute)) {
+		t.Error("second Use() of a live nonce accepted")
+	}
+
+	// Expired nonces are accepted again and dropped by the next sweep
+	if !store.Use("b", now.Add(-time.Second)) || !store.Use("b", now.Add(-time.Second)) {
+		t.Error("Use() of an expired nonce rejected")
+	}
+
+	store.nextSweep = time.Time{}
+	store.Use("c", now.Add(time.Minute))
+	if _, ok := store.nonces["b"]; ok {
+		t.Error("expired nonce kept after a sweep")
+	}
+	if _, ok := store.nonces["a"]; !ok {
+		t.Error("live nonce dropped by a sweep")
+	}
+	if !store.nextSweep.After(now) {
+		t.Error("next sweep not scheduled")
+	}
+}
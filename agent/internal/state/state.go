@@ -0,0 +1,65 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	statePerm    = 0600
+	stateDirPerm = 0700
+)
+
+// Load reads a JSON state file into v
+// A missing file is not an error, v is left untouched in that case
+func Load(path string, v interface{}) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read state %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Save atomically writes v as JSON to the state file
+// The data is written to a temporary file first and renamed into place,
+// so a crash never leaves a half-written state file behind
+func Save(path string, v interface{}) error {
+	if path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), stateDirPerm); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, statePerm); err != nil {
+		return fmt.Errorf("failed to write state %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename state %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func main() {
-	sshCollector := collector.NewSSHCollector()
+	sshCollector := collector.NewSSHCollector("", collector.DefaultBruteForceConfig())
 	fmt.Printf("Log paths: %v\n", sshCollector.GetLogPath())
 	fmt.Printf("Log size: %d bytes\n", sshCollector.GetLogSize())
 
@@ -17,8 +17,23 @@ func main() {
 	}
 
 	fmt.Printf("\nSSH Data:\n")
-	for _, login := range data.([]collector.SSHLogin) {
+	sshData := data.(*collector.SSHData)
+	for _, login := range sshData.Logins {
 		fmt.Printf("User: %s, IP: %s, Time: %s, Active: %v, Duration: %d seconds\n",
 			login.User, login.IP, login.Time, login.IsActive, login.SessionDuration)
 	}
+
+	fmt.Printf("\nSSH Sessions:\n")
+	for _, session := range sshData.Sessions {
+		fmt.Printf("User: %s, IP: %s, Port: %d, Start: %s, Duration: %d seconds, Reason: %s\n",
+			session.User, session.IP, session.Port, session.Start.Format("2006-01-02 15:04:05"),
+			session.Duration, session.DisconnectReason)
+	}
+
+	fmt.Printf("\nBrute force detections:\n")
+	for _, event := range sshData.BruteForce {
+		fmt.Printf("%s %s%s: %d failures, users %v, %s - %s\n",
+			event.Kind, event.IP, event.User, event.Failures, event.Usernames,
+			event.FirstSeen.Format("15:04:05"), event.LastSeen.Format("15:04:05"))
+	}
 }